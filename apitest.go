@@ -5,12 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
-	"net/http/httputil"
 	"net/textproto"
 	"net/url"
+	"os"
 	"runtime/debug"
 	"sort"
 	"strings"
@@ -31,6 +32,9 @@ var responseDebugPrefix = fmt.Sprintf("<%s", divider)
 type APITest struct {
 	debugEnabled             bool
 	mockResponseDelayEnabled bool
+	failOnUnusedMocks        bool
+	parallelMocks            bool
+	mockRoutingID            string
 	networkingEnabled        bool
 	networkingHTTPClient     *http.Client
 	reporter                 ReportFormatter
@@ -44,10 +48,17 @@ type APITest struct {
 	mocksObservers           []Observe
 	recorderHook             RecorderHook
 	mocks                    []*Mock
+	mockServers              []*MockServer
+	cassette                 *Cassette
+	mockPassthrough          bool
+	mockPassthroughHosts     []string
+	openAPISpecPath          string
 	t                        TestingT
 	httpClient               *http.Client
 	transport                *Transport
 	meta                     map[string]interface{}
+	vars                     map[string]string
+	scenario                 *Scenario
 	started                  time.Time
 	finished                 time.Time
 }
@@ -74,6 +85,7 @@ type RecorderHook func(*Recorder)
 func New(name ...string) *APITest {
 	apiTest := &APITest{
 		meta: map[string]interface{}{},
+		vars: map[string]string{},
 	}
 
 	request := &Request{
@@ -123,6 +135,13 @@ func (a *APITest) EnableMockResponseDelay() *APITest {
 	return a
 }
 
+// FailOnUnusedMocks fails the test if any of the mocks did not receive a request.
+// By default unused mocks are only reported in the Result
+func (a *APITest) FailOnUnusedMocks() *APITest {
+	a.failOnUnusedMocks = true
+	return a
+}
+
 // Debug logs to the console the http wire representation of all http interactions that are intercepted by apitest. This includes the inbound request to the application under test, the response returned by the application and any interactions that are intercepted by the mock server.
 func (a *APITest) Debug() *APITest {
 	a.debugEnabled = true
@@ -163,17 +182,54 @@ func (a *APITest) HandlerFunc(handlerFunc http.HandlerFunc) *APITest {
 func (a *APITest) Mocks(mocks ...*Mock) *APITest {
 	var m []*Mock
 	for i := range mocks {
-		times := mocks[i].response.mock.times
-		for j := 1; j <= times; j++ {
-			mockCpy := mocks[i].copy()
-			mockCpy.times = 1
-			m = append(m, mockCpy)
-		}
+		// copied so that the invocation count is not shared when a mock is reused across tests
+		m = append(m, mocks[i].copy())
 	}
 	a.mocks = m
 	return a
 }
 
+// ParallelMocks routes the requests made by the application to the mocks of this test instead of replacing
+// http.DefaultTransport, so that tests using mocks can run in parallel using t.Parallel().
+// The application must create outbound requests using the context of the inbound request, e.g.
+// http.NewRequestWithContext(r.Context(), ...)
+func (a *APITest) ParallelMocks() *APITest {
+	a.parallelMocks = true
+	return a
+}
+
+// MockPassthrough sends requests that do not match any mock to the real service instead of failing the request.
+// If hosts are given only requests to these hosts are sent, e.g. "localhost:8081" or "localhost".
+// Passthrough interactions are drawn with dashed arrows in the sequence diagram
+func (a *APITest) MockPassthrough(hosts ...string) *APITest {
+	a.mockPassthrough = true
+	a.mockPassthroughHosts = hosts
+	return a
+}
+
+// Cassette records the outbound http interactions of the application to the cassette file or replays them as mocks,
+// depending on the mode of the cassette. In record mode any requests that do not match the mocks of the test
+// are sent to the network
+func (a *APITest) Cassette(cassette *Cassette) *APITest {
+	a.cassette = cassette
+	return a
+}
+
+// OpenAPISpec validates the inbound request and the final response against the matching operation of the OpenAPI 3
+// document at the given path. The parameters and body of the request, the status code, the required headers and
+// the body of the response are checked, so that the test fails if the application does not implement the contract
+func (a *APITest) OpenAPISpec(path string) *APITest {
+	a.openAPISpecPath = path
+	return a
+}
+
+// MockServers is a builder method for observing the interactions with mock servers while the test runs,
+// so that the interactions are debugged and shown in the test report
+func (a *APITest) MockServers(servers ...*MockServer) *APITest {
+	a.mockServers = servers
+	return a
+}
+
 // HttpClient allows the developer to provide a custom http client when using mocks
 func (a *APITest) HttpClient(cli *http.Client) *APITest {
 	a.httpClient = cli
@@ -216,10 +272,15 @@ type Request struct {
 	method          string
 	url             string
 	body            string
+	bodyBytes       []byte
+	bodyReader      io.Reader
+	chunked         bool
 	query           map[string][]string
 	queryCollection map[string][]string
 	headers         map[string][]string
 	formData        map[string][]string
+	multipart       []multipartPart
+	multipartLast   int
 	cookies         []*Cookie
 	basicAuth       string
 	apiTest         *APITest
@@ -348,6 +409,26 @@ func (r *Request) BodyFromFile(f string) *Request {
 	return r
 }
 
+// BodyBytes is a builder method to set a binary request body, e.g. a protobuf message. The body is not interpolated
+func (r *Request) BodyBytes(b []byte) *Request {
+	r.bodyBytes = b
+	return r
+}
+
+// BodyReader is a builder method to read the request body from the reader, e.g. a file. The body is buffered in memory
+// when the request is sent, so that it can be recorded. The length of the body is unknown, so the request is sent with
+// chunked transfer encoding if networking is enabled
+func (r *Request) BodyReader(reader io.Reader) *Request {
+	r.bodyReader = reader
+	return r
+}
+
+// Chunked is a builder method to send the request body with chunked transfer encoding and without a Content-Length header
+func (r *Request) Chunked() *Request {
+	r.chunked = true
+	return r
+}
+
 // JSON is a convenience method for setting the request body and content type header as "application/json".
 // If v is not a string or []byte it will marshall the provided variable as json
 func (r *Request) JSON(v interface{}) *Request {
@@ -368,6 +449,19 @@ func (r *Request) JSON(v interface{}) *Request {
 	return r
 }
 
+// XML is a convenience method for setting the request body and content type header as "application/xml".
+// If the parameter is not a string or a byte slice it is marshalled using encoding/xml
+func (r *Request) XML(v interface{}) *Request {
+	body, err := marshalXML(v)
+	if err != nil {
+		r.apiTest.t.Fatal(err)
+		return nil
+	}
+	r.body = body
+	r.ContentType("application/xml")
+	return r
+}
+
 // JSONFromFile is a convenience method for setting the request body and content type header as "application/json"
 func (r *Request) JSONFromFile(f string) *Request {
 	r.BodyFromFile(f)
@@ -480,6 +574,42 @@ func (r *Request) FormData(name string, values ...string) *Request {
 	return r
 }
 
+// MultipartFormData is a builder method to add a form value to a multipart/form-data body. Each value is added as a
+// separate part. The content type of the request is set to multipart/form-data with the boundary of the body
+func (r *Request) MultipartFormData(name string, values ...string) *Request {
+	r.multipartLast = len(r.multipart)
+	for _, value := range values {
+		r.multipart = append(r.multipart, multipartPart{field: name, value: value})
+	}
+	return r
+}
+
+// MultipartFile is a builder method to add files to a multipart/form-data body. The content of the files is streamed
+// when the request is sent and the content type of each part is inferred from the file extension
+func (r *Request) MultipartFile(field string, paths ...string) *Request {
+	r.multipartLast = len(r.multipart)
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			r.apiTest.t.Fatal(err)
+		}
+		r.multipart = append(r.multipart, multipartPart{field: field, file: path})
+	}
+	return r
+}
+
+// MultipartContentType sets the content type of the parts added by the preceding call to MultipartFormData or
+// MultipartFile, e.g. MultipartFile("avatar", "testdata/avatar.bin").MultipartContentType("image/png")
+func (r *Request) MultipartContentType(contentType string) *Request {
+	if r.multipartLast >= len(r.multipart) {
+		r.apiTest.t.Fatal(errNoMultipartParts)
+		return r
+	}
+	for i := r.multipartLast; i < len(r.multipart); i++ {
+		r.multipart[i].contentType = contentType
+	}
+	return r
+}
+
 // Expect marks the request spec as complete and following code will define the expected response
 func (r *Request) Expect(t TestingT) *Response {
 	r.apiTest.t = t
@@ -490,6 +620,11 @@ func (r *Request) Expect(t TestingT) *Response {
 type Response struct {
 	status            int
 	body              string
+	jsonSchemas       []*jsonSchema
+	jsonPaths         []jsonPathAssertion
+	xmlBody           string
+	xPaths            []xPathAssertion
+	snapshot          *snapshot
 	headers           map[string][]string
 	headersPresent    []string
 	headersNotPresent []string
@@ -498,6 +633,7 @@ type Response struct {
 	cookiesNotPresent []string
 	apiTest           *APITest
 	assert            []Assert
+	captures          []capture
 }
 
 // Assert is a user defined custom assertion function
@@ -525,6 +661,66 @@ func (r *Response) BodyFromFile(f string) *Response {
 	return r
 }
 
+// JSONSchema validates the response body against the given JSON schema. The keywords of draft-07 and 2020-12 are
+// supported and references are resolved within the schema, e.g. #/$defs/user
+func (r *Response) JSONSchema(schema string) *Response {
+	document, err := parseJSONDocument([]byte(schema))
+	if err != nil {
+		r.apiTest.t.Fatal(err)
+	}
+	r.jsonSchemas = append(r.jsonSchemas, newJSONSchema(document, document))
+	return r
+}
+
+// JSONSchemaFromFile validates the response body against the JSON schema in the given file
+func (r *Response) JSONSchemaFromFile(f string) *Response {
+	document, err := readJSONDocument(f)
+	if err != nil {
+		r.apiTest.t.Fatal(err)
+	}
+	r.jsonSchemas = append(r.jsonSchemas, newJSONSchema(document, document))
+	return r
+}
+
+// JSONPath selects a value from the JSON response body using the given expression and returns a builder to assert on
+// the value, e.g. JSONPath("$.items[*].id").Len(2). Failures report the expression and the expected and actual value
+func (r *Response) JSONPath(expr string) *ResponseJSONPath {
+	path, err := compileJSONPath(expr)
+	if err != nil {
+		r.apiTest.t.Fatal(err)
+	}
+	return &ResponseJSONPath{response: r, expr: expr, path: path}
+}
+
+// XMLEq asserts that the response body is XML equivalent to the expected document. Whitespace between elements,
+// the order of attributes and namespace prefixes are ignored
+func (r *Response) XMLEq(expected string) *Response {
+	canonical, err := canonicalXML([]byte(expected))
+	if err != nil {
+		r.apiTest.t.Fatal(err)
+	}
+	r.xmlBody = canonical
+	return r
+}
+
+// XPath selects a node from the XML response body using the given expression and returns a builder to assert on the
+// node, e.g. XPath("/users/user[@id='1']/name").Equal("jan")
+func (r *Response) XPath(expr string) *ResponseXPath {
+	path, err := compileXPath(expr)
+	if err != nil {
+		r.apiTest.t.Fatal(err)
+	}
+	return &ResponseXPath{response: r, expr: expr, path: path}
+}
+
+// MatchSnapshot compares the status, headers and body of the response with the snapshot stored in
+// testdata/__snapshots__/<TestName>.snap. The snapshot is created if it does not exist and is rewritten if the
+// APITEST_UPDATE_SNAPSHOTS environment variable is set to true
+func (r *Response) MatchSnapshot(options ...SnapshotOption) *Response {
+	r.snapshot = newSnapshot(options...)
+	return r
+}
+
 // Cookies is the expected response cookies
 func (r *Response) Cookies(cookies ...*Cookie) *Response {
 	r.cookies = append(r.cookies, cookies...)
@@ -615,19 +811,9 @@ func (r *Response) End() Result {
 		res = r.runTest()
 	}
 
-	var unmatchedMocks []UnmatchedMock
-	for _, m := range r.apiTest.mocks {
-		if m.isUsed == false {
-			unmatchedMocks = append(unmatchedMocks, UnmatchedMock{
-				URL: *m.request.url,
-			})
-			break
-		}
-	}
-
 	return Result{
 		Response:       res,
-		unmatchedMocks: unmatchedMocks,
+		unmatchedMocks: apiTest.unmatchedMocks(),
 	}
 }
 
@@ -655,9 +841,10 @@ func (r Result) JSON(t interface{}) {
 }
 
 type mockInteraction struct {
-	request   *http.Request
-	response  *http.Response
-	timestamp time.Time
+	request     *http.Request
+	response    *http.Response
+	timestamp   time.Time
+	passthrough bool
 }
 
 func (r *mockInteraction) GetRequestHost() string {
@@ -680,16 +867,20 @@ func (a *APITest) report() *http.Response {
 
 	a.mocksObservers = append(a.mocksObservers, func(mockRes *http.Response, mockReq *http.Request, a *APITest) {
 		capturedMockInteractions = append(capturedMockInteractions, &mockInteraction{
-			request:   copyHttpRequest(mockReq),
-			response:  copyHttpResponse(mockRes),
-			timestamp: time.Now().UTC(),
+			request:     copyHttpRequest(mockReq),
+			response:    copyHttpResponse(mockRes),
+			timestamp:   time.Now().UTC(),
+			passthrough: isPassthrough(mockReq),
 		})
 	})
 
 	if a.recorder == nil {
 		a.recorder = NewTestRecorder()
 	}
-	defer a.recorder.Reset()
+	// a scenario formats the events of all of its steps once it has completed
+	if a.scenario == nil {
+		defer a.recorder.Reset()
+	}
 
 	if a.recorderHook != nil {
 		a.recorderHook(a.recorder)
@@ -699,29 +890,28 @@ func (a *APITest) report() *http.Response {
 	res := a.response.runTest()
 	a.finished = time.Now()
 
-	a.recorder.
-		AddTitle(fmt.Sprintf("%s %s", capturedInboundReq.Method, capturedInboundReq.URL.String())).
-		AddSubTitle(a.name).
-		AddHttpRequest(HttpRequest{
-			Source:    quoted(ConsumerName),
-			Target:    quoted(SystemUnderTestDefaultName),
-			Value:     capturedInboundReq,
-			Timestamp: a.started,
-		})
+	a.recorder.AddHttpRequest(HttpRequest{
+		Source:    quoted(ConsumerName),
+		Target:    quoted(SystemUnderTestDefaultName),
+		Value:     capturedInboundReq,
+		Timestamp: a.started,
+	})
 
 	for _, interaction := range capturedMockInteractions {
 		a.recorder.AddHttpRequest(HttpRequest{
-			Source:    quoted(SystemUnderTestDefaultName),
-			Target:    quoted(interaction.GetRequestHost()),
-			Value:     interaction.request,
-			Timestamp: interaction.timestamp,
+			Source:      quoted(SystemUnderTestDefaultName),
+			Target:      quoted(interaction.GetRequestHost()),
+			Value:       interaction.request,
+			Timestamp:   interaction.timestamp,
+			Passthrough: interaction.passthrough,
 		})
 		if interaction.response != nil {
 			a.recorder.AddHttpResponse(HttpResponse{
-				Source:    quoted(interaction.GetRequestHost()),
-				Target:    quoted(SystemUnderTestDefaultName),
-				Value:     interaction.response,
-				Timestamp: interaction.timestamp,
+				Source:      quoted(interaction.GetRequestHost()),
+				Target:      quoted(SystemUnderTestDefaultName),
+				Value:       interaction.response,
+				Timestamp:   interaction.timestamp,
+				Passthrough: interaction.passthrough,
 			})
 		}
 	}
@@ -733,6 +923,14 @@ func (a *APITest) report() *http.Response {
 		Timestamp: a.finished,
 	})
 
+	if a.scenario != nil {
+		return res
+	}
+
+	a.recorder.
+		AddTitle(fmt.Sprintf("%s %s", capturedInboundReq.Method, capturedInboundReq.URL.String())).
+		AddSubTitle(a.name)
+
 	sort.Slice(a.recorder.Events, func(i, j int) bool {
 		return a.recorder.Events[i].GetTime().Before(a.recorder.Events[j].GetTime())
 	})
@@ -778,7 +976,14 @@ func createHash(meta map[string]interface{}) string {
 
 func (r *Response) runTest() *http.Response {
 	a := r.apiTest
-	if len(a.mocks) > 0 {
+	if a.cassette != nil && !a.cassette.recording() {
+		mocks, err := a.cassette.mocks()
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		a.mocks = append(a.mocks, mocks...)
+	}
+	if len(a.mocks) > 0 || a.cassette != nil {
 		a.transport = newTransport(
 			a.mocks,
 			a.httpClient,
@@ -787,8 +992,21 @@ func (r *Response) runTest() *http.Response {
 			a.mocksObservers,
 			r.apiTest,
 		)
-		defer a.transport.Reset()
-		a.transport.Hijack()
+		a.transport.cassette = a.cassette
+		a.transport.passthrough = a.mockPassthrough
+		a.transport.passthroughHosts = a.mockPassthroughHosts
+		if a.parallelMocks && a.httpClient == nil {
+			a.mockRoutingID = newMockRoutingID()
+			mockRoutes.add(a.mockRoutingID, a.transport)
+			defer mockRoutes.remove(a.mockRoutingID)
+		} else {
+			defer a.transport.Reset()
+			a.transport.Hijack()
+		}
+	}
+	for _, server := range a.mockServers {
+		server.attach(a)
+		defer server.detach()
 	}
 	res, req := a.doRequest()
 
@@ -804,21 +1022,69 @@ func (r *Response) runTest() *http.Response {
 		a.verifier = newTestifyVerifier()
 	}
 
+	if a.cassette != nil && a.cassette.recording() {
+		a.verifier.NoError(a.t, a.cassette.save())
+	}
+
 	a.assertMocks()
 	a.assertResponse(res)
+	a.assertJSONSchema(res)
+	a.assertJSONPath(res)
+	a.assertXML(res)
+	a.assertXPath(res)
+	a.assertSnapshot(res)
 	a.assertHeaders(res)
 	a.assertCookies(res)
 	a.assertFunc(res, req)
+	a.assertOpenAPISpec(res, req)
+	a.captureVars(res)
 
 	return copyHttpResponse(res)
 }
 
 func (a *APITest) assertMocks() {
-	for _, mock := range a.mocks {
-		if mock.isUsed == false && mock.timesSet {
-			a.verifier.Fail(a.t, "mock was not invoked expected times")
+	for i, mock := range a.mocks {
+		if mock.timesSet && !mock.satisfied() {
+			a.verifier.Fail(a.t, fmt.Sprintf("%s expected %s calls, received %d", mock.name(i+1), mock.expectedCalls(), mock.calls),
+				mock.request.describe())
+			continue
+		}
+		if a.failOnUnusedMocks && mock.pending() {
+			a.verifier.Fail(a.t, fmt.Sprintf("%s was not invoked", mock.name(i+1)), mock.request.describe())
+		}
+	}
+}
+
+func (a *APITest) assertOpenAPISpec(res *http.Response, req *http.Request) {
+	if a.openAPISpecPath == "" {
+		return
+	}
+	spec, err := loadOpenAPISpec(a.openAPISpecPath)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	op := spec.findOperation(req)
+	if op == nil {
+		a.verifier.Fail(a.t, fmt.Sprintf("no OpenAPI operation matches %s %s", req.Method, req.URL.Path))
+		return
+	}
+	for _, violation := range op.validateRequest(copyHttpRequest(req)) {
+		a.verifier.Fail(a.t, fmt.Sprintf("request does not match OpenAPI operation %s: %s", op.id, violation))
+	}
+	for _, violation := range op.validateResponse(copyHttpResponse(res)) {
+		a.verifier.Fail(a.t, fmt.Sprintf("response does not match OpenAPI operation %s: %s", op.id, violation))
+	}
+}
+
+func (a *APITest) unmatchedMocks() []UnmatchedMock {
+	var unmatchedMocks []UnmatchedMock
+	for i, m := range a.mocks {
+		if m.pending() {
+			unmatchedMocks = append(unmatchedMocks, newUnmatchedMock(i+1, m))
 		}
 	}
+	return unmatchedMocks
 }
 
 func (a *APITest) assertFunc(res *http.Response, req *http.Request) {
@@ -840,7 +1106,7 @@ func (a *APITest) doRequest() (*http.Response, *http.Request) {
 	resRecorder := httptest.NewRecorder()
 
 	if a.debugEnabled {
-		requestDump, err := httputil.DumpRequest(req, true)
+		requestDump, err := dumpRequest(req, false)
 		if err == nil {
 			debugLog(requestDebugPrefix, "inbound http request", string(requestDump))
 		}
@@ -859,7 +1125,7 @@ func (a *APITest) doRequest() (*http.Response, *http.Request) {
 	}
 
 	if a.debugEnabled {
-		responseDump, err := httputil.DumpResponse(res, true)
+		responseDump, err := dumpResponse(res)
 		if err == nil {
 			debugLog(responseDebugPrefix, "final response", string(responseDump))
 		}
@@ -883,13 +1149,40 @@ func (a *APITest) buildRequest() *http.Request {
 		form := url.Values{}
 		for k := range a.request.formData {
 			for _, value := range a.request.formData[k] {
-				form.Add(k, value)
+				form.Add(k, a.interpolate(value))
 			}
 		}
 		a.request.body = form.Encode()
 	}
 
-	req, _ := http.NewRequest(a.request.method, a.request.url, bytes.NewBufferString(a.request.body))
+	var body io.Reader = bytes.NewBufferString(a.interpolate(a.request.body))
+	var multipartContentType string
+	switch {
+	case a.request.bodyReader != nil:
+		body = a.request.bodyReader
+	case a.request.bodyBytes != nil:
+		body = bytes.NewReader(a.request.bodyBytes)
+	case len(a.request.multipart) > 0:
+		parts := make([]multipartPart, len(a.request.multipart))
+		for i, part := range a.request.multipart {
+			part.value = a.interpolate(part.value)
+			parts[i] = part
+		}
+		multipartReader, contentType, err := multipartBody(parts)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		body, multipartContentType = multipartReader, contentType
+	}
+
+	req, _ := http.NewRequest(a.request.method, a.interpolate(a.request.url), body)
+	if a.request.bodyReader != nil {
+		req.ContentLength = -1
+	}
+	if a.request.chunked {
+		req.ContentLength = -1
+		req.TransferEncoding = []string{"chunked"}
+	}
 	req.URL.RawQuery = formatQuery(a.request)
 	req.Host = SystemUnderTestDefaultName
 	if a.networkingEnabled {
@@ -898,12 +1191,18 @@ func (a *APITest) buildRequest() *http.Request {
 
 	for k, v := range a.request.headers {
 		for _, headerValue := range v {
-			req.Header.Add(k, headerValue)
+			req.Header.Add(k, a.interpolate(headerValue))
 		}
 	}
 
+	if multipartContentType != "" {
+		req.Header.Set("Content-Type", multipartContentType)
+	}
+
 	for _, cookie := range a.request.cookies {
-		req.AddCookie(cookie.ToHttpCookie())
+		httpCookie := cookie.ToHttpCookie()
+		httpCookie.Value = a.interpolate(httpCookie.Value)
+		req.AddCookie(httpCookie)
 	}
 
 	if a.request.basicAuth != "" {
@@ -911,6 +1210,10 @@ func (a *APITest) buildRequest() *http.Request {
 		req.SetBasicAuth(parts[0], parts[1])
 	}
 
+	if a.mockRoutingID != "" {
+		req = withMockRoutingID(req, a.mockRoutingID)
+	}
+
 	return req
 }
 
@@ -919,14 +1222,14 @@ func formatQuery(request *Request) string {
 
 	if request.queryCollection != nil {
 		for _, param := range buildQueryCollection(request.queryCollection) {
-			out.Add(param.l, param.r)
+			out.Add(param.l, request.apiTest.interpolate(param.r))
 		}
 	}
 
 	if request.query != nil {
 		for k, v := range request.query {
 			for _, p := range v {
-				out.Add(k, p)
+				out.Add(k, request.apiTest.interpolate(p))
 			}
 		}
 	}
@@ -971,6 +1274,119 @@ func (a *APITest) assertResponse(res *http.Response) {
 	}
 }
 
+func (a *APITest) assertJSONSchema(res *http.Response) {
+	if len(a.response.jsonSchemas) == 0 {
+		return
+	}
+
+	value, ok := a.responseJSON(res)
+	if !ok {
+		return
+	}
+	for _, schema := range a.response.jsonSchemas {
+		for _, err := range schema.validate(value) {
+			a.verifier.Fail(a.t, fmt.Sprintf("response body does not match JSON schema: %s", err))
+		}
+	}
+}
+
+func (a *APITest) assertJSONPath(res *http.Response) {
+	if len(a.response.jsonPaths) == 0 {
+		return
+	}
+
+	value, ok := a.responseJSON(res)
+	if !ok {
+		return
+	}
+	for _, assertion := range a.response.jsonPaths {
+		if err := assertion.assert(value); err != nil {
+			a.verifier.Fail(a.t, err.Error())
+		}
+	}
+}
+
+func (a *APITest) assertXML(res *http.Response) {
+	if a.response.xmlBody == "" {
+		return
+	}
+
+	doc, ok := a.responseXML(res)
+	if !ok {
+		return
+	}
+	a.verifier.Equal(a.t, a.response.xmlBody, doc.canonical())
+}
+
+func (a *APITest) assertXPath(res *http.Response) {
+	if len(a.response.xPaths) == 0 {
+		return
+	}
+
+	doc, ok := a.responseXML(res)
+	if !ok {
+		return
+	}
+	for _, assertion := range a.response.xPaths {
+		if err := assertion.assert(doc); err != nil {
+			a.verifier.Fail(a.t, err.Error())
+		}
+	}
+}
+
+func (a *APITest) assertSnapshot(res *http.Response) {
+	if a.response.snapshot == nil {
+		return
+	}
+
+	actual, err := a.response.snapshot.format(res)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	path, err := snapshotPath(a.t, a.name)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) || updateSnapshots() {
+		a.verifier.NoError(a.t, writeSnapshot(path, actual))
+		return
+	}
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	if string(expected) != actual {
+		a.verifier.Fail(a.t, fmt.Sprintf("response does not match snapshot %s%s", path, diff(string(expected), actual)))
+	}
+}
+
+// responseJSON decodes the response body and fails the test if the body is not valid JSON
+func (a *APITest) responseJSON(res *http.Response) (interface{}, bool) {
+	resBodyBytes, _ := readBody(res.Body, func(replacementBody io.ReadCloser) {
+		res.Body = replacementBody
+	})
+	var value interface{}
+	if err := json.Unmarshal(resBodyBytes, &value); err != nil {
+		a.verifier.Fail(a.t, fmt.Sprintf("response body is not valid JSON: %s", err))
+		return nil, false
+	}
+	return value, true
+}
+
+// responseXML parses the response body and fails the test if the body is not valid XML
+func (a *APITest) responseXML(res *http.Response) (*xmlNode, bool) {
+	resBodyBytes, _ := readBody(res.Body, func(replacementBody io.ReadCloser) {
+		res.Body = replacementBody
+	})
+	doc, err := parseXML(resBodyBytes, false)
+	if err != nil {
+		a.verifier.Fail(a.t, fmt.Sprintf("response body is not valid XML: %s", err))
+		return nil, false
+	}
+	return doc, true
+}
+
 func (a *APITest) assertCookies(response *http.Response) {
 	if len(a.response.cookies) > 0 {
 		for _, expectedCookie := range a.response.cookies {
@@ -1092,6 +1508,9 @@ func copyHttpRequest(request *http.Request) *http.Request {
 		ContentLength: request.ContentLength,
 		RemoteAddr:    request.RemoteAddr,
 	}
+	if request.TransferEncoding != nil {
+		resCopy.TransferEncoding = append([]string(nil), request.TransferEncoding...)
+	}
 	resCopy = resCopy.WithContext(request.Context())
 
 	if request.Body != nil {
@@ -1,13 +1,18 @@
 package apitest_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -557,6 +562,222 @@ func TestApiTest_MatchesBodyFromFile(t *testing.T) {
 		End()
 }
 
+func TestApiTest_JSONSchema(t *testing.T) {
+	apitest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": 1, "name": "jan", "roles": ["admin"]}`))
+		}).
+		Get("/user").
+		Expect(t).
+		JSONSchema(`{"type": "object", "required": ["id"]}`).
+		JSONSchemaFromFile("testdata/user_schema.json").
+		Status(http.StatusOK).
+		End()
+}
+
+func TestApiTest_JSONSchema_ReportsEachError(t *testing.T) {
+	var failures []string
+	verifier := mocks.NewVerifier()
+	verifier.FailFn = func(t apitest.TestingT, failureMessage string, msgAndArgs ...interface{}) bool {
+		failures = append(failures, failureMessage)
+		return true
+	}
+
+	apitest.New().
+		Verifier(verifier).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": "1", "email": "jan", "roles": ["guest"]}`))
+		}).
+		Get("/user").
+		Expect(t).
+		JSONSchemaFromFile("testdata/user_schema.json").
+		End()
+
+	assert.Equal(t, []string{
+		"response body does not match JSON schema: /: missing required property 'name'",
+		`response body does not match JSON schema: /email: value "jan" is not a valid email`,
+		"response body does not match JSON schema: /id: expected integer, got string",
+		`response body does not match JSON schema: /roles/0: value "guest" is not one of ["admin","user"]`,
+	}, failures)
+}
+
+func TestApiTest_JSONPath(t *testing.T) {
+	apitest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": 12345, "name": "jan", "items": [{"color": "red", "size": 2}, {"color": "blue", "size": 4}]}`))
+		}).
+		Get("/user").
+		Expect(t).
+		JSONPath("$.id").Equal(12345).
+		JSONPath("$.items[0]").Equal(map[string]interface{}{"color": "red", "size": 2}).
+		JSONPath("$.items[*].color").Contains("blue").
+		JSONPath("$.name").Contains("ja").
+		JSONPath("$.items").Len(2).
+		JSONPath("$.items[?(@.size > 1)]").GreaterThan(1).
+		JSONPath("$.name").Present().
+		JSONPath("$.email").NotPresent().
+		JSONPath("$.items[?(@.color == 'green')]").NotPresent().
+		JSONPath("$.id").Matches(`^\d{5}$`).
+		JSONPath("$.items[*].size").Each(func(v interface{}) error {
+		if v.(float64) < 1 {
+			return fmt.Errorf("size %v must be positive", v)
+		}
+		return nil
+	}).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestApiTest_JSONPath_ReportsFailures(t *testing.T) {
+	var failures []string
+	verifier := mocks.NewVerifier()
+	verifier.FailFn = func(t apitest.TestingT, failureMessage string, msgAndArgs ...interface{}) bool {
+		failures = append(failures, failureMessage)
+		return true
+	}
+
+	apitest.New().
+		Verifier(verifier).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": 12345, "items": [{"color": "red"}, {"color": "blue"}]}`))
+		}).
+		Get("/user").
+		Expect(t).
+		JSONPath("$.id").Equal(1).
+		JSONPath("$.items[*].color").Contains("green").
+		JSONPath("$.items").Len(3).
+		JSONPath("$.items").GreaterThan(2).
+		JSONPath("$.name").Present().
+		JSONPath("$.id").NotPresent().
+		JSONPath("$.items[0].color").Matches("^b").
+		JSONPath("$.items[*].color").Each(func(v interface{}) error {
+		if v != "red" {
+			return fmt.Errorf("unexpected color %v", v)
+		}
+		return nil
+	}).
+		End()
+
+	assert.Equal(t, []string{
+		"JSONPath $.id: expected 1, got 12345",
+		`JSONPath $.items[*].color: expected ["red","blue"] to contain "green"`,
+		"JSONPath $.items: expected length 3, got 2",
+		"JSONPath $.items: expected length greater than 2, got 2",
+		"JSONPath $.name: expected a value, got nothing",
+		"JSONPath $.id: expected nothing, got 12345",
+		`JSONPath $.items[0].color: expected value matching ^b, got "red"`,
+		"JSONPath $.items[*].color: item 1: unexpected color blue",
+	}, failures)
+}
+
+func TestApiTest_XML(t *testing.T) {
+	type user struct {
+		XMLName xml.Name `xml:"user"`
+		ID      int      `xml:"id,attr"`
+		Name    string   `xml:"name"`
+	}
+
+	apitest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.Header.Get("Content-Type") != "application/xml" || string(body) != `<user id="1"><name>jan</name></user>` {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write([]byte(`<ns:users xmlns:ns="http://example.com/users">
+	<ns:user role="admin" id="1">
+		<ns:name>jan</ns:name>
+	</ns:user>
+</ns:users>`))
+		}).
+		Post("/users").
+		XML(user{ID: 1, Name: "jan"}).
+		Expect(t).
+		XMLEq(`<users xmlns="http://example.com/users"><user id="1" role="admin"><name>jan</name></user></users>`).
+		XPath("/users/user[@id='1']/name").Equal("jan").
+		XPath("//user/@id").Equal(1).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestApiTest_XML_ReportsFailures(t *testing.T) {
+	var failures []string
+	verifier := mocks.NewVerifier()
+	verifier.FailFn = func(t apitest.TestingT, failureMessage string, msgAndArgs ...interface{}) bool {
+		failures = append(failures, failureMessage)
+		return true
+	}
+	verifier.EqualFn = func(t apitest.TestingT, expected, actual interface{}, msgAndArgs ...interface{}) bool {
+		if expected != actual {
+			failures = append(failures, "body mismatch")
+		}
+		return true
+	}
+
+	apitest.New().
+		Verifier(verifier).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`<user id="1"><name>jan</name></user>`))
+		}).
+		Get("/user").
+		Expect(t).
+		XMLEq(`<user id="2"><name>jan</name></user>`).
+		XPath("/user/name").Equal("kim").
+		XPath("/user/email").Equal("jan@example.com").
+		End()
+
+	assert.Equal(t, []string{
+		"body mismatch",
+		`XPath /user/name: expected "kim", got "jan"`,
+		`XPath /user/email: expected "jan@example.com", got nothing`,
+	}, failures)
+}
+
+func TestApiTest_XML_ReportsInvalidBody(t *testing.T) {
+	var failures []string
+	verifier := mocks.NewVerifier()
+	verifier.FailFn = func(t apitest.TestingT, failureMessage string, msgAndArgs ...interface{}) bool {
+		failures = append(failures, failureMessage)
+		return true
+	}
+
+	apitest.New().
+		Verifier(verifier).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"name": "jan"}`))
+		}).
+		Get("/user").
+		Expect(t).
+		XPath("/user/name").Equal("jan").
+		End()
+
+	assert.Equal(t, []string{"response body is not valid XML: unexpected text outside of an element"}, failures)
+}
+
+func TestApiTest_MatchSnapshot(t *testing.T) {
+	apitest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Request-Id", "a1c8e1b2")
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"id": %d, "name": "jan", "createdAt": "%s"}`,
+				time.Now().UnixNano(), time.Now().Format(time.RFC3339))))
+		}).
+		Get("/user").
+		Expect(t).
+		MatchSnapshot(
+			apitest.SnapshotHeaders("Content-Type", "X-Request-Id"),
+			apitest.SnapshotMask("$.id"),
+			apitest.SnapshotReplace(`\d{4}-\d{2}-\d{2}T[0-9:]+(Z|[+-]\d{2}:\d{2})`, "<timestamp>"),
+		).
+		Status(http.StatusOK).
+		End()
+}
+
 func TestApiTest_MatchesJSONResponseBodyWithWhitespace(t *testing.T) {
 	handler := http.NewServeMux()
 	handler.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
@@ -1115,6 +1336,120 @@ func TestApiTest_AddsUrlEncodedFormBody(t *testing.T) {
 		End()
 }
 
+func TestApiTest_BodyBytes(t *testing.T) {
+	payload := []byte{0x0a, 0x03, 0x6a, 0x61, 0x6e, 0x00, 0xff}
+
+	apitest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.Equal(t, payload, body)
+			assert.Equal(t, int64(len(payload)), r.ContentLength)
+			w.WriteHeader(http.StatusOK)
+		}).
+		Post("/users").
+		BodyBytes(payload).
+		ContentType("application/x-protobuf").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestApiTest_BodyReader_Chunked(t *testing.T) {
+	apitest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.Equal(t, strings.Repeat("a", 1024), string(body))
+			assert.Equal(t, int64(-1), r.ContentLength)
+			assert.Equal(t, []string{"chunked"}, r.TransferEncoding)
+			w.WriteHeader(http.StatusOK)
+		}).
+		Post("/upload").
+		BodyReader(strings.NewReader(strings.Repeat("a", 1024))).
+		Chunked().
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestApiTest_AddsMultipartFormData(t *testing.T) {
+	upload := apitest.NewMock().
+		Post("http://example.com/upload").
+		MultipartFormData("name", "J([a-z]+)n").
+		MultipartFile("file", "testdata/multipart/hello.txt").
+		RespondWith().
+		Status(http.StatusCreated).
+		End()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err := r.ParseMultipartForm(1024); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		assert.Equal(t, []string{"John"}, r.MultipartForm.Value["name"])
+		assert.Equal(t, []string{"Jack", "Ann"}, r.MultipartForm.Value["children"])
+		files := r.MultipartForm.File["file"]
+		assert.Len(t, files, 2)
+		assert.Equal(t, "hello.txt", files[0].Filename)
+		assert.Equal(t, "text/plain; charset=utf-8", files[0].Header.Get("Content-Type"))
+		assert.Equal(t, "user.json", files[1].Filename)
+		assert.Equal(t, "application/json", files[1].Header.Get("Content-Type"))
+		f, _ := files[0].Open()
+		content, _ := ioutil.ReadAll(f)
+		assert.Equal(t, "hello world\n", string(content))
+		avatar := r.MultipartForm.File["avatar"]
+		assert.Len(t, avatar, 1)
+		assert.Equal(t, "image/png", avatar[0].Header.Get("Content-Type"))
+
+		res, err := http.Post("http://example.com/upload", r.Header.Get("Content-Type"), bytes.NewReader(body))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(res.StatusCode)
+	})
+
+	apitest.New().
+		Mocks(upload).
+		Handler(handler).
+		Post("/upload").
+		MultipartFormData("name", "John").
+		MultipartFormData("children", "Jack", "Ann").
+		MultipartFile("file", "testdata/multipart/hello.txt", "testdata/multipart/user.json").
+		MultipartFile("avatar", "testdata/multipart/hello.txt").MultipartContentType("image/png").
+		Expect(t).
+		Status(http.StatusCreated).
+		End()
+}
+
+func TestApiTest_MultipartFile_FailsIfFileCannotBeRead(t *testing.T) {
+	captor := &fatalCaptor{T: t}
+
+	apitest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}).
+		Post("/upload").
+		MultipartFile("file", "testdata/multipart").
+		Expect(captor).
+		Status(http.StatusOK).
+		End()
+
+	assert.Len(t, captor.fatal, 1)
+	assert.Contains(t, fmt.Sprint(captor.fatal[0]), "is a directory")
+}
+
+type fatalCaptor struct {
+	*testing.T
+	fatal []interface{}
+}
+
+func (f *fatalCaptor) Fatal(args ...interface{}) {
+	f.fatal = append(f.fatal, args...)
+}
+
 func TestApiTest_ErrorIfMockInvocationsDoNotMatchTimes(t *testing.T) {
 	getUser := apitest.NewMock().
 		Get("http://localhost:8080").
@@ -1125,7 +1460,8 @@ func TestApiTest_ErrorIfMockInvocationsDoNotMatchTimes(t *testing.T) {
 
 	verifier := mocks.NewVerifier()
 	verifier.FailFn = func(t apitest.TestingT, failureMessage string, msgAndArgs ...interface{}) bool {
-		assert.Equal(t, "mock was not invoked expected times", failureMessage)
+		assert.Equal(t, "mock 1 expected 2 calls, received 1", failureMessage)
+		assert.Equal(t, []interface{}{[]interface{}{"GET http://localhost:8080"}}, msgAndArgs)
 		return true
 	}
 
@@ -1168,6 +1504,434 @@ func TestApiTest_MatchesTimes(t *testing.T) {
 	assert.Empty(t, res.UnmatchedMocks())
 }
 
+func TestApiTest_ReportsAllUnmatchedMocks(t *testing.T) {
+	getUser := apitest.NewMock().
+		Get("http://localhost:8080").
+		RespondWith().
+		Status(http.StatusOK).
+		End()
+	getPreferences := apitest.NewMock().
+		Label("get preferences").
+		Get("http://localhost:8080/preferences").
+		Header("Authorization", "Bearer 123").
+		RespondWith().
+		Status(http.StatusOK).
+		End()
+	postUser := apitest.NewMock().
+		Post("http://localhost:8080/user").
+		Query("notify", "true").
+		Body(`{"name": "jon"}`).
+		RespondWith().
+		Status(http.StatusCreated).
+		End()
+
+	res := apitest.New().
+		Mocks(getUser, getPreferences, postUser).
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = getUserData()
+			w.WriteHeader(http.StatusOK)
+		})).
+		Get("/").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	unmatchedMocks := res.UnmatchedMocks()
+	assert.Len(t, unmatchedMocks, 2)
+	assert.Equal(t, 2, unmatchedMocks[0].Index)
+	assert.Equal(t, "get preferences", unmatchedMocks[0].Label)
+	assert.Equal(t, http.MethodGet, unmatchedMocks[0].Method)
+	assert.Equal(t, "http://localhost:8080/preferences", unmatchedMocks[0].URL.String())
+	assert.Equal(t, map[string][]string{"Authorization": {"Bearer 123"}}, unmatchedMocks[0].Headers)
+	assert.Equal(t, 3, unmatchedMocks[1].Index)
+	assert.Equal(t, http.MethodPost, unmatchedMocks[1].Method)
+	assert.Equal(t, map[string][]string{"notify": {"true"}}, unmatchedMocks[1].Query)
+	assert.Equal(t, `{"name": "jon"}`, unmatchedMocks[1].Body)
+}
+
+func TestApiTest_FailOnUnusedMocks(t *testing.T) {
+	getUser := apitest.NewMock().
+		Get("http://localhost:8080").
+		RespondWith().
+		Status(http.StatusOK).
+		End()
+	getPreferences := apitest.NewMock().
+		Label("get preferences").
+		Get("http://localhost:8080/preferences").
+		RespondWith().
+		Status(http.StatusOK).
+		End()
+	getContacts := apitest.NewMock().
+		Get("http://localhost:8080/contacts").
+		RespondWith().
+		Status(http.StatusOK).
+		AnyTimes().
+		End()
+
+	var failures [][]interface{}
+	verifier := mocks.NewVerifier()
+	verifier.FailFn = func(t apitest.TestingT, failureMessage string, msgAndArgs ...interface{}) bool {
+		failures = append(failures, []interface{}{failureMessage, msgAndArgs})
+		return true
+	}
+
+	apitest.New().
+		Mocks(getUser, getPreferences, getContacts).
+		FailOnUnusedMocks().
+		Verifier(verifier).
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = getUserData()
+			w.WriteHeader(http.StatusOK)
+		})).
+		Get("/").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	assert.Equal(t, [][]interface{}{
+		{"mock 2 (get preferences) was not invoked", []interface{}{[]interface{}{"GET http://localhost:8080/preferences"}}},
+	}, failures)
+}
+
+func TestApiTest_MockTimes_RespondsTheGivenNumberOfTimes(t *testing.T) {
+	getUser := apitest.NewMock().
+		Get("http://localhost:8080").
+		RespondWith().
+		Status(http.StatusOK).
+		Body("1").
+		Times(3).
+		End()
+
+	apitest.New().
+		Mocks(getUser).
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i < 3; i++ {
+				_, _ = w.Write(getUserData())
+			}
+		})).
+		Get("/").
+		Expect(t).
+		Status(http.StatusOK).
+		Body("111").
+		End()
+}
+
+func TestApiTest_MockTimes_FailsIfInvokedTooOften(t *testing.T) {
+	tests := map[string]struct {
+		mock            *apitest.MockResponse
+		invocations     int
+		expectedMessage string
+	}{
+		"never": {
+			mock:            apitest.NewMock().Get("http://localhost:8080/user").RespondWith().Never(),
+			invocations:     1,
+			expectedMessage: "mock 1 expected 0 calls, received 1",
+		},
+		"at most": {
+			mock:            apitest.NewMock().Get("http://localhost:8080/user").RespondWith().AtMost(2),
+			invocations:     3,
+			expectedMessage: "mock 1 expected at most 2 calls, received 3",
+		},
+		"at least": {
+			mock:            apitest.NewMock().Get("http://localhost:8080/user").RespondWith().AtLeast(2),
+			invocations:     1,
+			expectedMessage: "mock 1 expected at least 2 calls, received 1",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var failureMessages []string
+			verifier := mocks.NewVerifier()
+			verifier.FailFn = func(t apitest.TestingT, failureMessage string, msgAndArgs ...interface{}) bool {
+				failureMessages = append(failureMessages, failureMessage)
+				return true
+			}
+
+			apitest.New().
+				Mocks(test.mock.Status(http.StatusOK).End()).
+				Verifier(verifier).
+				Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					for i := 0; i < test.invocations; i++ {
+						res, err := http.Get("http://localhost:8080/user")
+						if err == nil {
+							_ = res.Body.Close()
+						}
+					}
+					w.WriteHeader(http.StatusOK)
+				})).
+				Get("/").
+				Expect(t).
+				Status(http.StatusOK).
+				End()
+
+			assert.Equal(t, []string{test.expectedMessage}, failureMessages)
+		})
+	}
+}
+
+func TestApiTest_MockAnyTimes(t *testing.T) {
+	getUser := apitest.NewMock().
+		Get("http://localhost:8080").
+		RespondWith().
+		Status(http.StatusOK).
+		AnyTimes().
+		End()
+
+	for _, invocations := range []int{0, 1, 5} {
+		apitest.New().
+			Mocks(getUser).
+			Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < invocations; i++ {
+					getUserData()
+				}
+				w.WriteHeader(http.StatusOK)
+			})).
+			Get("/").
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+	}
+}
+
+func TestApiTest_MockScenario_RespondsBasedOnState(t *testing.T) {
+	unavailable := apitest.NewMock().
+		InScenario("retry").
+		WhenState(apitest.MockStateStarted).
+		WillSetState("failed once").
+		Get("http://localhost:8080/user").
+		RespondWith().
+		Status(http.StatusServiceUnavailable).
+		End()
+	unavailableAgain := apitest.NewMock().
+		InScenario("retry").
+		WhenState("failed once").
+		WillSetState("failed twice").
+		Get("http://localhost:8080/user").
+		RespondWith().
+		Status(http.StatusServiceUnavailable).
+		End()
+	available := apitest.NewMock().
+		InScenario("retry").
+		WhenState("failed twice").
+		Get("http://localhost:8080/user").
+		RespondWith().
+		Status(http.StatusOK).
+		End()
+
+	// the scenario state is reset for each test run
+	for i := 0; i < 2; i++ {
+		apitest.New().
+			Mocks(available, unavailableAgain, unavailable).
+			Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var statuses []string
+				for i := 0; i < 3; i++ {
+					res, err := http.Get("http://localhost:8080/user")
+					if err != nil {
+						panic(err)
+					}
+					statuses = append(statuses, strconv.Itoa(res.StatusCode))
+				}
+				_, _ = w.Write([]byte(strings.Join(statuses, ",")))
+			})).
+			Get("/").
+			Expect(t).
+			Status(http.StatusOK).
+			Body("503,503,200").
+			End()
+	}
+}
+
+func TestApiTest_MockScenario_ChangesResponseAfterRequest(t *testing.T) {
+	getOrderCreated := apitest.NewMock().
+		InScenario("order").
+		WhenState(apitest.MockStateStarted).
+		Get("http://localhost:8080/order").
+		RespondWith().
+		Body(`{"status": "created"}`).
+		Status(http.StatusOK).
+		End()
+	payOrder := apitest.NewMock().
+		InScenario("order").
+		WillSetState("paid").
+		Post("http://localhost:8080/order/pay").
+		RespondWith().
+		Status(http.StatusNoContent).
+		End()
+	getOrderPaid := apitest.NewMock().
+		InScenario("order").
+		WhenState("paid").
+		Get("http://localhost:8080/order").
+		RespondWith().
+		Body(`{"status": "paid"}`).
+		Status(http.StatusOK).
+		End()
+
+	apitest.New().
+		Mocks(getOrderCreated, payOrder, getOrderPaid).
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, call := range []func() (*http.Response, error){
+				func() (*http.Response, error) { return http.Get("http://localhost:8080/order") },
+				func() (*http.Response, error) { return http.Post("http://localhost:8080/order/pay", "", nil) },
+				func() (*http.Response, error) { return http.Get("http://localhost:8080/order") },
+			} {
+				res, err := call()
+				if err != nil {
+					panic(err)
+				}
+				data, _ := ioutil.ReadAll(res.Body)
+				_, _ = w.Write(data)
+			}
+		})).
+		Get("/").
+		Expect(t).
+		Status(http.StatusOK).
+		Body(`{"status": "created"}{"status": "paid"}`).
+		End()
+}
+
+func TestApiTest_MockResponseSequence(t *testing.T) {
+	getUser := apitest.NewMock().
+		Get("http://localhost:8080/user").
+		RespondWith().
+		Timeout().
+		ThenRespondWith().
+		Status(http.StatusInternalServerError).
+		ThenRespondWith().
+		Status(http.StatusOK).
+		Body(`{"name": "jon"}`).
+		End()
+
+	apitest.New().
+		Mocks(getUser).
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var outcomes []string
+			for i := 0; i < 3; i++ {
+				res, err := http.Get("http://localhost:8080/user")
+				if err != nil {
+					outcomes = append(outcomes, "timeout")
+					continue
+				}
+				data, _ := ioutil.ReadAll(res.Body)
+				outcomes = append(outcomes, fmt.Sprintf("%d%s", res.StatusCode, data))
+			}
+			_, _ = w.Write([]byte(strings.Join(outcomes, ",")))
+		})).
+		Get("/").
+		Expect(t).
+		Status(http.StatusOK).
+		Body(`timeout,500,200{"name": "jon"}`).
+		End()
+}
+
+func TestApiTest_MockPassthrough(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("real"))
+	}))
+	defer srv.Close()
+	reporter := &RecorderCaptor{}
+
+	apitest.New().
+		Report(reporter).
+		MockPassthrough(strings.TrimPrefix(srv.URL, "http://")).
+		Mocks(apitest.NewMock().
+			Get(srv.URL + "/user").
+			RespondWith().
+			Body("mock").
+			Status(http.StatusOK).
+			End()).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, path := range []string{"/user", "/order"} {
+				res, err := http.Get(srv.URL + path)
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				data, _ := ioutil.ReadAll(res.Body)
+				_, _ = w.Write(data)
+			}
+		}).
+		Get("/").
+		Expect(t).
+		Status(http.StatusOK).
+		Body("mockreal").
+		End()
+
+	events := reporter.capturedRecorder.Events
+	assert.Len(t, events, 6)
+	assert.False(t, events[1].(apitest.HttpRequest).Passthrough)
+	assert.False(t, events[2].(apitest.HttpResponse).Passthrough)
+	assert.True(t, events[3].(apitest.HttpRequest).Passthrough)
+	assert.True(t, events[4].(apitest.HttpResponse).Passthrough)
+	assert.Equal(t, "/order", events[3].(apitest.HttpRequest).Value.URL.Path)
+}
+
+func TestApiTest_MockPassthrough_OnlyAllowsGivenHosts(t *testing.T) {
+	apitest.New().
+		MockPassthrough("localhost:9999").
+		Mocks(apitest.NewMock().
+			Get("http://localhost:8080/user").
+			RespondWith().
+			Status(http.StatusOK).
+			End()).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := http.Get("http://localhost:8080/order")
+			if err == nil || !strings.Contains(err.Error(), "received request did not match any mocks") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_, _ = http.Get("http://localhost:8080/user")
+			w.WriteHeader(http.StatusOK)
+		}).
+		Get("/").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestApiTest_MockPassthrough_WithParallelMocks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("real" + r.Header.Get("X-Apitest-Id")))
+	}))
+	defer srv.Close()
+
+	for i := 0; i < 2; i++ {
+		reporter := &RecorderCaptor{}
+
+		apitest.New().
+			Report(reporter).
+			ParallelMocks().
+			MockPassthrough(strings.TrimPrefix(srv.URL, "http://")).
+			Mocks(apitest.NewMock().
+				Get(srv.URL + "/user").
+				RespondWith().
+				Body("mock").
+				Status(http.StatusOK).
+				End()).
+			HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, path := range []string{"/user", "/order"} {
+					req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, srv.URL+path, nil)
+					res, err := http.DefaultClient.Do(req)
+					if err != nil {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					data, _ := ioutil.ReadAll(res.Body)
+					_, _ = w.Write(data)
+				}
+			}).
+			Get("/").
+			Expect(t).
+			Status(http.StatusOK).
+			Body("mockreal").
+			End()
+
+		events := reporter.capturedRecorder.Events
+		assert.Len(t, events, 6)
+		assert.True(t, events[3].(apitest.HttpRequest).Passthrough)
+		assert.True(t, events[4].(apitest.HttpResponse).Passthrough)
+	}
+}
+
 type RecorderCaptor struct {
 	capturedRecorder apitest.Recorder
 }
@@ -1187,3 +1951,89 @@ func getUserData() []byte {
 	}
 	return data
 }
+
+func TestApiTest_OpenAPISpec(t *testing.T) {
+	apitest.New().
+		OpenAPISpec("testdata/openapi/users.yaml").
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": 1, "name": "jan", "email": "jan@example.com"}`))
+		}).
+		Get("/v1/users/1").
+		Header("X-Request-Id", "3fa85f64-5717-4562-b3fc-2c963f66afa6").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestApiTest_OpenAPISpec_ReportsViolations(t *testing.T) {
+	var failures []string
+	verifier := mocks.NewVerifier()
+	verifier.FailFn = func(t apitest.TestingT, failureMessage string, msgAndArgs ...interface{}) bool {
+		failures = append(failures, failureMessage)
+		return true
+	}
+
+	apitest.New().
+		OpenAPISpec("testdata/openapi/users.yaml").
+		Verifier(verifier).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": "1", "email": "jan"}`))
+		}).
+		Get("/users/1").
+		Expect(t).
+		End()
+
+	assert.Equal(t, []string{
+		"request does not match OpenAPI operation getUser: missing required header parameter 'X-Request-Id'",
+		"response does not match OpenAPI operation getUser: response body /: missing required property 'name'",
+		`response does not match OpenAPI operation getUser: response body /email: value "jan" is not a valid email`,
+		"response does not match OpenAPI operation getUser: response body /id: expected integer, got string",
+	}, failures)
+}
+
+func TestApiTest_OpenAPISpec_ReportsUndeclaredResponses(t *testing.T) {
+	tests := map[string]struct {
+		method          string
+		path            string
+		status          int
+		expectedFailure string
+	}{
+		"undeclared status": {
+			method:          http.MethodDelete,
+			path:            "/v1/users/1",
+			status:          http.StatusInternalServerError,
+			expectedFailure: "response does not match OpenAPI operation DELETE /users/{id}: response status 500 is not declared",
+		},
+		"undeclared operation": {
+			method:          http.MethodPut,
+			path:            "/v1/users/1",
+			status:          http.StatusOK,
+			expectedFailure: "no OpenAPI operation matches PUT /v1/users/1",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var failures []string
+			verifier := mocks.NewVerifier()
+			verifier.FailFn = func(t apitest.TestingT, failureMessage string, msgAndArgs ...interface{}) bool {
+				failures = append(failures, failureMessage)
+				return true
+			}
+
+			apitest.New().
+				OpenAPISpec("testdata/openapi/users.yaml").
+				Verifier(verifier).
+				HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(test.status)
+				}).
+				Method(test.method).
+				URL(test.path).
+				Expect(t).
+				End()
+
+			assert.Equal(t, []string{test.expectedFailure}, failures)
+		})
+	}
+}
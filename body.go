@@ -0,0 +1,121 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// binarySummaryBytes is the number of bytes of a binary body shown in reports and debug output
+const binarySummaryBytes = 64
+
+// BodyFormatter formats a request or response body shown in reports. ok is false if the formatter does not handle the
+// body, e.g. because of its content type
+type BodyFormatter func(header http.Header, body []byte) (formatted string, ok bool)
+
+var (
+	bodyFormattersMu sync.RWMutex
+	bodyFormatters   []BodyFormatter
+)
+
+// RegisterBodyFormatter registers a formatter for bodies in reports, e.g. to decode binary payloads. Registered
+// formatters are tried in order before bodies are formatted as JSON, XML or a binary summary
+func RegisterBodyFormatter(formatter BodyFormatter) {
+	bodyFormattersMu.Lock()
+	defer bodyFormattersMu.Unlock()
+	bodyFormatters = append(bodyFormatters, formatter)
+}
+
+func formatRegisteredBody(header http.Header, body []byte) (string, bool) {
+	bodyFormattersMu.RLock()
+	defer bodyFormattersMu.RUnlock()
+	for _, formatter := range bodyFormatters {
+		if formatted, ok := formatter(header, body); ok {
+			return formatted, true
+		}
+	}
+	return "", false
+}
+
+// isBinary reports whether the body is not text, i.e. it is not valid UTF-8 or contains control characters
+func isBinary(body []byte) bool {
+	if !utf8.Valid(body) {
+		return true
+	}
+	for _, r := range string(body) {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' && r != '\f' {
+			return true
+		}
+	}
+	return false
+}
+
+// binarySummary describes a binary body by its size and a hex dump of its first bytes
+func binarySummary(body []byte) string {
+	summary := fmt.Sprintf("binary body of %d bytes\n", len(body))
+	if len(body) > binarySummaryBytes {
+		return summary + hex.Dump(body[:binarySummaryBytes]) + "...\n"
+	}
+	return summary + hex.Dump(body)
+}
+
+// readBody reads the body and replaces it so that it can be read again
+func readBody(body io.ReadCloser, replaceBody func(replacementBody io.ReadCloser)) ([]byte, error) {
+	if body == nil || body == http.NoBody {
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	replaceBody(ioutil.NopCloser(bytes.NewReader(data)))
+	return data, nil
+}
+
+// dumpRequest returns the wire representation of the request for debug output, with a summary of binary bodies.
+// out dumps the request as sent by a client, see httputil.DumpRequestOut
+func dumpRequest(req *http.Request, out bool) ([]byte, error) {
+	dump := httputil.DumpRequest
+	if out {
+		dump = httputil.DumpRequestOut
+	}
+	body, err := readBody(req.Body, func(replacementBody io.ReadCloser) {
+		req.Body = replacementBody
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !isBinary(body) {
+		return dump(req, true)
+	}
+	header, err := dump(req, false)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, binarySummary(body)...), nil
+}
+
+// dumpResponse returns the wire representation of the response for debug output, with a summary of binary bodies
+func dumpResponse(res *http.Response) ([]byte, error) {
+	body, err := readBody(res.Body, func(replacementBody io.ReadCloser) {
+		res.Body = replacementBody
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !isBinary(body) {
+		return httputil.DumpResponse(res, true)
+	}
+	header, err := httputil.DumpResponse(res, false)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, binarySummary(body)...), nil
+}
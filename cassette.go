@@ -0,0 +1,262 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CassetteMode defines whether a cassette records real http interactions or replays recorded interactions
+type CassetteMode string
+
+const (
+	// CassetteReplay replays the interactions of the cassette file as mocks
+	CassetteReplay CassetteMode = "replay"
+	// CassetteRecord sends requests that do not match any mock to the network and saves the interactions to the cassette file
+	CassetteRecord CassetteMode = "record"
+)
+
+// CassetteModeEnv is the environment variable used to switch the mode of all cassettes, e.g. APITEST_CASSETTE_MODE=record
+const CassetteModeEnv = "APITEST_CASSETTE_MODE"
+
+// RedactedValue replaces the values of redacted headers in cassette files
+const RedactedValue = "REDACTED"
+
+// Cassette records the http interactions of the application under test to a file and replays them as mocks.
+// The file is JSON unless the file name ends in .yaml or .yml
+type Cassette struct {
+	path          string
+	mode          CassetteMode
+	matchBody     bool
+	matchHeaders  []string
+	redactHeaders []string
+	m             sync.Mutex
+	interactions  []cassetteInteraction
+}
+
+type cassetteFile struct {
+	Interactions []cassetteInteraction `json:"interactions" yaml:"interactions"`
+}
+
+type cassetteInteraction struct {
+	Request  cassetteRequest  `json:"request" yaml:"request"`
+	Response cassetteResponse `json:"response" yaml:"response"`
+}
+
+type cassetteRequest struct {
+	Method  string              `json:"method" yaml:"method"`
+	URL     string              `json:"url" yaml:"url"`
+	Headers map[string][]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body    string              `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+type cassetteResponse struct {
+	Status  int                 `json:"status" yaml:"status"`
+	Headers map[string][]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body    string              `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// NewCassette creates a cassette stored at the given path. The mode is read from CassetteModeEnv and defaults to CassetteReplay.
+// Recorded requests are matched using the method and url. The Authorization and Proxy-Authorization headers are redacted
+func NewCassette(path string) *Cassette {
+	mode := CassetteReplay
+	if CassetteMode(os.Getenv(CassetteModeEnv)) == CassetteRecord {
+		mode = CassetteRecord
+	}
+	return &Cassette{
+		path:          path,
+		mode:          mode,
+		redactHeaders: []string{"Authorization", "Proxy-Authorization"},
+	}
+}
+
+// Mode sets the mode of the cassette, overriding CassetteModeEnv
+func (c *Cassette) Mode(mode CassetteMode) *Cassette {
+	c.mode = mode
+	return c
+}
+
+// MatchBody configures recorded requests to also match on the request body
+func (c *Cassette) MatchBody() *Cassette {
+	c.matchBody = true
+	return c
+}
+
+// MatchHeaders configures recorded requests to also match on the given headers
+func (c *Cassette) MatchHeaders(headers ...string) *Cassette {
+	for _, header := range headers {
+		c.matchHeaders = append(c.matchHeaders, textproto.CanonicalMIMEHeaderKey(header))
+	}
+	return c
+}
+
+// Redact replaces the values of the given headers with RedactedValue before the cassette is written
+func (c *Cassette) Redact(headers ...string) *Cassette {
+	c.redactHeaders = append(c.redactHeaders, headers...)
+	return c
+}
+
+func (c *Cassette) recording() bool {
+	return c.mode == CassetteRecord
+}
+
+// mocks loads the cassette file and creates a mock for each recorded interaction
+func (c *Cassette) mocks() ([]*Mock, error) {
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file cassetteFile
+	if c.isYAML() {
+		err = yaml.Unmarshal(data, &file)
+	} else {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var mocks []*Mock
+	for _, interaction := range file.Interactions {
+		mocks = append(mocks, c.newMock(interaction))
+	}
+	return mocks, nil
+}
+
+func (c *Cassette) newMock(interaction cassetteInteraction) *Mock {
+	mock := NewMock()
+	req := mock.Method(interaction.Request.Method)
+	mock.parseUrl(interaction.Request.URL)
+	mock.request.url.Path = exactly(mock.request.url.Path)
+
+	for key, values := range mock.request.url.Query() {
+		for _, value := range values {
+			req.Query(key, exactly(value))
+		}
+	}
+
+	if c.matchBody && interaction.Request.Body != "" {
+		req.Body(interaction.Request.Body)
+	}
+
+	for _, header := range c.matchHeaders {
+		for _, value := range interaction.Request.Headers[header] {
+			if value != RedactedValue {
+				req.Header(header, exactly(value))
+			}
+		}
+	}
+
+	res := req.RespondWith().Status(interaction.Response.Status).Body(interaction.Response.Body)
+	for key, values := range interaction.Response.Headers {
+		if key == "Content-Length" || key == "Transfer-Encoding" {
+			continue
+		}
+		for _, value := range values {
+			res.Header(key, value)
+		}
+	}
+	return res.End()
+}
+
+// record sends the request to the network and adds the interaction to the cassette
+func (c *Cassette) record(transport http.RoundTripper, req *http.Request) (*http.Response, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	recordedReq := copyHttpRequest(req)
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var reqBody []byte
+	if recordedReq.Body != nil {
+		reqBody, _ = ioutil.ReadAll(recordedReq.Body)
+	}
+	resBody, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.interactions = append(c.interactions, cassetteInteraction{
+		Request: cassetteRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: c.redact(recordedReq.Header),
+			Body:    string(reqBody),
+		},
+		Response: cassetteResponse{
+			Status:  res.StatusCode,
+			Headers: c.redact(res.Header),
+			Body:    string(resBody),
+		},
+	})
+	return res, nil
+}
+
+func (c *Cassette) redact(header http.Header) map[string][]string {
+	if len(header) == 0 {
+		return nil
+	}
+	redacted := map[string][]string{}
+	for key, values := range header {
+		redacted[key] = append([]string(nil), values...)
+	}
+	for _, key := range c.redactHeaders {
+		key = textproto.CanonicalMIMEHeaderKey(key)
+		if values, ok := redacted[key]; ok {
+			for i := range values {
+				values[i] = RedactedValue
+			}
+		}
+	}
+	return redacted
+}
+
+// save writes the recorded interactions to the cassette file
+func (c *Cassette) save() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	file := cassetteFile{Interactions: c.interactions}
+	var data []byte
+	var err error
+	if c.isYAML() {
+		data, err = yaml.Marshal(file)
+	} else {
+		data, err = json.MarshalIndent(file, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, data, 0644)
+}
+
+func (c *Cassette) isYAML() bool {
+	return strings.HasSuffix(c.path, ".yaml") || strings.HasSuffix(c.path, ".yml")
+}
+
+// exactly returns a regular expression that only matches the given value
+func exactly(value string) string {
+	return "^" + regexp.QuoteMeta(value) + "$"
+}
@@ -0,0 +1,179 @@
+package apitest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCassette_Record(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": "1234"}`))
+	}))
+	defer srv.Close()
+
+	for _, file := range []string{"user.json", "user.yaml"} {
+		t.Run(file, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "cassette")
+			assert.NoError(t, err)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "cassettes", file)
+
+			apitest.New().
+				Cassette(apitest.NewCassette(path).Mode(apitest.CassetteRecord).Redact("X-Api-Key")).
+				HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					req, _ := http.NewRequest(http.MethodPost, srv.URL+"/user?page=1", strings.NewReader(`{"name": "jon"}`))
+					req.Header.Set("Authorization", "Bearer abc")
+					req.Header.Set("X-Api-Key", "secret")
+					req.Header.Set("Accept", "application/json")
+					res, err := http.DefaultClient.Do(req)
+					if err != nil {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					data, _ := ioutil.ReadAll(res.Body)
+					_, _ = w.Write(data)
+				}).
+				Get("/").
+				Expect(t).
+				Status(http.StatusOK).
+				Body(`{"id": "1234"}`).
+				End()
+
+			data, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			cassette := string(data)
+			assert.Contains(t, cassette, srv.URL+"/user?page=1")
+			assert.Contains(t, cassette, "POST")
+			assert.Contains(t, cassette, "application/json")
+			assert.Contains(t, cassette, "1234")
+			assert.Contains(t, cassette, apitest.RedactedValue)
+			assert.NotContains(t, cassette, "Bearer abc")
+			assert.NotContains(t, cassette, "secret")
+		})
+	}
+}
+
+func TestCassette_RecordThenReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "user.json")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Query().Get("id")))
+	}))
+	handler := func(url string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			for _, id := range []string{"1", "2"} {
+				res, err := http.Get(fmt.Sprintf("%s/user?id=%s", url, id))
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				data, _ := ioutil.ReadAll(res.Body)
+				_, _ = w.Write(data)
+			}
+		}
+	}
+
+	apitest.New().
+		Cassette(apitest.NewCassette(path).Mode(apitest.CassetteRecord)).
+		Handler(handler(srv.URL)).
+		Get("/").
+		Expect(t).
+		Body("12").
+		End()
+
+	// the server is no longer available when the cassette is replayed
+	srv.Close()
+
+	apitest.New().
+		Cassette(apitest.NewCassette(path)).
+		FailOnUnusedMocks().
+		Handler(handler(srv.URL)).
+		Get("/").
+		Expect(t).
+		Body("12").
+		End()
+
+	var file map[string][]interface{}
+	data, _ := ioutil.ReadFile(path)
+	assert.NoError(t, json.Unmarshal(data, &file))
+	assert.Len(t, file["interactions"], 2)
+}
+
+func TestCassette_Replay_MatchBody(t *testing.T) {
+	apitest.New().
+		Cassette(apitest.NewCassette("testdata/cassettes/get_user.yaml").MatchBody()).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var statuses []string
+			for _, name := range []string{"jon", "jan"} {
+				res, err := http.Post("http://localhost:8080/user", "application/json", strings.NewReader(fmt.Sprintf(`{"name": "%s"}`, name)))
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				statuses = append(statuses, fmt.Sprint(res.StatusCode))
+			}
+			_, _ = w.Write([]byte(strings.Join(statuses, ",")))
+		}).
+		Get("/").
+		Expect(t).
+		Body("409,201").
+		End()
+}
+
+func TestCassette_Replay_MatchesExactPath(t *testing.T) {
+	apitest.New().
+		Cassette(apitest.NewCassette("testdata/cassettes/get_user.yaml")).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := http.Get("http://localhost:8080/user/orders?id=1234")
+			if err == nil || !strings.Contains(err.Error(), "received request did not match any mocks") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}).
+		Get("/").
+		Expect(t).
+		Status(http.StatusNotFound).
+		End()
+}
+
+func TestCassette_Replay_ModeFromEnv(t *testing.T) {
+	assert.NoError(t, os.Setenv(apitest.CassetteModeEnv, string(apitest.CassetteReplay)))
+	defer os.Unsetenv(apitest.CassetteModeEnv)
+
+	apitest.New().
+		Cassette(apitest.NewCassette("testdata/cassettes/get_user.yaml").MatchHeaders("Authorization")).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost:8080/user?id=1234", nil)
+			req.Header.Set("Authorization", "Bearer abc")
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", res.Header.Get("Content-Type"))
+			data, _ := ioutil.ReadAll(res.Body)
+			_, _ = w.Write(data)
+		}).
+		Get("/").
+		Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "application/json").
+		Body(`{"id": "1234", "name": "jon"}`).
+		End()
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	htmlTemplate "html/template"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
 	"os"
@@ -68,6 +67,14 @@ func (r *webSequenceDiagramDSL) addResponseRow(source string, target string, des
 	r.addRow("->>", source, target, description)
 }
 
+func (r *webSequenceDiagramDSL) addPassthroughRequestRow(source string, target string, description string) {
+	r.addRow("-->", source, target, description)
+}
+
+func (r *webSequenceDiagramDSL) addPassthroughResponseRow(source string, target string, description string) {
+	r.addRow("-->>", source, target, description)
+}
+
 func (r *webSequenceDiagramDSL) addRow(operation, source string, target string, description string) {
 	r.count++
 	r.data.WriteString(fmt.Sprintf("%s%s%s: (%d) %s\n",
@@ -171,7 +178,11 @@ func newHTMLTemplateModel(r *Recorder) (htmlTemplateModel, error) {
 		switch v := event.(type) {
 		case HttpRequest:
 			httpReq := v.Value
-			webSequenceDiagram.addRequestRow(v.Source, v.Target, formatDiagramRequest(httpReq))
+			if v.Passthrough {
+				webSequenceDiagram.addPassthroughRequestRow(v.Source, v.Target, formatDiagramRequest(httpReq))
+			} else {
+				webSequenceDiagram.addRequestRow(v.Source, v.Target, formatDiagramRequest(httpReq))
+			}
 			entry, err := newHTTPRequestLogEntry(httpReq)
 			if err != nil {
 				return htmlTemplateModel{}, err
@@ -179,7 +190,11 @@ func newHTMLTemplateModel(r *Recorder) (htmlTemplateModel, error) {
 			entry.Timestamp = v.Timestamp
 			logs = append(logs, entry)
 		case HttpResponse:
-			webSequenceDiagram.addResponseRow(v.Source, v.Target, strconv.Itoa(v.Value.StatusCode))
+			if v.Passthrough {
+				webSequenceDiagram.addPassthroughResponseRow(v.Source, v.Target, strconv.Itoa(v.Value.StatusCode))
+			} else {
+				webSequenceDiagram.addResponseRow(v.Source, v.Target, strconv.Itoa(v.Value.StatusCode))
+			}
 			entry, err := newHTTPResponseLogEntry(v.Value)
 			if err != nil {
 				return htmlTemplateModel{}, err
@@ -223,7 +238,7 @@ func newHTTPRequestLogEntry(req *http.Request) (logEntry, error) {
 	if err != nil {
 		return logEntry{}, err
 	}
-	body, err := formatBodyContent(req.Body, func(replacementBody io.ReadCloser) {
+	body, err := formatBodyContent(req.Header, req.Body, func(replacementBody io.ReadCloser) {
 		req.Body = replacementBody
 	})
 	if err != nil {
@@ -237,7 +252,7 @@ func newHTTPResponseLogEntry(res *http.Response) (logEntry, error) {
 	if err != nil {
 		return logEntry{}, err
 	}
-	body, err := formatBodyContent(res.Body, func(replacementBody io.ReadCloser) {
+	body, err := formatBodyContent(res.Header, res.Body, func(replacementBody io.ReadCloser) {
 		res.Body = replacementBody
 	})
 	if err != nil {
@@ -246,17 +261,17 @@ func newHTTPResponseLogEntry(res *http.Response) (logEntry, error) {
 	return logEntry{Header: string(resDump), Body: body}, err
 }
 
-func formatBodyContent(bodyReadCloser io.ReadCloser, replaceBody func(replacementBody io.ReadCloser)) (string, error) {
-	if bodyReadCloser == nil {
-		return "", nil
-	}
-
-	body, err := ioutil.ReadAll(bodyReadCloser)
+func formatBodyContent(header http.Header, bodyReadCloser io.ReadCloser, replaceBody func(replacementBody io.ReadCloser)) (string, error) {
+	body, err := readBody(bodyReadCloser, replaceBody)
 	if err != nil {
 		return "", err
 	}
-
-	replaceBody(ioutil.NopCloser(bytes.NewReader(body)))
+	if formatted, ok := formatRegisteredBody(header, body); ok {
+		return formatted, nil
+	}
+	if isBinary(body) {
+		return binarySummary(body), nil
+	}
 
 	buf := new(bytes.Buffer)
 	if json.Valid(body) {
@@ -268,6 +283,10 @@ func formatBodyContent(bodyReadCloser io.ReadCloser, replaceBody func(replacemen
 		return s, nil
 	}
 
+	if s, err := indentXML(body); err == nil {
+		return s, nil
+	}
+
 	_, err = buf.Write(body)
 	if err != nil {
 		return "", err
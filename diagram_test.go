@@ -1,6 +1,7 @@
 package apitest
 
 import (
+	"bytes"
 	"html/template"
 	"io"
 	"io/ioutil"
@@ -49,19 +50,76 @@ func TestDiagram_BadgeCSSClass(t *testing.T) {
 func TestFormatBodyContent_ShouldReplaceBody(t *testing.T) {
 	stream := ioutil.NopCloser(strings.NewReader("lol"))
 
-	val, err := formatBodyContent(stream, func(replacementBody io.ReadCloser) {
+	val, err := formatBodyContent(http.Header{}, stream, func(replacementBody io.ReadCloser) {
 		stream = replacementBody
 	})
 	assert.NoError(t, err)
 	assert.Equal(t, "lol", val)
 
-	valSecondRun, errSecondRun := formatBodyContent(stream, func(replacementBody io.ReadCloser) {
+	valSecondRun, errSecondRun := formatBodyContent(http.Header{}, stream, func(replacementBody io.ReadCloser) {
 		stream = replacementBody
 	})
 	assert.NoError(t, errSecondRun)
 	assert.Equal(t, "lol", valSecondRun)
 }
 
+func TestFormatBodyContent_SummarisesBinaryBody(t *testing.T) {
+	body := append([]byte{0x0a, 0x03, 0x6a, 0x61, 0x6e, 0x10, 0x01}, bytes.Repeat([]byte{0xff}, 64)...)
+	stream := ioutil.NopCloser(bytes.NewReader(body))
+
+	val, err := formatBodyContent(http.Header{}, stream, func(replacementBody io.ReadCloser) {
+		stream = replacementBody
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "binary body of 71 bytes\n"+
+		"00000000  0a 03 6a 61 6e 10 01 ff  ff ff ff ff ff ff ff ff  |..jan...........|\n"+
+		"00000010  ff ff ff ff ff ff ff ff  ff ff ff ff ff ff ff ff  |................|\n"+
+		"00000020  ff ff ff ff ff ff ff ff  ff ff ff ff ff ff ff ff  |................|\n"+
+		"00000030  ff ff ff ff ff ff ff ff  ff ff ff ff ff ff ff ff  |................|\n"+
+		"...\n", val)
+	replaced, _ := ioutil.ReadAll(stream)
+	assert.Equal(t, body, replaced)
+}
+
+func TestFormatBodyContent_IndentsXML(t *testing.T) {
+	stream := ioutil.NopCloser(strings.NewReader(`<user id="1"><name>jan</name></user>`))
+
+	val, err := formatBodyContent(http.Header{}, stream, func(replacementBody io.ReadCloser) {
+		stream = replacementBody
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "<user id=\"1\">\n    <name>jan</name>\n</user>", val)
+}
+
+func TestFormatBodyContent_UsesRegisteredFormatter(t *testing.T) {
+	RegisterBodyFormatter(func(header http.Header, body []byte) (string, bool) {
+		if header.Get("Content-Type") != "application/x-reversed" {
+			return "", false
+		}
+		reversed := make([]byte, len(body))
+		for i, b := range body {
+			reversed[len(body)-1-i] = b
+		}
+		return string(reversed), true
+	})
+	header := http.Header{"Content-Type": []string{"application/x-reversed"}}
+	stream := ioutil.NopCloser(strings.NewReader("olleh"))
+
+	val, err := formatBodyContent(header, stream, func(replacementBody io.ReadCloser) {
+		stream = replacementBody
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", val)
+
+	val, err = formatBodyContent(http.Header{}, stream, func(replacementBody io.ReadCloser) {
+		stream = replacementBody
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "olleh", val)
+}
+
 func TestWebSequenceDiagram_GeneratesDSL(t *testing.T) {
 	wsd := webSequenceDiagramDSL{}
 	wsd.addRequestRow("A", "B", "request1")
@@ -77,6 +135,19 @@ func TestWebSequenceDiagram_GeneratesDSL(t *testing.T) {
 	}
 }
 
+func TestWebSequenceDiagram_GeneratesDSLForPassthroughInteractions(t *testing.T) {
+	wsd := webSequenceDiagramDSL{}
+	wsd.addPassthroughRequestRow("B", "C", "request")
+	wsd.addPassthroughResponseRow("C", "B", "response")
+
+	actual := wsd.toString()
+
+	expected := "B-->C: (1) request\nC-->>B: (2) response\n"
+	if expected != actual {
+		t.Fatalf("expected=%s != actual=%s", expected, actual)
+	}
+}
+
 func TestNewSequenceDiagramFormatter_SetsDefaultPath(t *testing.T) {
 	formatter := SequenceDiagram()
 
@@ -0,0 +1,422 @@
+package apitest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type (
+	// HARFormatter implementation of a ReportFormatter that writes the http interactions of a test as a HAR 1.2 archive
+	HARFormatter struct {
+		storagePath string
+		fs          fileSystem
+	}
+
+	// HARFilter selects the entries of a HAR file that are converted to mocks
+	HARFilter func(*http.Request) bool
+
+	harFile struct {
+		Log harLog `json:"log"`
+	}
+
+	harLog struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Pages   []harPage  `json:"pages,omitempty"`
+		Entries []harEntry `json:"entries"`
+	}
+
+	harCreator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	harPage struct {
+		StartedDateTime time.Time      `json:"startedDateTime"`
+		ID              string         `json:"id"`
+		Title           string         `json:"title"`
+		PageTimings     harPageTimings `json:"pageTimings"`
+	}
+
+	harPageTimings struct{}
+
+	harEntry struct {
+		Pageref         string      `json:"pageref,omitempty"`
+		StartedDateTime time.Time   `json:"startedDateTime"`
+		Time            float64     `json:"time"`
+		Request         harRequest  `json:"request"`
+		Response        harResponse `json:"response"`
+		Cache           struct{}    `json:"cache"`
+		Timings         harTimings  `json:"timings"`
+	}
+
+	harRequest struct {
+		Method      string         `json:"method"`
+		URL         string         `json:"url"`
+		HTTPVersion string         `json:"httpVersion"`
+		Cookies     []harCookie    `json:"cookies"`
+		Headers     []harNameValue `json:"headers"`
+		QueryString []harNameValue `json:"queryString"`
+		PostData    *harPostData   `json:"postData,omitempty"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int            `json:"bodySize"`
+	}
+
+	harResponse struct {
+		Status      int            `json:"status"`
+		StatusText  string         `json:"statusText"`
+		HTTPVersion string         `json:"httpVersion"`
+		Cookies     []harCookie    `json:"cookies"`
+		Headers     []harNameValue `json:"headers"`
+		Content     harContent     `json:"content"`
+		RedirectURL string         `json:"redirectURL"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int            `json:"bodySize"`
+	}
+
+	harCookie struct {
+		Name     string     `json:"name"`
+		Value    string     `json:"value"`
+		Path     string     `json:"path,omitempty"`
+		Domain   string     `json:"domain,omitempty"`
+		Expires  *time.Time `json:"expires,omitempty"`
+		HTTPOnly bool       `json:"httpOnly,omitempty"`
+		Secure   bool       `json:"secure,omitempty"`
+	}
+
+	harNameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	harPostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	}
+
+	harContent struct {
+		Size     int    `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+		Encoding string `json:"encoding,omitempty"`
+	}
+
+	harTimings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+)
+
+// HAR produce a HAR archive at the given path or .har by default
+func HAR(path ...string) *HARFormatter {
+	storagePath := ".har"
+	if len(path) > 0 {
+		storagePath = path[0]
+	}
+	return &HARFormatter{storagePath: storagePath, fs: &osFileSystem{}}
+}
+
+// Format writes the http requests and responses received by the recorder as HAR entries. Each request is paired with
+// the next response sent in the opposite direction and the time of the entry is the time between both events
+func (r *HARFormatter) Format(recorder *Recorder) {
+	har, err := newHAR(recorder)
+	if err != nil {
+		panic(err)
+	}
+
+	data, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	fileName := fmt.Sprintf("%s.har", recorder.Meta["hash"])
+	err = r.fs.mkdirAll(r.storagePath, os.ModePerm)
+	if err != nil {
+		panic(err)
+	}
+	saveFilesTo := fmt.Sprintf("%s/%s", r.storagePath, fileName)
+
+	f, err := r.fs.create(saveFilesTo)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	s, _ := filepath.Abs(saveFilesTo)
+	_, err = f.Write(data)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Created HAR (%s): %s\n", fileName, filepath.FromSlash(s))
+}
+
+func newHAR(recorder *Recorder) (harFile, error) {
+	har := harFile{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "apitest", Version: "1.0"},
+		Entries: []harEntry{},
+	}}
+
+	pageID := fmt.Sprint(recorder.Meta["hash"])
+	if len(recorder.Events) > 0 {
+		har.Log.Pages = []harPage{{
+			StartedDateTime: recorder.Events[0].GetTime(),
+			ID:              pageID,
+			Title:           recorder.Title,
+		}}
+	}
+
+	paired := map[int]bool{}
+	for i, event := range recorder.Events {
+		req, ok := event.(HttpRequest)
+		if !ok {
+			continue
+		}
+
+		entry := harEntry{Pageref: pageID, StartedDateTime: req.Timestamp}
+		request, err := newHARRequest(req.Value)
+		if err != nil {
+			return harFile{}, err
+		}
+		entry.Request = request
+		// the request did not receive a response if it is not paired, e.g. because the mock timed out
+		entry.Response = harResponse{HTTPVersion: request.HTTPVersion, Cookies: []harCookie{}, Headers: []harNameValue{}, HeadersSize: -1, BodySize: -1}
+
+		for j, next := range recorder.Events {
+			res, ok := next.(HttpResponse)
+			if !ok || paired[j] || j == i || res.Source != req.Target || res.Target != req.Source || res.Timestamp.Before(req.Timestamp) {
+				continue
+			}
+			paired[j] = true
+			response, err := newHARResponse(res.Value)
+			if err != nil {
+				return harFile{}, err
+			}
+			entry.Response = response
+			entry.Time = float64(res.Timestamp.Sub(req.Timestamp)) / float64(time.Millisecond)
+			entry.Timings.Wait = entry.Time
+			break
+		}
+		har.Log.Entries = append(har.Log.Entries, entry)
+	}
+	return har, nil
+}
+
+func newHARRequest(req *http.Request) (harRequest, error) {
+	u := *req.URL
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	if u.Host == "" {
+		u.Host = req.Host
+	}
+
+	request := harRequest{
+		Method:      req.Method,
+		URL:         u.String(),
+		HTTPVersion: harHTTPVersion(req.Proto, req.ProtoMajor, req.ProtoMinor),
+		Cookies:     []harCookie{},
+		Headers:     harNameValues(req.Header),
+		QueryString: harNameValues(u.Query()),
+		HeadersSize: -1,
+	}
+	for _, cookie := range req.Cookies() {
+		request.Cookies = append(request.Cookies, harCookie{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	body, err := readBody(req.Body, func(replacementBody io.ReadCloser) {
+		req.Body = replacementBody
+	})
+	if err != nil {
+		return harRequest{}, err
+	}
+	request.BodySize = len(body)
+	if len(body) > 0 {
+		request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return request, nil
+}
+
+func newHARResponse(res *http.Response) (harResponse, error) {
+	response := harResponse{
+		Status:      res.StatusCode,
+		StatusText:  http.StatusText(res.StatusCode),
+		HTTPVersion: harHTTPVersion(res.Proto, res.ProtoMajor, res.ProtoMinor),
+		Cookies:     []harCookie{},
+		Headers:     harNameValues(res.Header),
+		RedirectURL: res.Header.Get("Location"),
+		HeadersSize: -1,
+	}
+	for _, cookie := range res.Cookies() {
+		c := harCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			HTTPOnly: cookie.HttpOnly,
+			Secure:   cookie.Secure,
+		}
+		if !cookie.Expires.IsZero() {
+			expires := cookie.Expires
+			c.Expires = &expires
+		}
+		response.Cookies = append(response.Cookies, c)
+	}
+
+	body, err := readBody(res.Body, func(replacementBody io.ReadCloser) {
+		res.Body = replacementBody
+	})
+	if err != nil {
+		return harResponse{}, err
+	}
+	response.BodySize = len(body)
+	response.Content = harContent{Size: len(body), MimeType: res.Header.Get("Content-Type")}
+	if utf8.Valid(body) {
+		response.Content.Text = string(body)
+	} else {
+		response.Content.Text = base64.StdEncoding.EncodeToString(body)
+		response.Content.Encoding = "base64"
+	}
+	return response, nil
+}
+
+func harHTTPVersion(proto string, major, minor int) string {
+	if proto != "" {
+		return proto
+	}
+	if major > 0 {
+		return fmt.Sprintf("HTTP/%d.%d", major, minor)
+	}
+	return "HTTP/1.1"
+}
+
+func harNameValues(values map[string][]string) []harNameValue {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nameValues := []harNameValue{}
+	for _, name := range names {
+		for _, value := range values[name] {
+			nameValues = append(nameValues, harNameValue{Name: name, Value: value})
+		}
+	}
+	return nameValues
+}
+
+// HARHosts selects the entries of a HAR file that were sent to one of the given hosts, e.g. api.example.com
+func HARHosts(hosts ...string) HARFilter {
+	return func(req *http.Request) bool {
+		for _, host := range hosts {
+			if req.URL.Host == host || req.URL.Hostname() == host {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MocksFromHAR creates a mock for each entry of a HAR file, e.g. one exported from the developer tools of a browser.
+// Mocks match the method, url and query params of the recorded request and are ordered as the entries of the file,
+// so repeated requests receive the recorded responses in turn. Entries are only converted if the filter, which can be nil,
+// selects the request. Entries without a response are skipped. MocksFromHAR panics if the file is invalid
+func MocksFromHAR(path string, filter HARFilter) []*Mock {
+	mocks, err := mocksFromHAR(path, filter)
+	if err != nil {
+		panic(err)
+	}
+	return mocks
+}
+
+func mocksFromHAR(path string, filter HARFilter) ([]*Mock, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	var mocks []*Mock
+	for i, entry := range har.Log.Entries {
+		if entry.Response.Status == 0 {
+			continue
+		}
+		req, err := entry.Request.httpRequest()
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %s", path, i, err)
+		}
+		if filter != nil && !filter(req) {
+			continue
+		}
+		mock, err := entry.newMock()
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %s", path, i, err)
+		}
+		mocks = append(mocks, mock)
+	}
+	return mocks, nil
+}
+
+func (r harRequest) httpRequest() (*http.Request, error) {
+	var body io.Reader
+	if r.PostData != nil {
+		body = strings.NewReader(r.PostData.Text)
+	}
+	req, err := http.NewRequest(r.Method, r.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range r.Headers {
+		req.Header.Add(header.Name, header.Value)
+	}
+	return req, nil
+}
+
+func (e harEntry) newMock() (*Mock, error) {
+	mock := NewMock()
+	req := mock.Method(e.Request.Method)
+	mock.parseUrl(e.Request.URL)
+	mock.request.url.Path = exactly(mock.request.url.Path)
+
+	for key, values := range mock.request.url.Query() {
+		for _, value := range values {
+			req.Query(key, exactly(value))
+		}
+	}
+
+	body := []byte(e.Response.Content.Text)
+	if e.Response.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(e.Response.Content.Text)
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+
+	res := req.RespondWith().Status(e.Response.Status).Body(string(body))
+	for _, header := range e.Response.Headers {
+		// the content of a HAR entry is decoded so the headers describing the encoding no longer apply
+		switch http.CanonicalHeaderKey(header.Name) {
+		case "Content-Length", "Transfer-Encoding", "Content-Encoding":
+			continue
+		}
+		res.Header(header.Name, header.Value)
+	}
+	return res.End(), nil
+}
@@ -0,0 +1,162 @@
+package apitest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHARFormatter_Format(t *testing.T) {
+	mockFS := &FS{}
+	formatter := HARFormatter{storagePath: ".har", fs: mockFS}
+	started := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	inboundReq := httptest.NewRequest(http.MethodPost, "/user?name=jan", strings.NewReader(`{"name": "jan"}`))
+	inboundReq.Header.Set("Content-Type", "application/json")
+	mockReq := httptest.NewRequest(http.MethodGet, "http://users/user/1", nil)
+	mockRes := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Set-Cookie": {"session=abc; Path=/"}},
+		Body: ioutil.NopCloser(strings.NewReader("\x89PNG"))}
+	finalRes := &http.Response{StatusCode: http.StatusCreated, ProtoMajor: 1, ProtoMinor: 1,
+		Header: http.Header{"Content-Type": {"application/json"}}, Body: ioutil.NopCloser(strings.NewReader(`{"id": 1}`))}
+
+	formatter.Format(NewTestRecorder().
+		AddTitle("POST /user").
+		AddHttpRequest(HttpRequest{Source: "cli", Target: "sut", Value: inboundReq, Timestamp: started}).
+		AddHttpRequest(HttpRequest{Source: "sut", Target: "users", Value: mockReq, Timestamp: started.Add(time.Millisecond)}).
+		AddMessageRequest(MessageRequest{Source: "sut", Target: "db", Header: "SELECT", Timestamp: started.Add(2 * time.Millisecond)}).
+		AddHttpResponse(HttpResponse{Source: "users", Target: "sut", Value: mockRes, Timestamp: started.Add(3 * time.Millisecond)}).
+		AddHttpResponse(HttpResponse{Source: "sut", Target: "cli", Value: finalRes, Timestamp: started.Add(5500 * time.Microsecond)}).
+		AddMeta(map[string]interface{}{"hash": "1234"}))
+
+	assert.Equal(t, ".har", mockFS.CapturedMkdirAllPath)
+	assert.Equal(t, ".har/1234.har", mockFS.CapturedCreateName)
+	data, err := ioutil.ReadFile(mockFS.CapturedCreateFile)
+	assert.NoError(t, err)
+
+	var har harFile
+	assert.NoError(t, json.Unmarshal(data, &har))
+	assert.Equal(t, "1.2", har.Log.Version)
+	assert.Equal(t, []harPage{{StartedDateTime: started, ID: "1234", Title: "POST /user"}}, har.Log.Pages)
+	assert.Len(t, har.Log.Entries, 2)
+
+	inbound := har.Log.Entries[0]
+	assert.Equal(t, "1234", inbound.Pageref)
+	assert.Equal(t, started, inbound.StartedDateTime)
+	assert.Equal(t, 5.5, inbound.Time)
+	assert.Equal(t, harTimings{Wait: 5.5}, inbound.Timings)
+	assert.Equal(t, "http://example.com/user?name=jan", inbound.Request.URL)
+	assert.Equal(t, []harNameValue{{Name: "name", Value: "jan"}}, inbound.Request.QueryString)
+	assert.Equal(t, &harPostData{MimeType: "application/json", Text: `{"name": "jan"}`}, inbound.Request.PostData)
+	assert.Equal(t, http.StatusCreated, inbound.Response.Status)
+	assert.Equal(t, "Created", inbound.Response.StatusText)
+	assert.Equal(t, "HTTP/1.1", inbound.Response.HTTPVersion)
+	assert.Equal(t, harContent{Size: 9, MimeType: "application/json", Text: `{"id": 1}`}, inbound.Response.Content)
+
+	outbound := har.Log.Entries[1]
+	assert.Equal(t, 2.0, outbound.Time)
+	assert.Equal(t, "http://users/user/1", outbound.Request.URL)
+	assert.Nil(t, outbound.Request.PostData)
+	assert.Equal(t, []harCookie{{Name: "session", Value: "abc", Path: "/"}}, outbound.Response.Cookies)
+	assert.Equal(t, harContent{Size: 4, Text: "iVBORw==", Encoding: "base64"}, outbound.Response.Content)
+}
+
+func TestHARFormatter_FormatRequestWithoutResponse(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://users/user/1", nil)
+
+	har, err := newHAR(NewTestRecorder().AddHttpRequest(HttpRequest{Source: "sut", Target: "users", Value: req}))
+
+	assert.NoError(t, err)
+	assert.Len(t, har.Log.Entries, 1)
+	assert.Equal(t, 0, har.Log.Entries[0].Response.Status)
+	assert.Equal(t, []harNameValue{}, har.Log.Entries[0].Response.Headers)
+}
+
+func TestHAR_SetsDefaultPath(t *testing.T) {
+	assert.Equal(t, ".har", HAR().storagePath)
+	assert.Equal(t, "reports", HAR("reports").storagePath)
+}
+
+func TestMocksFromHAR(t *testing.T) {
+	mocks := MocksFromHAR("testdata/har/users.har", nil)
+
+	assert.Len(t, mocks, 3)
+	assert.Equal(t, http.MethodGet, mocks[0].request.method)
+	assert.Equal(t, "^/users$", mocks[0].request.url.Path)
+	assert.Equal(t, map[string][]string{"page": {"^1$"}}, mocks[0].request.query)
+	assert.Equal(t, http.StatusOK, mocks[0].response.statusCode)
+	assert.Equal(t, map[string][]string{"Content-Type": {"application/json"}}, mocks[0].response.headers)
+	assert.JSONEq(t, `[{"id": 1, "name": "Jan"}]`, mocks[0].response.body)
+	assert.Equal(t, http.StatusServiceUnavailable, mocks[1].response.statusCode)
+	assert.Equal(t, "\x89PNG", mocks[2].response.body)
+}
+
+func TestMocksFromHAR_Filter(t *testing.T) {
+	mocks := MocksFromHAR("testdata/har/users.har", HARHosts("api.example.com"))
+
+	assert.Len(t, mocks, 2)
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/users?page=1", nil)
+	first, err := matches(req, mocks)
+	assert.NoError(t, err)
+	second, err := matches(req, mocks)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, first.statusCode)
+	assert.Equal(t, http.StatusServiceUnavailable, second.statusCode)
+}
+
+func TestMocksFromHAR_MatchesExactPath(t *testing.T) {
+	mocks := MocksFromHAR("testdata/har/users.har", HARHosts("api.example.com"))
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/users/1?page=1", nil)
+
+	_, err := matches(req, mocks)
+
+	assert.Error(t, err)
+}
+
+func TestMocksFromHAR_PanicsIfInvalid(t *testing.T) {
+	assert.Panics(t, func() {
+		MocksFromHAR("testdata/har/missing.har", nil)
+	})
+}
+
+func TestMocksFromHAR_ReplaysHARFormatterOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "har")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		res, err := http.Get("http://users/user/1")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(res.StatusCode)
+	}
+
+	New().
+		Report(HAR(dir)).
+		Mocks(NewMock().Get("http://users/user/1").RespondWith().Status(http.StatusAccepted).Body(`{"id": 1}`).End()).
+		HandlerFunc(handler).
+		Get("/").
+		Expect(t).
+		Status(http.StatusAccepted).
+		End()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.har"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	mocks := MocksFromHAR(files[0], HARHosts("users"))
+	assert.Len(t, mocks, 1)
+	req, _ := http.NewRequest(http.MethodGet, "http://users/user/1", nil)
+	mockResponse, err := matches(req, mocks)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, mockResponse.statusCode)
+	assert.JSONEq(t, `{"id": 1}`, mockResponse.body)
+}
@@ -0,0 +1,780 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a compiled JSONPath expression, e.g. $.store.book[?(@.price < 10)].title
+type jsonPath struct {
+	relative bool
+	segments []jsonPathSegment
+}
+
+type jsonPathSegment struct {
+	recursive bool
+	selectors []jsonPathSelector
+}
+
+// jsonPathSelector selects children of a node by their keys, i.e. names of object members and indexes of array items
+type jsonPathSelector interface {
+	selectKeys(node, root interface{}) []interface{}
+}
+
+// jsonPathNode is a value selected by a path and its location, which allows the value to be replaced in the document
+type jsonPathNode struct {
+	value  interface{}
+	parent interface{}
+	key    interface{}
+}
+
+type (
+	jsonPathName     string
+	jsonPathIndex    int
+	jsonPathWildcard struct{}
+	jsonPathSlice    struct{ start, end *int }
+	jsonPathFilter   struct{ expr jsonPathExpr }
+)
+
+// compileJSONPath parses a JSONPath expression. Supported are child and recursive descent (..) segments, wildcards,
+// array indexes, unions, slices and filters using comparison, regular expression (=~) and logical operators
+func compileJSONPath(expr string) (*jsonPath, error) {
+	p := &jsonPathParser{expr: strings.TrimSpace(expr)}
+	if p.expr == "" {
+		return nil, fmt.Errorf("invalid JSONPath: empty expression")
+	}
+	if p.peek() != '$' && p.peek() != '@' {
+		// a path without the root identifier is relative to the root, e.g. store.book
+		p.expr = "$." + p.expr
+	}
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath '%s': %s", expr, err)
+	}
+	if p.pos < len(p.expr) {
+		return nil, fmt.Errorf("invalid JSONPath '%s': unexpected '%s' at position %d", expr, p.expr[p.pos:], p.pos)
+	}
+	return path, nil
+}
+
+// evaluate returns the values selected by the path
+func (p *jsonPath) evaluate(root interface{}) []interface{} {
+	return p.evaluateFrom(root, root)
+}
+
+// value returns the value selected by the path. A singular path, i.e. a path of names and indexes such as $.items[0].id,
+// selects at most one value, any other path selects the list of matching values. present is false if nothing is selected
+func (p *jsonPath) value(root interface{}) (value interface{}, present bool) {
+	nodes := p.evaluate(root)
+	if !p.singular() {
+		if nodes == nil {
+			nodes = []interface{}{}
+		}
+		return nodes, len(nodes) > 0
+	}
+	if len(nodes) == 0 {
+		return nil, false
+	}
+	return nodes[0], true
+}
+
+func (p *jsonPath) singular() bool {
+	for _, segment := range p.segments {
+		if segment.recursive || len(segment.selectors) != 1 {
+			return false
+		}
+		switch segment.selectors[0].(type) {
+		case jsonPathName, jsonPathIndex:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (p *jsonPath) evaluateFrom(current, root interface{}) []interface{} {
+	nodes := p.locate(current, root)
+	if nodes == nil {
+		return nil
+	}
+	values := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		values[i] = node.value
+	}
+	return values
+}
+
+// replace replaces each value selected by the path with the result of fn. Values selected by the root of the path
+// are not replaced
+func (p *jsonPath) replace(root interface{}, fn func(interface{}) interface{}) {
+	for _, node := range p.locate(root, root) {
+		switch parent := node.parent.(type) {
+		case map[string]interface{}:
+			parent[node.key.(string)] = fn(node.value)
+		case []interface{}:
+			parent[node.key.(int)] = fn(node.value)
+		}
+	}
+}
+
+func (p *jsonPath) locate(current, root interface{}) []jsonPathNode {
+	nodes := []jsonPathNode{{value: current}}
+	for _, segment := range p.segments {
+		var next []jsonPathNode
+		for _, node := range nodes {
+			candidates := []jsonPathNode{node}
+			if segment.recursive {
+				candidates = descendants(node, candidates[:0])
+			}
+			for _, candidate := range candidates {
+				for _, selector := range segment.selectors {
+					for _, key := range selector.selectKeys(candidate.value, root) {
+						next = append(next, child(candidate.value, key))
+					}
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func child(parent, key interface{}) jsonPathNode {
+	switch v := parent.(type) {
+	case map[string]interface{}:
+		return jsonPathNode{value: v[key.(string)], parent: parent, key: key}
+	case []interface{}:
+		return jsonPathNode{value: v[key.(int)], parent: parent, key: key}
+	}
+	return jsonPathNode{}
+}
+
+// descendants returns the node and all of its descendants in document order
+func descendants(node jsonPathNode, out []jsonPathNode) []jsonPathNode {
+	out = append(out, node)
+	for _, key := range (jsonPathWildcard{}).selectKeys(node.value, nil) {
+		out = descendants(child(node.value, key), out)
+	}
+	return out
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (n jsonPathName) selectKeys(node, _ interface{}) []interface{} {
+	if m, ok := node.(map[string]interface{}); ok {
+		if _, ok := m[string(n)]; ok {
+			return []interface{}{string(n)}
+		}
+	}
+	return nil
+}
+
+func (i jsonPathIndex) selectKeys(node, _ interface{}) []interface{} {
+	if items, ok := node.([]interface{}); ok {
+		index := int(i)
+		if index < 0 {
+			index += len(items)
+		}
+		if index >= 0 && index < len(items) {
+			return []interface{}{index}
+		}
+	}
+	return nil
+}
+
+func (jsonPathWildcard) selectKeys(node, _ interface{}) []interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		var out []interface{}
+		for _, key := range sortedKeys(v) {
+			out = append(out, key)
+		}
+		return out
+	case []interface{}:
+		return indexes(0, len(v))
+	}
+	return nil
+}
+
+func (s jsonPathSlice) selectKeys(node, _ interface{}) []interface{} {
+	items, ok := node.([]interface{})
+	if !ok {
+		return nil
+	}
+	bound := func(i *int, def int) int {
+		if i == nil {
+			return def
+		}
+		v := *i
+		if v < 0 {
+			v += len(items)
+		}
+		if v < 0 {
+			return 0
+		}
+		if v > len(items) {
+			return len(items)
+		}
+		return v
+	}
+	start, end := bound(s.start, 0), bound(s.end, len(items))
+	if start >= end {
+		return nil
+	}
+	return indexes(start, end)
+}
+
+func (f jsonPathFilter) selectKeys(node, root interface{}) []interface{} {
+	var out []interface{}
+	for _, key := range (jsonPathWildcard{}).selectKeys(node, root) {
+		if truthy(f.expr.eval(child(node, key).value, root)) {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+func indexes(start, end int) []interface{} {
+	out := make([]interface{}, 0, end-start)
+	for i := start; i < end; i++ {
+		out = append(out, i)
+	}
+	return out
+}
+
+// jsonPathExpr is an expression of a filter, e.g. @.price < 10 && @.category == 'fiction'
+type jsonPathExpr interface {
+	eval(current, root interface{}) interface{}
+}
+
+type (
+	jsonPathOr      struct{ left, right jsonPathExpr }
+	jsonPathAnd     struct{ left, right jsonPathExpr }
+	jsonPathNot     struct{ expr jsonPathExpr }
+	jsonPathLiteral struct{ value interface{} }
+	jsonPathOperand struct{ path *jsonPath }
+	jsonPathCompare struct {
+		op          string
+		left, right jsonPathExpr
+	}
+	// jsonPathNodes are the values selected by a path within a filter
+	jsonPathNodes []interface{}
+)
+
+func (e jsonPathOr) eval(current, root interface{}) interface{} {
+	return truthy(e.left.eval(current, root)) || truthy(e.right.eval(current, root))
+}
+
+func (e jsonPathAnd) eval(current, root interface{}) interface{} {
+	return truthy(e.left.eval(current, root)) && truthy(e.right.eval(current, root))
+}
+
+func (e jsonPathNot) eval(current, root interface{}) interface{} {
+	return !truthy(e.expr.eval(current, root))
+}
+
+func (e jsonPathLiteral) eval(_, _ interface{}) interface{} {
+	return e.value
+}
+
+func (e jsonPathOperand) eval(current, root interface{}) interface{} {
+	if e.path.relative {
+		return jsonPathNodes(e.path.evaluateFrom(current, root))
+	}
+	return jsonPathNodes(e.path.evaluate(root))
+}
+
+func (e jsonPathCompare) eval(current, root interface{}) interface{} {
+	left, ok := single(e.left.eval(current, root))
+	if !ok {
+		return false
+	}
+	right, ok := single(e.right.eval(current, root))
+	if !ok {
+		return false
+	}
+
+	switch e.op {
+	case "==":
+		return jsonValuesEqual(left, right)
+	case "!=":
+		return !jsonValuesEqual(left, right)
+	case "=~":
+		s, isString := left.(string)
+		re, isRegexp := right.(*regexp.Regexp)
+		return isString && isRegexp && re.MatchString(s)
+	}
+
+	if l, ok := toFloat(left); ok {
+		if r, ok := toFloat(right); ok {
+			return compareOrdered(e.op, l < r, l == r)
+		}
+	}
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			return compareOrdered(e.op, l < r, l == r)
+		}
+	}
+	return false
+}
+
+func compareOrdered(op string, less, equal bool) bool {
+	switch op {
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	case ">=":
+		return !less
+	}
+	return false
+}
+
+// single returns the value of an operand. Paths must select exactly one value to be compared
+func single(v interface{}) (interface{}, bool) {
+	if nodes, ok := v.(jsonPathNodes); ok {
+		if len(nodes) != 1 {
+			return nil, false
+		}
+		return nodes[0], true
+	}
+	return v, true
+}
+
+func truthy(v interface{}) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case jsonPathNodes:
+		return len(value) > 0
+	case nil:
+		return false
+	}
+	return true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// jsonValuesEqual compares decoded JSON values, treating numbers of different types as equal if their values are equal
+func jsonValuesEqual(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(normalizeJSON(a), normalizeJSON(b))
+}
+
+func normalizeJSON(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for k, item := range value {
+			out[k] = normalizeJSON(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, item := range value {
+			out[i] = normalizeJSON(item)
+		}
+		return out
+	}
+	if f, ok := toFloat(v); ok {
+		return f
+	}
+	return v
+}
+
+// jsonPathValueString formats a selected value for comparison with a string, e.g. numbers are formatted without exponent
+func jsonPathValueString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case nil:
+		return "null"
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+type jsonPathParser struct {
+	expr string
+	pos  int
+}
+
+func (p *jsonPathParser) peek() byte {
+	if p.pos < len(p.expr) {
+		return p.expr[p.pos]
+	}
+	return 0
+}
+
+func (p *jsonPathParser) skipSpaces() {
+	for p.pos < len(p.expr) && (p.expr[p.pos] == ' ' || p.expr[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *jsonPathParser) consume(s string) bool {
+	if strings.HasPrefix(p.expr[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jsonPathParser) parsePath() (*jsonPath, error) {
+	path := &jsonPath{}
+	switch p.peek() {
+	case '$':
+	case '@':
+		path.relative = true
+	default:
+		return nil, fmt.Errorf("expected '$' or '@' at position %d", p.pos)
+	}
+	p.pos++
+
+	for p.peek() == '.' || p.peek() == '[' {
+		segment, err := p.parseSegment()
+		if err != nil {
+			return nil, err
+		}
+		path.segments = append(path.segments, segment)
+	}
+	return path, nil
+}
+
+func (p *jsonPathParser) parseSegment() (jsonPathSegment, error) {
+	var segment jsonPathSegment
+	if p.consume("..") {
+		segment.recursive = true
+		if p.peek() == '[' {
+			selectors, err := p.parseBracket()
+			segment.selectors = selectors
+			return segment, err
+		}
+	} else if p.consume(".") {
+		if p.peek() == '[' {
+			return segment, fmt.Errorf("unexpected '[' at position %d", p.pos)
+		}
+	} else {
+		selectors, err := p.parseBracket()
+		segment.selectors = selectors
+		return segment, err
+	}
+
+	if p.consume("*") {
+		segment.selectors = []jsonPathSelector{jsonPathWildcard{}}
+		return segment, nil
+	}
+	start := p.pos
+	for p.pos < len(p.expr) && !strings.ContainsRune(".[]()=!<>&|,'\" \t~", rune(p.expr[p.pos])) {
+		p.pos++
+	}
+	if start == p.pos {
+		return segment, fmt.Errorf("expected a name at position %d", p.pos)
+	}
+	segment.selectors = []jsonPathSelector{jsonPathName(p.expr[start:p.pos])}
+	return segment, nil
+}
+
+func (p *jsonPathParser) parseBracket() ([]jsonPathSelector, error) {
+	if !p.consume("[") {
+		return nil, fmt.Errorf("expected '[' at position %d", p.pos)
+	}
+	p.skipSpaces()
+
+	var selectors []jsonPathSelector
+	switch {
+	case p.consume("*"):
+		selectors = append(selectors, jsonPathWildcard{})
+	case p.consume("?"):
+		p.skipSpaces()
+		if !p.consume("(") {
+			return nil, fmt.Errorf("expected '(' at position %d", p.pos)
+		}
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if !p.consume(")") {
+			return nil, fmt.Errorf("expected ')' at position %d", p.pos)
+		}
+		selectors = append(selectors, jsonPathFilter{expr: expr})
+	default:
+		for {
+			p.skipSpaces()
+			selector, err := p.parseUnionItem()
+			if err != nil {
+				return nil, err
+			}
+			selectors = append(selectors, selector)
+			p.skipSpaces()
+			if !p.consume(",") {
+				break
+			}
+		}
+	}
+
+	p.skipSpaces()
+	if !p.consume("]") {
+		return nil, fmt.Errorf("expected ']' at position %d", p.pos)
+	}
+	return selectors, nil
+}
+
+func (p *jsonPathParser) parseUnionItem() (jsonPathSelector, error) {
+	if c := p.peek(); c == '\'' || c == '"' {
+		s, err := p.parseString()
+		return jsonPathName(s), err
+	}
+
+	var parts []*int
+	for {
+		p.skipSpaces()
+		var value *int
+		if c := p.peek(); c == '-' || (c >= '0' && c <= '9') {
+			n, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			value = &n
+		}
+		parts = append(parts, value)
+		p.skipSpaces()
+		if !p.consume(":") {
+			break
+		}
+	}
+
+	switch len(parts) {
+	case 1:
+		if parts[0] == nil {
+			return nil, fmt.Errorf("expected an index or name at position %d", p.pos)
+		}
+		return jsonPathIndex(*parts[0]), nil
+	case 2:
+		return jsonPathSlice{start: parts[0], end: parts[1]}, nil
+	}
+	return nil, fmt.Errorf("slices with a step are not supported at position %d", p.pos)
+}
+
+func (p *jsonPathParser) parseInt() (int, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.expr) && p.expr[p.pos] >= '0' && p.expr[p.pos] <= '9' {
+		p.pos++
+	}
+	return strconv.Atoi(p.expr[start:p.pos])
+}
+
+func (p *jsonPathParser) parseString() (string, error) {
+	quote := p.expr[p.pos]
+	p.pos++
+	var out strings.Builder
+	for p.pos < len(p.expr) {
+		c := p.expr[p.pos]
+		p.pos++
+		switch {
+		case c == '\\' && p.pos < len(p.expr):
+			out.WriteByte(p.expr[p.pos])
+			p.pos++
+		case c == quote:
+			return out.String(), nil
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+func (p *jsonPathParser) parseOr() (jsonPathExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if !p.consume("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = jsonPathOr{left: left, right: right}
+	}
+}
+
+func (p *jsonPathParser) parseAnd() (jsonPathExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = jsonPathAnd{left: left, right: right}
+	}
+}
+
+func (p *jsonPathParser) parseUnary() (jsonPathExpr, error) {
+	p.skipSpaces()
+	if p.peek() == '!' && !strings.HasPrefix(p.expr[p.pos:], "!=") {
+		p.pos++
+		expr, err := p.parseUnary()
+		return jsonPathNot{expr: expr}, err
+	}
+	if p.consume("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if !p.consume(")") {
+			return nil, fmt.Errorf("expected ')' at position %d", p.pos)
+		}
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *jsonPathParser) parseComparison() (jsonPathExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	for _, op := range []string{"==", "!=", "<=", ">=", "=~", "<", ">"} {
+		if p.consume(op) {
+			p.skipSpaces()
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			if op == "=~" {
+				if literal, ok := right.(jsonPathLiteral); !ok || !isRegexp(literal.value) {
+					return nil, fmt.Errorf("expected a regular expression at position %d", p.pos)
+				}
+			}
+			return jsonPathCompare{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *jsonPathParser) parseOperand() (jsonPathExpr, error) {
+	p.skipSpaces()
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		path, err := p.parsePath()
+		return jsonPathOperand{path: path}, err
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return jsonPathLiteral{value: s}, err
+	case c == '/':
+		return p.parseRegexp()
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.expr) && strings.ContainsRune("0123456789.eE+-", rune(p.expr[p.pos])) {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(p.expr[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", p.expr[start:p.pos])
+		}
+		return jsonPathLiteral{value: f}, nil
+	case p.consume("true"):
+		return jsonPathLiteral{value: true}, nil
+	case p.consume("false"):
+		return jsonPathLiteral{value: false}, nil
+	case p.consume("null"):
+		return jsonPathLiteral{value: nil}, nil
+	}
+	return nil, fmt.Errorf("unexpected '%s' at position %d", p.expr[p.pos:], p.pos)
+}
+
+func isRegexp(v interface{}) bool {
+	_, ok := v.(*regexp.Regexp)
+	return ok
+}
+
+// parseRegexp parses a regular expression literal, e.g. /^foo.*/i
+func (p *jsonPathParser) parseRegexp() (jsonPathExpr, error) {
+	p.pos++
+	var pattern strings.Builder
+	for {
+		if p.pos >= len(p.expr) {
+			return nil, fmt.Errorf("unterminated regular expression")
+		}
+		c := p.expr[p.pos]
+		p.pos++
+		if c == '\\' && p.pos < len(p.expr) && p.expr[p.pos] == '/' {
+			pattern.WriteByte('/')
+			p.pos++
+			continue
+		}
+		if c == '/' {
+			break
+		}
+		pattern.WriteByte(c)
+	}
+
+	flags := ""
+	for p.pos < len(p.expr) && strings.ContainsRune("ims", rune(p.expr[p.pos])) {
+		flags += string(p.expr[p.pos])
+		p.pos++
+	}
+	expr := pattern.String()
+	if flags != "" {
+		expr = "(?" + flags + ")" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return jsonPathLiteral{value: re}, nil
+}
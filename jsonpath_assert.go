@@ -0,0 +1,271 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+type (
+	// ResponseJSONPath asserts on the value selected from the response body by a JSONPath expression
+	ResponseJSONPath struct {
+		response *Response
+		expr     string
+		path     *jsonPath
+	}
+
+	// MockRequestJSONPath matches the value selected from the mock request body by a JSONPath expression
+	MockRequestJSONPath struct {
+		request *MockRequest
+		expr    string
+		path    *jsonPath
+	}
+
+	// jsonPathCheck checks the value selected by a JSONPath expression. present is false if nothing is selected
+	jsonPathCheck func(value interface{}, present bool) error
+
+	jsonPathAssertion struct {
+		expr  string
+		path  *jsonPath
+		check jsonPathCheck
+	}
+)
+
+func (a jsonPathAssertion) assert(root interface{}) error {
+	if a.path == nil {
+		return nil
+	}
+	if err := a.check(a.path.value(root)); err != nil {
+		return fmt.Errorf("JSONPath %s: %s", a.expr, err)
+	}
+	return nil
+}
+
+// Equal asserts that the selected value equals the expected value. The expected value is compared as JSON, so
+// JSONPath("$.id").Equal(12345) matches {"id": 12345}
+func (j *ResponseJSONPath) Equal(expected interface{}) *Response {
+	return j.add(jsonPathEqual(expected))
+}
+
+// Contains asserts that the selected array contains the expected value or that the selected string contains the
+// expected substring
+func (j *ResponseJSONPath) Contains(expected interface{}) *Response {
+	return j.add(jsonPathContains(expected))
+}
+
+// Len asserts the length of the selected array, object or string
+func (j *ResponseJSONPath) Len(expected int) *Response {
+	return j.add(jsonPathLen(expected))
+}
+
+// GreaterThan asserts that the length of the selected array, object or string is greater than the given length
+func (j *ResponseJSONPath) GreaterThan(length int) *Response {
+	return j.add(jsonPathGreaterThan(length))
+}
+
+// Present asserts that the expression selects a value
+func (j *ResponseJSONPath) Present() *Response {
+	return j.add(jsonPathPresent)
+}
+
+// NotPresent asserts that the expression does not select a value
+func (j *ResponseJSONPath) NotPresent() *Response {
+	return j.add(jsonPathNotPresent)
+}
+
+// Matches asserts that the selected value matches the regular expression. Numbers and booleans are matched by their
+// JSON representation
+func (j *ResponseJSONPath) Matches(regex string) *Response {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		j.response.apiTest.t.Fatal(err)
+		return j.response
+	}
+	return j.add(jsonPathMatches(re))
+}
+
+// Each calls fn with each item of the selected array, or with each value selected by an expression that is not singular,
+// e.g. $.items[*].id. The assertion fails with the first error returned by fn
+func (j *ResponseJSONPath) Each(fn func(interface{}) error) *Response {
+	return j.add(jsonPathEach(fn))
+}
+
+func (j *ResponseJSONPath) add(check jsonPathCheck) *Response {
+	j.response.jsonPaths = append(j.response.jsonPaths, jsonPathAssertion{expr: j.expr, path: j.path, check: check})
+	return j.response
+}
+
+// Equal matches bodies where the selected value equals the expected value
+func (j *MockRequestJSONPath) Equal(expected interface{}) *MockRequest {
+	return j.add(jsonPathEqual(expected))
+}
+
+// Contains matches bodies where the selected array contains the expected value or the selected string contains the
+// expected substring
+func (j *MockRequestJSONPath) Contains(expected interface{}) *MockRequest {
+	return j.add(jsonPathContains(expected))
+}
+
+// Len matches bodies where the selected array, object or string has the expected length
+func (j *MockRequestJSONPath) Len(expected int) *MockRequest {
+	return j.add(jsonPathLen(expected))
+}
+
+// GreaterThan matches bodies where the length of the selected array, object or string is greater than the given length
+func (j *MockRequestJSONPath) GreaterThan(length int) *MockRequest {
+	return j.add(jsonPathGreaterThan(length))
+}
+
+// Present matches bodies where the expression selects a value
+func (j *MockRequestJSONPath) Present() *MockRequest {
+	return j.add(jsonPathPresent)
+}
+
+// NotPresent matches bodies where the expression does not select a value
+func (j *MockRequestJSONPath) NotPresent() *MockRequest {
+	return j.add(jsonPathNotPresent)
+}
+
+// Matches matches bodies where the selected value matches the regular expression
+func (j *MockRequestJSONPath) Matches(regex string) *MockRequest {
+	return j.add(jsonPathMatches(regexp.MustCompile(regex)))
+}
+
+// Each matches bodies where fn returns no error for each item of the selected array, or for each value selected by an
+// expression that is not singular
+func (j *MockRequestJSONPath) Each(fn func(interface{}) error) *MockRequest {
+	return j.add(jsonPathEach(fn))
+}
+
+func (j *MockRequestJSONPath) add(check jsonPathCheck) *MockRequest {
+	j.request.jsonPaths = append(j.request.jsonPaths, jsonPathAssertion{expr: j.expr, path: j.path, check: check})
+	return j.request
+}
+
+func jsonPathEqual(expected interface{}) jsonPathCheck {
+	expected = toJSONValue(expected)
+	return func(value interface{}, present bool) error {
+		if !present || !jsonValuesEqual(expected, value) {
+			return fmt.Errorf("expected %s, got %s", jsonString(expected), describeJSONPathValue(value, present))
+		}
+		return nil
+	}
+}
+
+func jsonPathContains(expected interface{}) jsonPathCheck {
+	expected = toJSONValue(expected)
+	return func(value interface{}, present bool) error {
+		switch v := value.(type) {
+		case []interface{}:
+			for _, item := range v {
+				if jsonValuesEqual(expected, item) {
+					return nil
+				}
+			}
+		case string:
+			if s, ok := expected.(string); ok && strings.Contains(v, s) {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected %s to contain %s", describeJSONPathValue(value, present), jsonString(expected))
+	}
+}
+
+func jsonPathLen(expected int) jsonPathCheck {
+	return func(value interface{}, present bool) error {
+		length, err := jsonPathLength(value, present)
+		if err != nil {
+			return err
+		}
+		if length != expected {
+			return fmt.Errorf("expected length %d, got %d", expected, length)
+		}
+		return nil
+	}
+}
+
+func jsonPathGreaterThan(expected int) jsonPathCheck {
+	return func(value interface{}, present bool) error {
+		length, err := jsonPathLength(value, present)
+		if err != nil {
+			return err
+		}
+		if length <= expected {
+			return fmt.Errorf("expected length greater than %d, got %d", expected, length)
+		}
+		return nil
+	}
+}
+
+func jsonPathPresent(value interface{}, present bool) error {
+	if !present {
+		return fmt.Errorf("expected a value, got nothing")
+	}
+	return nil
+}
+
+func jsonPathNotPresent(value interface{}, present bool) error {
+	if present {
+		return fmt.Errorf("expected nothing, got %s", jsonString(value))
+	}
+	return nil
+}
+
+func jsonPathMatches(re *regexp.Regexp) jsonPathCheck {
+	return func(value interface{}, present bool) error {
+		if !present || !re.MatchString(jsonPathValueString(value)) {
+			return fmt.Errorf("expected value matching %s, got %s", re, describeJSONPathValue(value, present))
+		}
+		return nil
+	}
+}
+
+func jsonPathEach(fn func(interface{}) error) jsonPathCheck {
+	return func(value interface{}, present bool) error {
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected an array, got %s", describeJSONPathValue(value, present))
+		}
+		for i, item := range items {
+			if err := fn(item); err != nil {
+				return fmt.Errorf("item %d: %s", i, err)
+			}
+		}
+		return nil
+	}
+}
+
+func jsonPathLength(value interface{}, present bool) (int, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return len(v), nil
+	case map[string]interface{}:
+		return len(v), nil
+	case string:
+		return utf8.RuneCountInString(v), nil
+	}
+	return 0, fmt.Errorf("expected an array, object or string, got %s", describeJSONPathValue(value, present))
+}
+
+func describeJSONPathValue(value interface{}, present bool) string {
+	if !present {
+		return "nothing"
+	}
+	return jsonString(value)
+}
+
+// toJSONValue converts a Go value to the value produced by decoding its JSON representation, e.g. structs are
+// converted to maps
+func toJSONValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return v
+	}
+	return value
+}
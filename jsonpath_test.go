@@ -0,0 +1,97 @@
+package apitest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const jsonPathStore = `{
+	"store": {
+		"book": [
+			{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+			{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+			{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+			{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+		],
+		"bicycle": {"color": "red", "price": 19.95}
+	},
+	"expensive": 10
+}`
+
+func TestJSONPath_Evaluate(t *testing.T) {
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(jsonPathStore), &doc))
+
+	tests := map[string]struct {
+		expr     string
+		expected []interface{}
+	}{
+		"child":                   {expr: "$.store.bicycle.color", expected: []interface{}{"red"}},
+		"without root":            {expr: "store.bicycle.color", expected: []interface{}{"red"}},
+		"bracket name":            {expr: "$['store']['bicycle']['color']", expected: []interface{}{"red"}},
+		"index":                   {expr: "$.store.book[1].author", expected: []interface{}{"Evelyn Waugh"}},
+		"negative index":          {expr: "$.store.book[-1].title", expected: []interface{}{"The Lord of the Rings"}},
+		"union":                   {expr: "$.store.book[0,2].price", expected: []interface{}{8.95, 8.99}},
+		"slice":                   {expr: "$.store.book[1:3].price", expected: []interface{}{12.99, 8.99}},
+		"open slice":              {expr: "$.store.book[:1].price", expected: []interface{}{8.95}},
+		"wildcard":                {expr: "$.store.book[*].category", expected: []interface{}{"reference", "fiction", "fiction", "fiction"}},
+		"object wildcard":         {expr: "$.store.bicycle.*", expected: []interface{}{"red", 19.95}},
+		"recursive descent":       {expr: "$..isbn", expected: []interface{}{"0-553-21311-3", "0-395-19395-8"}},
+		"recursive with index":    {expr: "$..book[0].price", expected: []interface{}{8.95}},
+		"filter comparison":       {expr: "$.store.book[?(@.price < 9)].title", expected: []interface{}{"Sayings of the Century", "Moby Dick"}},
+		"filter existence":        {expr: "$.store.book[?(@.isbn)].price", expected: []interface{}{8.99, 22.99}},
+		"filter negation":         {expr: "$.store.book[?(!@.isbn)].price", expected: []interface{}{8.95, 12.99}},
+		"filter string equality":  {expr: "$.store.book[?(@.author == 'Herman Melville')].price", expected: []interface{}{8.99}},
+		"filter logical and":      {expr: "$.store.book[?(@.category == \"fiction\" && @.price > 20)].price", expected: []interface{}{22.99}},
+		"filter logical or":       {expr: "$.store.book[?(@.price < 9 || (@.price > 20))].price", expected: []interface{}{8.95, 8.99, 22.99}},
+		"filter root reference":   {expr: "$.store.book[?(@.price > $.expensive)].price", expected: []interface{}{12.99, 22.99}},
+		"filter regexp":           {expr: "$.store.book[?(@.author =~ /.*tolkien/i)].price", expected: []interface{}{22.99}},
+		"missing field":           {expr: "$.store.car", expected: nil},
+		"index out of range":      {expr: "$.store.book[10]", expected: nil},
+		"name on array":           {expr: "$.store.book.title", expected: nil},
+		"recursive wildcard root": {expr: "$..bicycle.price", expected: []interface{}{19.95}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path, err := compileJSONPath(test.expr)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, path.evaluate(doc))
+		})
+	}
+}
+
+func TestJSONPath_InvalidExpressions(t *testing.T) {
+	for _, expr := range []string{"", "$.", "$[", "$.store[?(@.price <)]", "$.book[?(@.a =~ 'x')]", "$.book[1:2:3]", "$.book['a", "$.a b"} {
+		_, err := compileJSONPath(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestJSONPath_Value(t *testing.T) {
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(jsonPathStore), &doc))
+
+	tests := map[string]struct {
+		expr            string
+		expected        interface{}
+		expectedPresent bool
+	}{
+		"singular":         {expr: "$.store.bicycle.color", expected: "red", expectedPresent: true},
+		"singular missing": {expr: "$.store.bicycle.size", expected: nil, expectedPresent: false},
+		"list":             {expr: "$.store.book[?(@.price > 20)].title", expected: []interface{}{"The Lord of the Rings"}, expectedPresent: true},
+		"empty list":       {expr: "$.store.book[?(@.price > 50)].title", expected: []interface{}{}, expectedPresent: false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path, err := compileJSONPath(test.expr)
+			assert.NoError(t, err)
+
+			value, present := path.value(doc)
+
+			assert.Equal(t, test.expected, value)
+			assert.Equal(t, test.expectedPresent, present)
+		})
+	}
+}
@@ -0,0 +1,707 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxSchemaDepth limits the number of nested references that are followed, e.g. by recursive schemas
+const maxSchemaDepth = 64
+
+// jsonSchema validates JSON values against a JSON Schema. It supports the keywords of draft-07 and 2020-12 that
+// describe the shape of values and the nullable keyword of OpenAPI 3.0. References are resolved against the document
+type jsonSchema struct {
+	document interface{}
+	schema   interface{}
+}
+
+// jsonSchemaError is a value that does not match the schema. The path is the JSON pointer of the value
+type jsonSchemaError struct {
+	path    string
+	message string
+}
+
+func (e jsonSchemaError) Error() string {
+	path := e.path
+	if path == "" {
+		path = "/"
+	}
+	return path + ": " + e.message
+}
+
+func newJSONSchema(document, schema interface{}) *jsonSchema {
+	return &jsonSchema{document: document, schema: schema}
+}
+
+// readJSONDocument reads a JSON or YAML document and converts it to the values produced by encoding/json
+func readJSONDocument(path string) (interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseJSONDocument(data)
+}
+
+func parseJSONDocument(data []byte) (interface{}, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	return jsonCompatible(document), nil
+}
+
+// jsonCompatible converts the maps and numbers decoded by yaml to the types used by encoding/json
+func jsonCompatible(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for k, item := range value {
+			out[k] = jsonCompatible(item)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := map[string]interface{}{}
+		for k, item := range value {
+			out[fmt.Sprint(k)] = jsonCompatible(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, item := range value {
+			out[i] = jsonCompatible(item)
+		}
+		return out
+	}
+	if f, ok := toFloat(v); ok {
+		return f
+	}
+	return v
+}
+
+func (s *jsonSchema) validate(value interface{}) []jsonSchemaError {
+	return s.validateValue(s.schema, normalizeJSON(value), "", 0)
+}
+
+func (s *jsonSchema) validateValue(schema, value interface{}, path string, depth int) []jsonSchemaError {
+	if depth > maxSchemaDepth {
+		return []jsonSchemaError{{path, "schema references are nested too deeply"}}
+	}
+
+	switch sch := schema.(type) {
+	case bool:
+		if !sch {
+			return []jsonSchemaError{{path, "value is not allowed"}}
+		}
+		return nil
+	case map[string]interface{}:
+		return s.validateKeywords(sch, value, path, depth)
+	}
+	return nil
+}
+
+func (s *jsonSchema) validateKeywords(schema map[string]interface{}, value interface{}, path string, depth int) []jsonSchemaError {
+	var errs []jsonSchemaError
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, jsonSchemaError{path, fmt.Sprintf(format, args...)})
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := s.resolve(ref)
+		if err != nil {
+			fail("%s", err)
+		} else {
+			errs = append(errs, s.validateValue(target, value, path, depth+1)...)
+		}
+	}
+
+	if value == nil && schema["nullable"] == true {
+		return errs
+	}
+
+	if t, ok := schema["type"]; ok {
+		types := schemaTypes(t)
+		if !matchesAnyType(types, value) {
+			fail("expected %s, got %s", strings.Join(types, " or "), jsonTypeOf(value))
+			return errs
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, item := range enum {
+			if jsonValuesEqual(item, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value %s is not one of %s", jsonString(value), jsonString(enum))
+		}
+	}
+
+	if constant, ok := schema["const"]; ok && !jsonValuesEqual(constant, value) {
+		fail("value %s is not %s", jsonString(value), jsonString(constant))
+	}
+
+	switch v := value.(type) {
+	case string:
+		errs = append(errs, validateString(schema, v, path)...)
+	case float64:
+		errs = append(errs, validateNumber(schema, v, path)...)
+	case []interface{}:
+		errs = append(errs, s.validateArray(schema, v, path, depth)...)
+	case map[string]interface{}:
+		errs = append(errs, s.validateObject(schema, v, path, depth)...)
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			errs = append(errs, s.validateValue(sub, value, path, depth+1)...)
+		}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		var anyErrs []jsonSchemaError
+		matched := false
+		for _, sub := range anyOf {
+			subErrs := s.validateValue(sub, value, path, depth+1)
+			if len(subErrs) == 0 {
+				matched = true
+				break
+			}
+			anyErrs = append(anyErrs, subErrs...)
+		}
+		if !matched {
+			fail("value does not match any schema of anyOf: %s", joinSchemaErrors(anyErrs))
+		}
+	}
+
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		matches := 0
+		var oneErrs []jsonSchemaError
+		for _, sub := range oneOf {
+			subErrs := s.validateValue(sub, value, path, depth+1)
+			if len(subErrs) == 0 {
+				matches++
+			}
+			oneErrs = append(oneErrs, subErrs...)
+		}
+		if matches == 0 {
+			fail("value does not match any schema of oneOf: %s", joinSchemaErrors(oneErrs))
+		} else if matches > 1 {
+			fail("value matches %d schemas of oneOf, expected exactly one", matches)
+		}
+	}
+
+	if not, ok := schema["not"]; ok && len(s.validateValue(not, value, path, depth+1)) == 0 {
+		fail("value must not match the schema of not")
+	}
+
+	if condition, ok := schema["if"]; ok {
+		if len(s.validateValue(condition, value, path, depth+1)) == 0 {
+			if then, ok := schema["then"]; ok {
+				errs = append(errs, s.validateValue(then, value, path, depth+1)...)
+			}
+		} else if otherwise, ok := schema["else"]; ok {
+			errs = append(errs, s.validateValue(otherwise, value, path, depth+1)...)
+		}
+	}
+
+	return errs
+}
+
+func validateString(schema map[string]interface{}, value string, path string) []jsonSchemaError {
+	var errs []jsonSchemaError
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, jsonSchemaError{path, fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(value)
+	if min, ok := toFloat(schema["minLength"]); ok && float64(length) < min {
+		fail("length %d is less than minLength %v", length, min)
+	}
+	if max, ok := toFloat(schema["maxLength"]); ok && float64(length) > max {
+		fail("length %d is greater than maxLength %v", length, max)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			fail("invalid pattern %s: %s", pattern, err)
+		} else if !re.MatchString(value) {
+			fail("value %q does not match pattern %s", value, pattern)
+		}
+	}
+	if format, ok := schema["format"].(string); ok && !matchesFormat(format, value) {
+		fail("value %q is not a valid %s", value, format)
+	}
+	return errs
+}
+
+func validateNumber(schema map[string]interface{}, value float64, path string) []jsonSchemaError {
+	var errs []jsonSchemaError
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, jsonSchemaError{path, fmt.Sprintf(format, args...)})
+	}
+
+	// OpenAPI 3.0 and draft-04 define exclusive bounds as booleans that apply to minimum and maximum
+	if min, ok := toFloat(schema["minimum"]); ok {
+		if schema["exclusiveMinimum"] == true && value <= min {
+			fail("value %v must be greater than %v", value, min)
+		} else if value < min {
+			fail("value %v is less than minimum %v", value, min)
+		}
+	}
+	if max, ok := toFloat(schema["maximum"]); ok {
+		if schema["exclusiveMaximum"] == true && value >= max {
+			fail("value %v must be less than %v", value, max)
+		} else if value > max {
+			fail("value %v is greater than maximum %v", value, max)
+		}
+	}
+	if min, ok := toFloat(schema["exclusiveMinimum"]); ok && value <= min {
+		fail("value %v must be greater than %v", value, min)
+	}
+	if max, ok := toFloat(schema["exclusiveMaximum"]); ok && value >= max {
+		fail("value %v must be less than %v", value, max)
+	}
+	if multipleOf, ok := toFloat(schema["multipleOf"]); ok && multipleOf > 0 {
+		quotient := value / multipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			fail("value %v is not a multiple of %v", value, multipleOf)
+		}
+	}
+	return errs
+}
+
+func (s *jsonSchema) validateArray(schema map[string]interface{}, value []interface{}, path string, depth int) []jsonSchemaError {
+	var errs []jsonSchemaError
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, jsonSchemaError{path, fmt.Sprintf(format, args...)})
+	}
+
+	if min, ok := toFloat(schema["minItems"]); ok && float64(len(value)) < min {
+		fail("array has %d items, expected at least %v", len(value), min)
+	}
+	if max, ok := toFloat(schema["maxItems"]); ok && float64(len(value)) > max {
+		fail("array has %d items, expected at most %v", len(value), max)
+	}
+	if schema["uniqueItems"] == true {
+		for i := range value {
+			for j := i + 1; j < len(value); j++ {
+				if jsonValuesEqual(value[i], value[j]) {
+					fail("items %d and %d are equal, expected unique items", i, j)
+				}
+			}
+		}
+	}
+
+	// positional schemas are defined by prefixItems in 2020-12 and by an array of items in draft-07
+	prefix, _ := schema["prefixItems"].([]interface{})
+	rest, hasRest := schema["items"]
+	if tuple, ok := rest.([]interface{}); ok {
+		prefix = tuple
+		rest, hasRest = schema["additionalItems"]
+	}
+	for i, item := range value {
+		itemPath := path + "/" + strconv.Itoa(i)
+		if i < len(prefix) {
+			errs = append(errs, s.validateValue(prefix[i], item, itemPath, depth+1)...)
+		} else if hasRest {
+			errs = append(errs, s.validateValue(rest, item, itemPath, depth+1)...)
+		}
+	}
+
+	if contains, ok := schema["contains"]; ok {
+		found := 0
+		for i, item := range value {
+			if len(s.validateValue(contains, item, path+"/"+strconv.Itoa(i), depth+1)) == 0 {
+				found++
+			}
+		}
+		min, hasMin := toFloat(schema["minContains"])
+		if !hasMin {
+			min = 1
+		}
+		if found == 0 && min > 0 {
+			fail("array does not contain an item matching the schema of contains")
+		} else if float64(found) < min {
+			fail("array contains %d items matching the schema of contains, expected at least %v", found, min)
+		}
+		if max, ok := toFloat(schema["maxContains"]); ok && float64(found) > max {
+			fail("array contains %d items matching the schema of contains, expected at most %v", found, max)
+		}
+	}
+	return errs
+}
+
+func (s *jsonSchema) validateObject(schema map[string]interface{}, value map[string]interface{}, path string, depth int) []jsonSchemaError {
+	var errs []jsonSchemaError
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, jsonSchemaError{path, fmt.Sprintf(format, args...)})
+	}
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if _, ok := value[fmt.Sprint(name)]; !ok {
+				fail("missing required property '%s'", name)
+			}
+		}
+	}
+	if min, ok := toFloat(schema["minProperties"]); ok && float64(len(value)) < min {
+		fail("object has %d properties, expected at least %v", len(value), min)
+	}
+	if max, ok := toFloat(schema["maxProperties"]); ok && float64(len(value)) > max {
+		fail("object has %d properties, expected at most %v", len(value), max)
+	}
+	// draft-07 defines both dependentRequired and dependentSchemas as dependencies
+	for _, keyword := range []string{"dependencies", "dependentRequired", "dependentSchemas"} {
+		dependencies, _ := schema[keyword].(map[string]interface{})
+		for _, name := range sortedKeys(dependencies) {
+			if _, ok := value[name]; !ok {
+				continue
+			}
+			list, ok := dependencies[name].([]interface{})
+			if !ok {
+				errs = append(errs, s.validateValue(dependencies[name], value, path, depth+1)...)
+				continue
+			}
+			for _, dependency := range list {
+				if _, ok := value[fmt.Sprint(dependency)]; !ok {
+					fail("property '%s' is required by property '%s'", dependency, name)
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	patternProperties, _ := schema["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := schema["additionalProperties"]
+	propertyNames, hasPropertyNames := schema["propertyNames"]
+
+	for _, name := range sortedKeys(value) {
+		propertyPath := path + "/" + escapeJSONPointer(name)
+		if hasPropertyNames {
+			for _, err := range s.validateValue(propertyNames, name, propertyPath, depth+1) {
+				fail("property name '%s' is invalid: %s", name, err.message)
+			}
+		}
+
+		matched := false
+		if property, ok := properties[name]; ok {
+			matched = true
+			errs = append(errs, s.validateValue(property, value[name], propertyPath, depth+1)...)
+		}
+		for pattern, property := range patternProperties {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				fail("invalid pattern %s: %s", pattern, err)
+				continue
+			}
+			if re.MatchString(name) {
+				matched = true
+				errs = append(errs, s.validateValue(property, value[name], propertyPath, depth+1)...)
+			}
+		}
+		if !matched && hasAdditional {
+			if additional == false {
+				errs = append(errs, jsonSchemaError{propertyPath, fmt.Sprintf("property '%s' is not allowed", name)})
+			} else {
+				errs = append(errs, s.validateValue(additional, value[name], propertyPath, depth+1)...)
+			}
+		}
+	}
+	return errs
+}
+
+// resolve returns the schema referenced by a JSON pointer within the document, e.g. #/components/schemas/User
+func (s *jsonSchema) resolve(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported reference %s, only references within the document are supported", ref)
+	}
+	return resolveJSONPointer(s.document, strings.TrimPrefix(ref, "#"))
+}
+
+func resolveJSONPointer(document interface{}, pointer string) (interface{}, error) {
+	pointer, err := url.PathUnescape(pointer)
+	if err != nil {
+		return nil, err
+	}
+	node := document
+	if pointer == "" {
+		return node, nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		switch value := node.(type) {
+		case map[string]interface{}:
+			next, ok := value[token]
+			if !ok {
+				return nil, fmt.Errorf("reference #%s not found", pointer)
+			}
+			node = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(value) {
+				return nil, fmt.Errorf("reference #%s not found", pointer)
+			}
+			node = value[i]
+		default:
+			return nil, fmt.Errorf("reference #%s not found", pointer)
+		}
+	}
+	return node, nil
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+// example returns an example of a value that matches the schema. Examples and defaults declared by the schema are
+// preferred, otherwise a value is generated from the type and constraints of the schema
+func (s *jsonSchema) example() interface{} {
+	return s.exampleOf(s.schema, 0)
+}
+
+func (s *jsonSchema) exampleOf(schema interface{}, depth int) interface{} {
+	m, ok := schema.(map[string]interface{})
+	if !ok || depth > maxSchemaDepth {
+		return nil
+	}
+
+	if ref, ok := m["$ref"].(string); ok {
+		target, err := s.resolve(ref)
+		if err != nil {
+			return nil
+		}
+		return s.exampleOf(target, depth+1)
+	}
+	if example, ok := m["example"]; ok {
+		return example
+	}
+	if examples, ok := m["examples"].([]interface{}); ok && len(examples) > 0 {
+		return examples[0]
+	}
+	if def, ok := m["default"]; ok {
+		return def
+	}
+	if constant, ok := m["const"]; ok {
+		return constant
+	}
+	if enum, ok := m["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		if schemas, ok := m[keyword].([]interface{}); ok && len(schemas) > 0 {
+			return s.exampleOf(schemas[0], depth+1)
+		}
+	}
+
+	var typ string
+	for _, t := range schemaTypes(m["type"]) {
+		if t != "null" {
+			typ = t
+			break
+		}
+	}
+	if typ == "" {
+		if _, ok := m["properties"]; ok {
+			typ = "object"
+		} else if _, ok := m["items"]; ok {
+			typ = "array"
+		} else if _, ok := m["allOf"]; ok {
+			typ = "object"
+		}
+	}
+
+	switch typ {
+	case "object":
+		object := map[string]interface{}{}
+		if allOf, ok := m["allOf"].([]interface{}); ok {
+			for _, sub := range allOf {
+				if subObject, ok := s.exampleOf(sub, depth+1).(map[string]interface{}); ok {
+					for k, v := range subObject {
+						object[k] = v
+					}
+				}
+			}
+		}
+		if properties, ok := m["properties"].(map[string]interface{}); ok {
+			for name, property := range properties {
+				object[name] = s.exampleOf(property, depth+1)
+			}
+		}
+		return object
+	case "array":
+		count := 1
+		if min, ok := toFloat(m["minItems"]); ok && min > 1 {
+			count = int(min)
+		}
+		array := make([]interface{}, count)
+		for i := range array {
+			array[i] = s.exampleOf(m["items"], depth+1)
+		}
+		return array
+	case "string":
+		return exampleString(m)
+	case "integer", "number":
+		return exampleNumber(m, typ == "integer")
+	case "boolean":
+		return true
+	}
+	return nil
+}
+
+func exampleString(schema map[string]interface{}) string {
+	switch schema["format"] {
+	case "date-time":
+		return "2020-01-01T00:00:00Z"
+	case "date":
+		return "2020-01-01"
+	case "time":
+		return "00:00:00Z"
+	case "email":
+		return "user@example.com"
+	case "uuid":
+		return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+	case "uri", "url":
+		return "https://example.com"
+	case "hostname":
+		return "example.com"
+	case "ipv4":
+		return "192.0.2.1"
+	case "ipv6":
+		return "2001:db8::1"
+	case "byte":
+		return "c3RyaW5n"
+	}
+	value := "string"
+	if min, ok := toFloat(schema["minLength"]); ok && int(min) > len(value) {
+		value += strings.Repeat("s", int(min)-len(value))
+	}
+	if max, ok := toFloat(schema["maxLength"]); ok && int(max) < len(value) {
+		value = value[:int(max)]
+	}
+	return value
+}
+
+func exampleNumber(schema map[string]interface{}, integer bool) float64 {
+	value := 0.0
+	if min, ok := toFloat(schema["minimum"]); ok {
+		value = min
+		if schema["exclusiveMinimum"] == true {
+			value++
+		}
+	} else if min, ok := toFloat(schema["exclusiveMinimum"]); ok {
+		value = min + 1
+	} else if max, ok := toFloat(schema["maximum"]); ok && max < value {
+		value = max
+	} else if max, ok := toFloat(schema["exclusiveMaximum"]); ok && max <= value {
+		value = max - 1
+	}
+	if integer {
+		value = math.Ceil(value)
+	}
+	return value
+}
+
+// schemaTypes returns the types of a schema, which is a single type or a list of types
+func schemaTypes(t interface{}) []string {
+	switch value := t.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var types []string
+		for _, item := range value {
+			types = append(types, fmt.Sprint(item))
+		}
+		return types
+	}
+	return nil
+}
+
+func matchesAnyType(types []string, value interface{}) bool {
+	actual := jsonTypeOf(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonTypeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// matchesFormat validates the common formats of strings. Unknown formats are not validated
+func matchesFormat(format, value string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "email":
+		address, err := mail.ParseAddress(value)
+		return err == nil && address.Address == value
+	case "uuid":
+		return uuidRegexp.MatchString(value)
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.IsAbs()
+	case "ipv4":
+		ip := net.ParseIP(value)
+		return ip != nil && ip.To4() != nil && !strings.Contains(value, ":")
+	case "ipv6":
+		return net.ParseIP(value) != nil && strings.Contains(value, ":")
+	}
+	return true
+}
+
+func jsonString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func joinSchemaErrors(errs []jsonSchemaError) string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
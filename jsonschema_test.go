@@ -0,0 +1,157 @@
+package apitest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONSchema_Validate(t *testing.T) {
+	document := map[string]interface{}{
+		"$defs": map[string]interface{}{
+			"id": map[string]interface{}{"type": "integer", "minimum": 1.0},
+		},
+	}
+	tests := map[string]struct {
+		schema         string
+		value          string
+		expectedErrors []string
+	}{
+		"type": {
+			schema:         `{"type": "string"}`,
+			value:          `12`,
+			expectedErrors: []string{"/: expected string, got integer"},
+		},
+		"integer": {
+			schema:         `{"type": "integer"}`,
+			value:          `1.5`,
+			expectedErrors: []string{"/: expected integer, got number"},
+		},
+		"nullable": {
+			schema: `{"type": "string", "nullable": true}`,
+			value:  `null`,
+		},
+		"multiple types": {
+			schema: `{"type": ["string", "null"]}`,
+			value:  `null`,
+		},
+		"enum": {
+			schema:         `{"enum": ["a", "b"]}`,
+			value:          `"c"`,
+			expectedErrors: []string{`/: value "c" is not one of ["a","b"]`},
+		},
+		"string constraints": {
+			schema:         `{"type": "string", "minLength": 3, "pattern": "^[a-z]+$"}`,
+			value:          `"A"`,
+			expectedErrors: []string{"/: length 1 is less than minLength 3", `/: value "A" does not match pattern ^[a-z]+$`},
+		},
+		"format": {
+			schema:         `{"type": "string", "format": "date-time"}`,
+			value:          `"yesterday"`,
+			expectedErrors: []string{`/: value "yesterday" is not a valid date-time`},
+		},
+		"number constraints": {
+			schema:         `{"type": "number", "minimum": 1, "exclusiveMaximum": 10, "multipleOf": 0.5}`,
+			value:          `10`,
+			expectedErrors: []string{"/: value 10 must be less than 10"},
+		},
+		"boolean exclusive minimum": {
+			schema:         `{"type": "number", "minimum": 1, "exclusiveMinimum": true}`,
+			value:          `1`,
+			expectedErrors: []string{"/: value 1 must be greater than 1"},
+		},
+		"object": {
+			schema: `{"type": "object", "required": ["id", "name"], "additionalProperties": false,
+				"properties": {"id": {"$ref": "#/$defs/id"}, "tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true}}}`,
+			value: `{"id": 0, "tags": ["a", 1, "a"], "extra/field": true}`,
+			expectedErrors: []string{
+				"/: missing required property 'name'",
+				"/extra~1field: property 'extra/field' is not allowed",
+				"/id: value 0 is less than minimum 1",
+				"/tags: items 0 and 2 are equal, expected unique items",
+				"/tags/1: expected string, got integer",
+			},
+		},
+		"tuple": {
+			schema:         `{"type": "array", "prefixItems": [{"type": "string"}], "items": false}`,
+			value:          `["a", "b"]`,
+			expectedErrors: []string{"/1: value is not allowed"},
+		},
+		"contains": {
+			schema:         `{"type": "array", "contains": {"type": "integer"}, "minContains": 2, "maxContains": 2}`,
+			value:          `[1, "a"]`,
+			expectedErrors: []string{"/: array contains 1 items matching the schema of contains, expected at least 2"},
+		},
+		"dependencies": {
+			schema: `{"dependencies": {"card": ["billing"]}, "dependentSchemas": {"card": {"properties": {"card": {"type": "string"}}}}}`,
+			value:  `{"card": 1234}`,
+			expectedErrors: []string{
+				"/: property 'billing' is required by property 'card'",
+				"/card: expected string, got integer",
+			},
+		},
+		"oneOf": {
+			schema:         `{"oneOf": [{"type": "integer"}, {"type": "number"}]}`,
+			value:          `1`,
+			expectedErrors: []string{"/: value matches 2 schemas of oneOf, expected exactly one"},
+		},
+		"anyOf": {
+			schema:         `{"anyOf": [{"type": "integer"}, {"type": "boolean"}]}`,
+			value:          `"a"`,
+			expectedErrors: []string{"/: value does not match any schema of anyOf: /: expected integer, got string; /: expected boolean, got string"},
+		},
+		"if then else": {
+			schema:         `{"if": {"properties": {"type": {"const": "card"}}}, "then": {"required": ["number"]}}`,
+			value:          `{"type": "card"}`,
+			expectedErrors: []string{"/: missing required property 'number'"},
+		},
+		"unresolved reference": {
+			schema:         `{"$ref": "#/$defs/missing"}`,
+			value:          `1`,
+			expectedErrors: []string{"/: reference #/$defs/missing not found"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			schema, err := parseJSONDocument([]byte(test.schema))
+			assert.NoError(t, err)
+			value, err := parseJSONDocument([]byte(test.value))
+			assert.NoError(t, err)
+
+			errs := newJSONSchema(document, schema).validate(value)
+
+			var messages []string
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+			assert.Equal(t, test.expectedErrors, messages)
+		})
+	}
+}
+
+func TestJSONSchema_Example(t *testing.T) {
+	schema, err := parseJSONDocument([]byte(`{
+		"type": "object",
+		"allOf": [{"properties": {"id": {"type": "string", "format": "uuid"}}}],
+		"properties": {
+			"name": {"type": "string", "example": "jan"},
+			"age": {"type": "integer", "minimum": 18},
+			"status": {"enum": ["active", "inactive"]},
+			"tags": {"type": "array", "items": {"type": "string"}, "minItems": 2},
+			"active": {"type": "boolean", "default": false}
+		}
+	}`))
+	assert.NoError(t, err)
+
+	example := newJSONSchema(schema, schema).example()
+
+	assert.Equal(t, map[string]interface{}{
+		"id":     "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+		"name":   "jan",
+		"age":    18.0,
+		"status": "active",
+		"tags":   []interface{}{"string", "string"},
+		"active": false,
+	}, example)
+	assert.Empty(t, newJSONSchema(schema, schema).validate(example))
+}
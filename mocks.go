@@ -2,19 +2,21 @@ package apitest
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"net/http/httputil"
 	"net/textproto"
 	"net/url"
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
@@ -30,6 +32,9 @@ type Transport struct {
 	httpClient               *http.Client
 	observers                []Observe
 	apiTest                  *APITest
+	cassette                 *Cassette
+	passthrough              bool
+	passthroughHosts         []string
 }
 
 func newTransport(
@@ -40,6 +45,11 @@ func newTransport(
 	observers []Observe,
 	apiTest *APITest) *Transport {
 
+	states := newMockStates()
+	for _, mock := range mocks {
+		mock.states = states
+	}
+
 	t := &Transport{
 		mocks:                    mocks,
 		httpClient:               httpClient,
@@ -114,13 +124,16 @@ func (r *Transport) RoundTrip(req *http.Request) (mockResponse *http.Response, m
 
 	matchedResponse, matchErrors := matches(req, r.mocks)
 	if matchErrors == nil {
-		res := buildResponseFromMock(matchedResponse)
-		res.Request = req
-
 		if matchedResponse.timeout {
 			return nil, timeoutError{}
 		}
 
+		res, err := buildResponseFromMock(matchedResponse, req)
+		if err != nil {
+			return nil, err
+		}
+		res.Request = req
+
 		if r.mockResponseDelayEnabled && matchedResponse.fixedDelayMillis > 0 {
 			time.Sleep(time.Duration(matchedResponse.fixedDelayMillis) * time.Millisecond)
 		}
@@ -128,6 +141,20 @@ func (r *Transport) RoundTrip(req *http.Request) (mockResponse *http.Response, m
 		return res, nil
 	}
 
+	if r.cassette != nil && r.cassette.recording() {
+		return r.cassette.record(r.nativeTransport, withoutMockRouting(req))
+	}
+
+	if r.allowsPassthrough(req) {
+		req = withoutMockRouting(req)
+		req = req.WithContext(context.WithValue(req.Context(), passthroughKey{}, true))
+		native := r.nativeTransport
+		if native == nil {
+			native = http.DefaultTransport
+		}
+		return native.RoundTrip(req)
+	}
+
 	if r.debugEnabled {
 		fmt.Printf("failed to match mocks. Errors: %s\n", matchErrors)
 	}
@@ -135,14 +162,38 @@ func (r *Transport) RoundTrip(req *http.Request) (mockResponse *http.Response, m
 	return nil, matchErrors
 }
 
+type passthroughKey struct{}
+
+// allowsPassthrough reports whether a request that did not match any mock can be sent to the real service
+func (r *Transport) allowsPassthrough(req *http.Request) bool {
+	if !r.passthrough {
+		return false
+	}
+	if len(r.passthroughHosts) == 0 {
+		return true
+	}
+	for _, host := range r.passthroughHosts {
+		if host == req.URL.Host || host == req.URL.Hostname() {
+			return true
+		}
+	}
+	return false
+}
+
+// isPassthrough reports whether the request was sent to the real service instead of a mock
+func isPassthrough(req *http.Request) bool {
+	passthrough, _ := req.Context().Value(passthroughKey{}).(bool)
+	return passthrough
+}
+
 func debugMock(res *http.Response, req *http.Request) {
-	requestDump, err := httputil.DumpRequestOut(req, true)
+	requestDump, err := dumpRequest(req, true)
 	if err == nil {
 		debugLog(requestDebugPrefix, "request to mock", string(requestDump))
 	}
 
 	if res != nil {
-		responseDump, err := httputil.DumpResponse(res, true)
+		responseDump, err := dumpResponse(res)
 		if err == nil {
 			debugLog(responseDebugPrefix, "response from mock", string(responseDump))
 		}
@@ -169,34 +220,55 @@ func (r *Transport) Reset() {
 	http.DefaultTransport = r.nativeTransport
 }
 
-func buildResponseFromMock(mockResponse *MockResponse) *http.Response {
+func buildResponseFromMock(mockResponse *MockResponse, req *http.Request) (*http.Response, error) {
 	if mockResponse == nil {
-		return nil
+		return nil, nil
 	}
 
-	contentTypeHeader := mockResponse.headers["Content-Type"]
-	var contentType string
+	if mockResponse.responseFunc != nil {
+		res, err := mockResponse.responseFunc(copyHttpRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return completeResponseFromFunc(res)
+	}
 
-	// if the content type isn't set and the body contains json, set content type as json
-	if len(mockResponse.body) > 0 {
-		if len(contentTypeHeader) == 0 {
-			if json.Valid([]byte(mockResponse.body)) {
-				contentType = "application/json"
-			} else {
-				contentType = "text/plain"
-			}
-		} else {
-			contentType = contentTypeHeader[0]
+	body := mockResponse.body
+	if mockResponse.bodyFunc != nil {
+		body = mockResponse.bodyFunc(copyHttpRequest(req))
+	}
+
+	var templateData MockTemplateData
+	if mockResponse.bodyTemplate != nil || len(mockResponse.headerTemplates) > 0 {
+		templateData = newMockTemplateData(copyHttpRequest(req))
+	}
+
+	if mockResponse.bodyTemplate != nil {
+		var err error
+		if body, err = executeMockTemplate(mockResponse.bodyTemplate, templateData); err != nil {
+			return nil, err
 		}
 	}
 
 	res := &http.Response{
-		Body:          ioutil.NopCloser(strings.NewReader(mockResponse.body)),
-		Header:        mockResponse.headers,
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		Header:        http.Header{},
 		StatusCode:    mockResponse.statusCode,
 		ProtoMajor:    1,
 		ProtoMinor:    1,
-		ContentLength: int64(len(mockResponse.body)),
+		ContentLength: int64(len(body)),
+	}
+
+	for key, values := range mockResponse.headers {
+		res.Header[key] = append([]string(nil), values...)
+	}
+
+	for _, header := range mockResponse.headerTemplates {
+		value, err := executeMockTemplate(header.template, templateData)
+		if err != nil {
+			return nil, err
+		}
+		res.Header.Set(header.key, value)
 	}
 
 	for _, cookie := range mockResponse.cookies {
@@ -205,23 +277,82 @@ func buildResponseFromMock(mockResponse *MockResponse) *http.Response {
 		}
 	}
 
-	if contentType != "" {
+	if contentType := inferContentType(res.Header, []byte(body)); contentType != "" {
 		res.Header.Set("Content-Type", contentType)
 	}
 
-	return res
+	return res, nil
+}
+
+// completeResponseFromFunc fills in the fields of a response created by a mock response function
+// that the http client relies upon, e.g. the protocol version and the content length
+func completeResponseFromFunc(res *http.Response) (*http.Response, error) {
+	if res == nil {
+		return nil, errors.New("mock response function returned a nil response")
+	}
+
+	if res.Header == nil {
+		res.Header = http.Header{}
+	}
+
+	if res.ProtoMajor == 0 {
+		res.ProtoMajor = 1
+		res.ProtoMinor = 1
+	}
+
+	var body []byte
+	if res.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+
+	if contentType := inferContentType(res.Header, body); contentType != "" {
+		res.Header.Set("Content-Type", contentType)
+	}
+
+	return res, nil
+}
+
+// inferContentType returns the content type of the body. If the content type isn't set and the body contains json,
+// the content type is json
+func inferContentType(header http.Header, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		return contentType
+	}
+	if json.Valid(body) {
+		return "application/json"
+	}
+	return "text/plain"
 }
 
+// unlimitedTimes is used as the upper bound of mocks that can be invoked any number of times
+const unlimitedTimes = -1
+
 // Mock represents the entire interaction for a mock to be used for testing
 type Mock struct {
 	m               *sync.Mutex
-	isUsed          bool
+	calls           int
 	request         *MockRequest
 	response        *MockResponse
+	sequence        []*MockResponse
 	httpClient      *http.Client
 	debugStandalone bool
-	times           int
+	label           string
+	minTimes        int
+	maxTimes        int
 	timesSet        bool
+	scenario        string
+	requiredState   string
+	newState        string
+	states          *mockStates
 }
 
 // Matches checks whether the given request matches the mock
@@ -239,6 +370,7 @@ func (m *Mock) copy() *Mock {
 	newMock := *m
 
 	newMock.m = &sync.Mutex{}
+	newMock.calls = 0
 
 	req := *m.request
 	newMock.request = &req
@@ -246,6 +378,12 @@ func (m *Mock) copy() *Mock {
 	res := *m.response
 	newMock.response = &res
 
+	newMock.sequence = make([]*MockResponse, len(m.sequence))
+	for i := range m.sequence {
+		seqRes := *m.sequence[i]
+		newMock.sequence[i] = &seqRes
+	}
+
 	return &newMock
 }
 
@@ -262,6 +400,7 @@ type MockRequest struct {
 	formData           map[string][]string
 	formDataPresent    []string
 	formDataNotPresent []string
+	multipart          []multipartPart
 	query              map[string][]string
 	queryPresent       []string
 	queryNotPresent    []string
@@ -269,12 +408,71 @@ type MockRequest struct {
 	cookiePresent      []string
 	cookieNotPresent   []string
 	body               string
+	jsonSchemas        []*jsonSchema
+	jsonPaths          []jsonPathAssertion
+	xmlBody            string
 	matchers           []Matcher
 }
 
+func (r *MockRequest) describe() string {
+	if r.url == nil {
+		return r.method
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s", r.method, r.url.String()))
+}
+
 // UnmatchedMock exposes some information about mocks that failed to match a request
 type UnmatchedMock struct {
-	URL url.URL
+	// Index is the position of the mock in the list of mocks provided to the test, starting at 1
+	Index    int
+	Label    string
+	Method   string
+	URL      url.URL
+	Headers  map[string][]string
+	Query    map[string][]string
+	FormData map[string][]string
+	Body     string
+	Calls    int
+}
+
+func newUnmatchedMock(index int, m *Mock) UnmatchedMock {
+	unmatchedMock := UnmatchedMock{
+		Index:    index,
+		Label:    m.label,
+		Method:   m.request.method,
+		Headers:  m.request.headers,
+		Query:    m.request.query,
+		FormData: m.request.formData,
+		Body:     m.request.body,
+		Calls:    m.calls,
+	}
+	if m.request.url != nil {
+		unmatchedMock.URL = *m.request.url
+	}
+	return unmatchedMock
+}
+
+// String returns a human readable description of the unmatched mock
+func (u UnmatchedMock) String() string {
+	var strBuilder strings.Builder
+	strBuilder.WriteString(fmt.Sprintf("mock %d", u.Index))
+	if u.Label != "" {
+		strBuilder.WriteString(fmt.Sprintf(" (%s)", u.Label))
+	}
+	strBuilder.WriteString(fmt.Sprintf(": %s %s", u.Method, u.URL.String()))
+	if len(u.Headers) > 0 {
+		strBuilder.WriteString(fmt.Sprintf(", headers %v", u.Headers))
+	}
+	if len(u.Query) > 0 {
+		strBuilder.WriteString(fmt.Sprintf(", query %v", u.Query))
+	}
+	if len(u.FormData) > 0 {
+		strBuilder.WriteString(fmt.Sprintf(", form data %v", u.FormData))
+	}
+	if u.Body != "" {
+		strBuilder.WriteString(fmt.Sprintf(", body %s", u.Body))
+	}
+	return strBuilder.String()
 }
 
 // MockResponse represents the http response side of a mock interaction
@@ -284,6 +482,10 @@ type MockResponse struct {
 	headers          map[string][]string
 	cookies          []*Cookie
 	body             string
+	bodyFunc         func(*http.Request) string
+	bodyTemplate     *template.Template
+	headerTemplates  []headerTemplate
+	responseFunc     func(*http.Request) (*http.Response, error)
 	statusCode       int
 	fixedDelayMillis int64
 }
@@ -332,8 +534,8 @@ func (r *StandaloneMocks) End() func() {
 // NewMock create a new mock, ready for configuration using the builder pattern
 func NewMock() *Mock {
 	mock := &Mock{
-		m:     &sync.Mutex{},
-		times: 1,
+		m:        &sync.Mutex{},
+		maxTimes: 1,
 	}
 	mock.request = &MockRequest{
 		mock:     mock,
@@ -356,6 +558,32 @@ func (m *Mock) Debug() *Mock {
 	return m
 }
 
+// Label names the mock. The label is used in test failures and unmatched mock reports
+func (m *Mock) Label(label string) *Mock {
+	m.label = label
+	return m
+}
+
+// InScenario adds the mock to the named scenario. A scenario is a state machine shared by the mocks of a test,
+// allowing the same request to receive different responses, e.g. 503, then 503, then 200.
+// Every scenario starts in MockStateStarted and the state is reset for each test run
+func (m *Mock) InScenario(name string) *Mock {
+	m.scenario = name
+	return m
+}
+
+// WhenState configures the mock to only match when its scenario is in the given state
+func (m *Mock) WhenState(state string) *Mock {
+	m.requiredState = state
+	return m
+}
+
+// WillSetState configures the mock to move its scenario to the given state when it matches a request
+func (m *Mock) WillSetState(state string) *Mock {
+	m.newState = state
+	return m
+}
+
 // HttpClient allows the developer to provide a custom http client when using mocks
 func (m *Mock) HttpClient(cli *http.Client) *Mock {
 	m.httpClient = cli
@@ -444,28 +672,144 @@ func (m *Mock) Method(method string) *MockRequest {
 }
 
 func matches(req *http.Request, mocks []*Mock) (*MockResponse, error) {
+	// the scenario states are locked while matching so that concurrent requests observe consistent transitions
+	states := statesOf(mocks)
+	states.Lock()
+	defer states.Unlock()
+
 	mockError := newUnmatchedMockError()
+	var exhausted []int
 	for mockNumber, mock := range mocks {
-		mock.m.Lock() // lock is for isUsed when matches is called concurrently by RoundTripper
-		if mock.isUsed {
-			mock.m.Unlock()
-			continue
-		}
-
+		mock.m.Lock() // lock is for calls when matches is called concurrently by RoundTripper
 		errs := mock.Matches(req)
 		if len(errs) == 0 {
-			mock.isUsed = true
+			if err := mock.matchesState(states); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) == 0 {
+			if mock.exhausted() {
+				exhausted = append(exhausted, mockNumber)
+				mock.m.Unlock()
+				continue
+			}
+			mock.calls++
+			if mock.scenario != "" && mock.newState != "" {
+				states.current[mock.scenario] = mock.newState
+			}
+			response := mock.responseForCall(mock.calls)
 			mock.m.Unlock()
-			return mock.response, nil
+			return response, nil
 		}
 
 		mockError = mockError.addErrors(mockNumber+1, errs...)
 		mock.m.Unlock()
 	}
 
+	// the request is only attributed to mocks that have been invoked too often if no other mock could serve it
+	for _, mockNumber := range exhausted {
+		mock := mocks[mockNumber]
+		mock.m.Lock()
+		mock.calls++
+		mockError = mockError.addErrors(mockNumber+1,
+			fmt.Errorf("mock expected %s calls, received %d", mock.expectedCalls(), mock.calls))
+		mock.m.Unlock()
+	}
+
 	return nil, mockError
 }
 
+// MockStateStarted is the initial state of every mock scenario, see Mock.InScenario
+const MockStateStarted = "Started"
+
+// mockStates holds the current state of the scenarios of the mocks used by a single test run
+type mockStates struct {
+	sync.Mutex
+	current map[string]string
+}
+
+func newMockStates() *mockStates {
+	return &mockStates{current: map[string]string{}}
+}
+
+func (s *mockStates) state(scenario string) string {
+	if state, ok := s.current[scenario]; ok {
+		return state
+	}
+	return MockStateStarted
+}
+
+// statesOf returns the scenario states shared by the mocks. Mocks that are matched outside of a transport get fresh states
+func statesOf(mocks []*Mock) *mockStates {
+	for _, mock := range mocks {
+		if mock.states != nil {
+			return mock.states
+		}
+	}
+	return newMockStates()
+}
+
+func (m *Mock) matchesState(states *mockStates) error {
+	if m.scenario == "" || m.requiredState == "" {
+		return nil
+	}
+	if state := states.state(m.scenario); state != m.requiredState {
+		return fmt.Errorf("scenario '%s' is in state '%s', expected state '%s'", m.scenario, state, m.requiredState)
+	}
+	return nil
+}
+
+// responseForCall returns the response for the nth call of the mock, starting at 1.
+// Once the sequence of responses has ended the last response is repeated
+func (m *Mock) responseForCall(call int) *MockResponse {
+	if call <= 1 || len(m.sequence) == 0 {
+		return m.response
+	}
+	if call-2 < len(m.sequence) {
+		return m.sequence[call-2]
+	}
+	return m.sequence[len(m.sequence)-1]
+}
+
+func (m *Mock) exhausted() bool {
+	return m.maxTimes != unlimitedTimes && m.calls >= m.maxTimes
+}
+
+// pending reports whether the mock has not received all of the requests it expects.
+// Mocks that explicitly allow zero invocations, e.g. AnyTimes, are never pending
+func (m *Mock) pending() bool {
+	if m.timesSet {
+		return m.calls < m.minTimes
+	}
+	return m.calls == 0
+}
+
+func (m *Mock) name(index int) string {
+	if m.label != "" {
+		return fmt.Sprintf("mock %d (%s)", index, m.label)
+	}
+	return fmt.Sprintf("mock %d", index)
+}
+
+func (m *Mock) satisfied() bool {
+	return m.calls >= m.minTimes && (m.maxTimes == unlimitedTimes || m.calls <= m.maxTimes)
+}
+
+func (m *Mock) expectedCalls() string {
+	switch {
+	case m.minTimes == m.maxTimes:
+		return strconv.Itoa(m.minTimes)
+	case m.maxTimes == unlimitedTimes && m.minTimes == 0:
+		return "any number of"
+	case m.maxTimes == unlimitedTimes:
+		return fmt.Sprintf("at least %d", m.minTimes)
+	case m.minTimes == 0:
+		return fmt.Sprintf("at most %d", m.maxTimes)
+	default:
+		return fmt.Sprintf("between %d and %d", m.minTimes, m.maxTimes)
+	}
+}
+
 // Body configures the mock request to match the given body
 func (r *MockRequest) Body(b string) *MockRequest {
 	r.body = b
@@ -501,6 +845,42 @@ func (r *MockRequest) JSON(v interface{}) *MockRequest {
 	return r
 }
 
+// XML configures the mock request to match XML bodies that are equivalent to the given document, ignoring whitespace
+// between elements, the order of attributes and namespace prefixes. If the parameter is not a string or a byte slice
+// it is marshalled using encoding/xml
+func (r *MockRequest) XML(v interface{}) *MockRequest {
+	body, err := marshalXML(v)
+	if err != nil {
+		panic(err)
+	}
+	canonical, err := canonicalXML([]byte(body))
+	if err != nil {
+		panic(err)
+	}
+	r.xmlBody = canonical
+	return r
+}
+
+// JSONSchema configures the mock request to match bodies that are valid against the given JSON schema
+func (r *MockRequest) JSONSchema(schema string) *MockRequest {
+	document, err := parseJSONDocument([]byte(schema))
+	if err != nil {
+		panic(err)
+	}
+	r.jsonSchemas = append(r.jsonSchemas, newJSONSchema(document, document))
+	return r
+}
+
+// JSONPath selects a value from the JSON body of the mock request using the given expression and returns a builder to
+// match the value, e.g. JSONPath("$.name").Equal("jan")
+func (r *MockRequest) JSONPath(expr string) *MockRequestJSONPath {
+	path, err := compileJSONPath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return &MockRequestJSONPath{request: r, expr: expr, path: path}
+}
+
 // Header configures the mock request to match the given header
 func (r *MockRequest) Header(key, value string) *MockRequest {
 	normalizedKey := textproto.CanonicalMIMEHeaderKey(key)
@@ -554,6 +934,28 @@ func (r *MockRequest) FormDataNotPresent(key string) *MockRequest {
 	return r
 }
 
+// MultipartFormData configures the mock request to match the values of a field of a multipart/form-data body. Regular
+// expressions are allowed as values
+func (r *MockRequest) MultipartFormData(name string, values ...string) *MockRequest {
+	for _, value := range values {
+		r.multipart = append(r.multipart, multipartPart{field: name, value: value})
+	}
+	return r
+}
+
+// MultipartFile configures the mock request to match files uploaded in a field of a multipart/form-data body. A file
+// matches if its name and content are equal to the name and content of the given file
+func (r *MockRequest) MultipartFile(field string, paths ...string) *MockRequest {
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		r.multipart = append(r.multipart, multipartPart{field: field, file: path, content: content})
+	}
+	return r
+}
+
 // Query configures the mock request to match a query param
 func (r *MockRequest) Query(key, value string) *MockRequest {
 	r.query[key] = append(r.query[key], value)
@@ -619,6 +1021,14 @@ func (r *MockRequest) RespondWith() *MockResponse {
 	return r.mock.response
 }
 
+// RespondWithFunc finalises the mock request phase of set up and computes the response from the received request.
+// If the returned response does not define a content type, it is inferred from the body.
+// Returning an error causes the http client to receive the error
+func (r *MockRequest) RespondWithFunc(fn func(*http.Request) (*http.Response, error)) *MockResponse {
+	r.mock.response.responseFunc = fn
+	return r.mock.response
+}
+
 // Timeout forces the mock to return a http timeout
 func (r *MockResponse) Timeout() *MockResponse {
 	r.timeout = true
@@ -659,6 +1069,12 @@ func (r *MockResponse) Body(body string) *MockResponse {
 	return r
 }
 
+// BodyFunc sets the mock response body computed from the received request
+func (r *MockResponse) BodyFunc(fn func(*http.Request) string) *MockResponse {
+	r.bodyFunc = fn
+	return r
+}
+
 // Bodyf sets the mock response body. Supports formatting
 func (r *MockResponse) Bodyf(format string, args ...interface{}) *MockResponse {
 	return r.Body(fmt.Sprintf(format, args...))
@@ -688,6 +1104,17 @@ func (r *MockResponse) JSON(v interface{}) *MockResponse {
 	return r
 }
 
+// XML is a convenience method for setting the mock response body and content type header as "application/xml".
+// If the parameter is not a string or a byte slice it is marshalled using encoding/xml
+func (r *MockResponse) XML(v interface{}) *MockResponse {
+	body, err := marshalXML(v)
+	if err != nil {
+		panic(err)
+	}
+	r.body = body
+	return r.Header("Content-Type", "application/xml")
+}
+
 // Status respond with the given status
 func (r *MockResponse) Status(statusCode int) *MockResponse {
 	r.statusCode = statusCode
@@ -702,13 +1129,69 @@ func (r *MockResponse) FixedDelay(delay int64) *MockResponse {
 	return r
 }
 
-// Times respond the given number of times
+// Times respond the given number of times. The test fails if the mock is not invoked exactly this many times
 func (r *MockResponse) Times(times int) *MockResponse {
-	r.mock.times = times
+	r.mock.minTimes = times
+	r.mock.maxTimes = times
 	r.mock.timesSet = true
 	return r
 }
 
+// AnyTimes respond to any number of requests, including none
+func (r *MockResponse) AnyTimes() *MockResponse {
+	r.mock.minTimes = 0
+	r.mock.maxTimes = unlimitedTimes
+	r.mock.timesSet = true
+	return r
+}
+
+// AtLeast respond to any number of requests. The test fails if the mock is invoked fewer than the given number of times.
+// Use AtMost after AtLeast to also define an upper bound
+func (r *MockResponse) AtLeast(times int) *MockResponse {
+	r.mock.minTimes = times
+	r.mock.maxTimes = unlimitedTimes
+	r.mock.timesSet = true
+	return r
+}
+
+// AtMost respond to up to the given number of requests
+func (r *MockResponse) AtMost(times int) *MockResponse {
+	r.mock.maxTimes = times
+	r.mock.timesSet = true
+	return r
+}
+
+// Never fails the test if the mock is invoked
+func (r *MockResponse) Never() *MockResponse {
+	r.mock.minTimes = 0
+	r.mock.maxTimes = 0
+	r.mock.timesSet = true
+	return r
+}
+
+// ThenRespondWith adds a response to the sequence of responses returned by the mock. Each call of the mock receives
+// the next response in the sequence, e.g. a timeout, then a 500 and then a 200.
+// Unless the number of calls is defined using Times or similar, the mock responds once to each response in the sequence
+func (r *MockResponse) ThenRespondWith() *MockResponse {
+	next := &MockResponse{
+		mock:    r.mock,
+		headers: map[string][]string{},
+	}
+	r.mock.sequence = append(r.mock.sequence, next)
+	if !r.mock.timesSet {
+		r.mock.maxTimes = len(r.mock.sequence) + 1
+	}
+	return next
+}
+
+// RepeatLast keeps returning the last response of the sequence after the sequence has ended
+func (r *MockResponse) RepeatLast() *MockResponse {
+	if !r.mock.timesSet {
+		r.mock.maxTimes = unlimitedTimes
+	}
+	return r
+}
+
 // End finalise the response definition phase in order for the mock to be used
 func (r *MockResponse) End() *Mock {
 	return r.mock
@@ -1064,6 +1547,59 @@ var bodyMatcher = func(req *http.Request, spec *MockRequest) error {
 	return fmt.Errorf("received body did not match expected mock body\n%s", diff(mockBody, bodyStr))
 }
 
+var jsonSchemaMatcher = func(req *http.Request, spec *MockRequest) error {
+	if len(spec.jsonSchemas) == 0 {
+		return nil
+	}
+
+	value, err := requestJSON(req)
+	if err != nil {
+		return err
+	}
+	for _, schema := range spec.jsonSchemas {
+		if errs := schema.validate(value); len(errs) > 0 {
+			return fmt.Errorf("received body did not match mock JSON schema: %s", joinSchemaErrors(errs))
+		}
+	}
+	return nil
+}
+
+var jsonPathMatcher = func(req *http.Request, spec *MockRequest) error {
+	if len(spec.jsonPaths) == 0 {
+		return nil
+	}
+
+	value, err := requestJSON(req)
+	if err != nil {
+		return err
+	}
+	for _, assertion := range spec.jsonPaths {
+		if err := assertion.assert(value); err != nil {
+			return fmt.Errorf("received body did not match mock %s", err)
+		}
+	}
+	return nil
+}
+
+var xmlMatcher = func(req *http.Request, spec *MockRequest) error {
+	if spec.xmlBody == "" {
+		return nil
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+	received, err := canonicalXML(body)
+	if err != nil {
+		return fmt.Errorf("received body is not valid XML: %s", err)
+	}
+	if received != spec.xmlBody {
+		return fmt.Errorf("received body did not match expected mock XML body\n%s", diff(spec.xmlBody, received))
+	}
+	return nil
+}
+
 func errorOrNil(statement bool, errorMessage func() string) error {
 	if statement {
 		return nil
@@ -1086,7 +1622,11 @@ var defaultMatchers = []Matcher{
 	formDataMatcher,
 	formDataPresentMatcher,
 	formDataNotPresentMatcher,
+	multipartMatcher,
 	bodyMatcher,
+	jsonSchemaMatcher,
+	jsonPathMatcher,
+	xmlMatcher,
 	cookieMatcher,
 	cookiePresentMatcher,
 	cookieNotPresentMatcher,
@@ -0,0 +1,349 @@
+package apitest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	mockDefinition struct {
+		Label    string                 `yaml:"label"`
+		Request  mockRequestDefinition  `yaml:"request"`
+		Response mockResponseDefinition `yaml:"response"`
+	}
+
+	mockRequestDefinition struct {
+		Method             string                  `yaml:"method"`
+		URL                string                  `yaml:"url"`
+		Headers            map[string]stringValues `yaml:"headers"`
+		HeaderPresent      []string                `yaml:"headerPresent"`
+		HeaderNotPresent   []string                `yaml:"headerNotPresent"`
+		Query              map[string]stringValues `yaml:"query"`
+		QueryPresent       []string                `yaml:"queryPresent"`
+		QueryNotPresent    []string                `yaml:"queryNotPresent"`
+		FormData           map[string]stringValues `yaml:"formData"`
+		FormDataPresent    []string                `yaml:"formDataPresent"`
+		FormDataNotPresent []string                `yaml:"formDataNotPresent"`
+		Cookies            map[string]string       `yaml:"cookies"`
+		CookiePresent      []string                `yaml:"cookiePresent"`
+		CookieNotPresent   []string                `yaml:"cookieNotPresent"`
+		Body               string                  `yaml:"body"`
+		BodyFile           string                  `yaml:"bodyFile"`
+		BasicAuth          *basicAuthDefinition    `yaml:"basicAuth"`
+	}
+
+	basicAuthDefinition struct {
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	}
+
+	mockResponseDefinition struct {
+		Status           int                     `yaml:"status"`
+		Headers          map[string]stringValues `yaml:"headers"`
+		HeaderTemplates  map[string]string       `yaml:"headerTemplates"`
+		Cookies          map[string]string       `yaml:"cookies"`
+		Body             string                  `yaml:"body"`
+		BodyFile         string                  `yaml:"bodyFile"`
+		BodyTemplate     string                  `yaml:"bodyTemplate"`
+		BodyTemplateFile string                  `yaml:"bodyTemplateFile"`
+		Delay            string                  `yaml:"delay"`
+		Timeout          bool                    `yaml:"timeout"`
+		Times            *int                    `yaml:"times"`
+	}
+
+	// stringValues is a single string or a list of strings
+	stringValues []string
+
+	// definitionError is an invalid value in a mock definition. The path is the location of the value in the mock
+	definitionError struct {
+		path    []string
+		message string
+	}
+)
+
+func (v *stringValues) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*v = stringValues{node.Value}
+		return nil
+	}
+	var values []string
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+	*v = values
+	return nil
+}
+
+func (e *definitionError) Error() string {
+	return e.message
+}
+
+func newDefinitionError(message string, path ...string) *definitionError {
+	return &definitionError{path: path, message: message}
+}
+
+var yamlLineError = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// MocksFromFile loads mocks from a JSON or YAML file containing a list of mock definitions. Each definition has
+// a request, which supports the matchers of MockRequest, and a response, which supports body and header templates,
+// see MockResponse.BodyTemplate. Body files are relative to the mock file.
+// MocksFromFile panics if the file is invalid. The error refers to the line of the file containing the invalid value
+func MocksFromFile(path string) []*Mock {
+	mocks, err := mocksFromFile(path)
+	if err != nil {
+		panic(err)
+	}
+	return mocks
+}
+
+func mocksFromFile(path string) ([]*Mock, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var definitions []mockDefinition
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&definitions); err != nil && err != io.EOF {
+		return nil, fileLineErrors(path, err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fileLineErrors(path, err)
+	}
+
+	var mocks []*Mock
+	for i, definition := range definitions {
+		mock, err := definition.newMock(filepath.Dir(path))
+		if err != nil {
+			var defErr *definitionError
+			if errors.As(err, &defErr) {
+				node := document.Content[0].Content[i]
+				return nil, fmt.Errorf("%s:%d: %s", path, nodeLine(node, defErr.path...), defErr.message)
+			}
+			return nil, err
+		}
+		mocks = append(mocks, mock)
+	}
+	return mocks, nil
+}
+
+// fileLineErrors rewrites the line numbers of yaml errors to refer to the file, e.g. mocks.yaml:12: ...
+func fileLineErrors(path string, err error) error {
+	var messages []string
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+
+	var out bytes.Buffer
+	for i, message := range messages {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		if match := yamlLineError.FindStringSubmatch(message); match != nil {
+			out.WriteString(fmt.Sprintf("%s:%s: %s", path, match[1], match[2]))
+			continue
+		}
+		out.WriteString(fmt.Sprintf("%s: %s", path, message))
+	}
+	return errors.New(out.String())
+}
+
+// nodeLine returns the line of the value at the given path of a mapping node, or the line of the closest parent
+func nodeLine(node *yaml.Node, path ...string) int {
+	for _, key := range path {
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return node.Line
+}
+
+func (d mockDefinition) newMock(dir string) (*Mock, error) {
+	req := d.Request
+	if req.Method == "" {
+		return nil, newDefinitionError("request method is required", "request")
+	}
+	if req.URL == "" {
+		return nil, newDefinitionError("request url is required", "request")
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, newDefinitionError(fmt.Sprintf("invalid request url: %s", err), "request", "url")
+	}
+	if _, err := regexp.Compile(u.Path); err != nil {
+		return nil, newDefinitionError(fmt.Sprintf("invalid request url path regexp: %s", err), "request", "url")
+	}
+	if req.Body != "" && req.BodyFile != "" {
+		return nil, newDefinitionError("request body and bodyFile cannot both be defined", "request", "bodyFile")
+	}
+
+	mock := NewMock().Label(d.Label)
+	mockRequest := mock.Method(req.Method)
+	mock.request.url = u
+
+	for key, values := range req.Headers {
+		for _, value := range values {
+			if _, err := regexp.Compile(value); err != nil {
+				return nil, newDefinitionError(fmt.Sprintf("invalid regexp for header %s: %s", key, err), "request", "headers", key)
+			}
+			mockRequest.Header(key, value)
+		}
+	}
+	for key, values := range req.Query {
+		for _, value := range values {
+			if _, err := regexp.Compile(value); err != nil {
+				return nil, newDefinitionError(fmt.Sprintf("invalid regexp for query param %s: %s", key, err), "request", "query", key)
+			}
+			mockRequest.Query(key, value)
+		}
+	}
+	for key, values := range req.FormData {
+		mockRequest.FormData(key, values...)
+	}
+	for name, value := range req.Cookies {
+		mockRequest.Cookie(name, value)
+	}
+	for _, key := range req.HeaderPresent {
+		mockRequest.HeaderPresent(key)
+	}
+	for _, key := range req.HeaderNotPresent {
+		mockRequest.HeaderNotPresent(key)
+	}
+	for _, key := range req.QueryPresent {
+		mockRequest.QueryPresent(key)
+	}
+	for _, key := range req.QueryNotPresent {
+		mockRequest.QueryNotPresent(key)
+	}
+	for _, key := range req.FormDataPresent {
+		mockRequest.FormDataPresent(key)
+	}
+	for _, key := range req.FormDataNotPresent {
+		mockRequest.FormDataNotPresent(key)
+	}
+	for _, name := range req.CookiePresent {
+		mockRequest.CookiePresent(name)
+	}
+	for _, name := range req.CookieNotPresent {
+		mockRequest.CookieNotPresent(name)
+	}
+	if req.BasicAuth != nil {
+		mockRequest.BasicAuth(req.BasicAuth.Username, req.BasicAuth.Password)
+	}
+
+	body := req.Body
+	if req.BodyFile != "" {
+		b, err := ioutil.ReadFile(filepath.Join(dir, req.BodyFile))
+		if err != nil {
+			return nil, newDefinitionError(err.Error(), "request", "bodyFile")
+		}
+		body = string(b)
+	}
+	mockRequest.Body(body)
+
+	res := d.Response
+	var bodies []string
+	for key, value := range map[string]string{"body": res.Body, "bodyFile": res.BodyFile, "bodyTemplate": res.BodyTemplate, "bodyTemplateFile": res.BodyTemplateFile} {
+		if value != "" {
+			bodies = append(bodies, key)
+		}
+	}
+	if len(bodies) > 1 {
+		sort.Strings(bodies)
+		return nil, newDefinitionError(fmt.Sprintf("response %s and %s cannot both be defined", bodies[0], bodies[1]), "response", bodies[1])
+	}
+	if res.Status == 0 {
+		res.Status = http.StatusOK
+	}
+	if http.StatusText(res.Status) == "" {
+		return nil, newDefinitionError("invalid response status "+strconv.Itoa(res.Status), "response", "status")
+	}
+
+	mockResponse := mockRequest.RespondWith().Status(res.Status)
+	for key, values := range res.Headers {
+		for _, value := range values {
+			mockResponse.Header(key, value)
+		}
+	}
+	for key, text := range res.HeaderTemplates {
+		tmpl, err := newMockTemplate(key, text)
+		if err != nil {
+			return nil, newDefinitionError(fmt.Sprintf("invalid header template: %s", err), "response", "headerTemplates", key)
+		}
+		mockResponse.headerTemplates = append(mockResponse.headerTemplates, headerTemplate{key: textproto.CanonicalMIMEHeaderKey(key), template: tmpl})
+	}
+	for name, value := range res.Cookies {
+		mockResponse.Cookie(name, value)
+	}
+
+	body = res.Body
+	if res.BodyFile != "" {
+		b, err := ioutil.ReadFile(filepath.Join(dir, res.BodyFile))
+		if err != nil {
+			return nil, newDefinitionError(err.Error(), "response", "bodyFile")
+		}
+		body = string(b)
+	}
+	mockResponse.Body(body)
+
+	text, field := res.BodyTemplate, "bodyTemplate"
+	if res.BodyTemplateFile != "" {
+		b, err := ioutil.ReadFile(filepath.Join(dir, res.BodyTemplateFile))
+		if err != nil {
+			return nil, newDefinitionError(err.Error(), "response", "bodyTemplateFile")
+		}
+		text, field = string(b), "bodyTemplateFile"
+	}
+	if text != "" {
+		tmpl, err := newMockTemplate("body", text)
+		if err != nil {
+			return nil, newDefinitionError(fmt.Sprintf("invalid body template: %s", err), "response", field)
+		}
+		mockResponse.bodyTemplate = tmpl
+	}
+
+	if res.Delay != "" {
+		delay, err := time.ParseDuration(res.Delay)
+		if err != nil {
+			return nil, newDefinitionError(fmt.Sprintf("invalid response delay: %s", err), "response", "delay")
+		}
+		mockResponse.FixedDelay(delay.Milliseconds())
+	}
+	if res.Timeout {
+		mockResponse.Timeout()
+	}
+	if res.Times != nil {
+		if *res.Times < 0 {
+			return nil, newDefinitionError("response times cannot be negative", "response", "times")
+		}
+		mockResponse.Times(*res.Times)
+	}
+
+	return mockResponse.End(), nil
+}
@@ -0,0 +1,190 @@
+package apitest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMocksFromFile_YAML(t *testing.T) {
+	mocks := MocksFromFile("testdata/mocks/payments.yaml")
+
+	assert.Len(t, mocks, 3)
+
+	getPayment := mocks[0]
+	assert.Equal(t, "get payment", getPayment.label)
+	assert.Equal(t, http.MethodGet, getPayment.request.method)
+	assert.Equal(t, "/payment/[0-9]+", getPayment.request.url.Path)
+	assert.Equal(t, map[string][]string{"Authorization": {"Bearer .+"}, "Accept": {"application/json"}}, getPayment.request.headers)
+	assert.Equal(t, []string{"X-Request-Id"}, getPayment.request.headerPresent)
+	assert.Equal(t, []string{"X-Debug"}, getPayment.request.headerNotPresent)
+	assert.Equal(t, map[string][]string{"expand": {"customer"}}, getPayment.request.query)
+	assert.Equal(t, []string{"version"}, getPayment.request.queryPresent)
+	assert.Equal(t, []string{"debug"}, getPayment.request.queryNotPresent)
+	assert.Equal(t, []Cookie{*NewCookie("session").Value("abc")}, getPayment.request.cookie)
+	assert.Equal(t, []string{"tracking"}, getPayment.request.cookiePresent)
+	assert.Equal(t, []string{"legacy"}, getPayment.request.cookieNotPresent)
+	assert.Equal(t, http.StatusOK, getPayment.response.statusCode)
+	assert.Equal(t, map[string][]string{"X-Payment-Id": {"1234"}}, getPayment.response.headers)
+	assert.Equal(t, []*Cookie{NewCookie("seen").Value("true")}, getPayment.response.cookies)
+	assert.JSONEq(t, `{"id": "1234", "amount": 100}`, getPayment.response.body)
+	assert.Equal(t, int64(10), getPayment.response.fixedDelayMillis)
+	assert.Equal(t, 2, getPayment.maxTimes)
+
+	createPayment := mocks[1]
+	assert.Equal(t, map[string][]string{"amount": {"100"}, "currency": {"GBP"}}, createPayment.request.formData)
+	assert.Equal(t, []string{"reference"}, createPayment.request.formDataPresent)
+	assert.Equal(t, []string{"test"}, createPayment.request.formDataNotPresent)
+	assert.Equal(t, "admin", createPayment.request.basicAuthUsername)
+	assert.Equal(t, "secret", createPayment.request.basicAuthPassword)
+	assert.Equal(t, http.StatusCreated, createPayment.response.statusCode)
+	assert.Equal(t, `{"id": "5678"}`, createPayment.response.body)
+
+	deletePayment := mocks[2]
+	assert.True(t, deletePayment.response.timeout)
+}
+
+func TestMocksFromFile_JSON(t *testing.T) {
+	mocks := MocksFromFile("testdata/mocks/payments.json")
+
+	assert.Len(t, mocks, 1)
+	assert.Equal(t, `{"a": 1}`, mocks[0].request.body)
+	assert.Equal(t, http.StatusNotFound, mocks[0].response.statusCode)
+}
+
+func TestMocksFromFile_MatchesRequests(t *testing.T) {
+	mocks := MocksFromFile("testdata/mocks/payments.yaml")
+	req, _ := http.NewRequest(http.MethodPost, "http://payments/payment",
+		strings.NewReader(url.Values{"amount": {"100"}, "currency": {"GBP"}, "reference": {"abc"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("admin", "secret")
+
+	mockResponse, err := matches(req, mocks)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, mockResponse.statusCode)
+}
+
+func TestMocksFromFile_Templates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mocks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mocks.yaml")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "user.tmpl"), []byte(`{"id": "{{index .PathSegments 1}}"}`), 0644))
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`
+- request:
+    method: POST
+    url: http://users/user
+  response:
+    status: 201
+    bodyTemplate: '{"name": "{{.JSON.name}}"}'
+    headerTemplates:
+      Location: /user/{{.JSON.name}}
+- request:
+    method: GET
+    url: http://users/user/[0-9]+
+  response:
+    bodyTemplateFile: user.tmpl
+`), 0644))
+	mocks := MocksFromFile(path)
+
+	tests := map[string]struct {
+		req              *http.Request
+		expectedBody     string
+		expectedLocation string
+	}{
+		"body and header template": {
+			req:              httptest.NewRequest(http.MethodPost, "http://users/user", strings.NewReader(`{"name": "jan"}`)),
+			expectedBody:     `{"name": "jan"}`,
+			expectedLocation: "/user/jan",
+		},
+		"body template file": {
+			req:          httptest.NewRequest(http.MethodGet, "http://users/user/1234", nil),
+			expectedBody: `{"id": "1234"}`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockResponse, err := matches(test.req, mocks)
+			assert.NoError(t, err)
+
+			response, err := buildResponseFromMock(mockResponse, test.req)
+
+			assert.NoError(t, err)
+			body, _ := ioutil.ReadAll(response.Body)
+			assert.JSONEq(t, test.expectedBody, string(body))
+			assert.Equal(t, test.expectedLocation, response.Header.Get("Location"))
+		})
+	}
+}
+
+func TestMocksFromFile_InvalidDefinitions(t *testing.T) {
+	tests := map[string]struct {
+		definition    string
+		expectedError string
+	}{
+		"unknown field": {
+			definition:    "- request:\n    method: GET\n    url: /user\n  response:\n    stauts: 200\n",
+			expectedError: "mocks.yaml:5: field stauts not found in type apitest.mockResponseDefinition",
+		},
+		"invalid type": {
+			definition:    "- request:\n    method: GET\n    url: /user\n  response:\n    status: ok\n",
+			expectedError: "mocks.yaml:5: cannot unmarshal !!str `ok` into int",
+		},
+		"missing method": {
+			definition:    "- request:\n    url: /user\n- request:\n    url: /user\n",
+			expectedError: "mocks.yaml:2: request method is required",
+		},
+		"invalid path regexp": {
+			definition:    "- request:\n    method: GET\n    url: /user\n- request:\n    method: GET\n    url: /user/[0-9\n",
+			expectedError: "mocks.yaml:6: invalid request url path regexp: error parsing regexp: missing closing ]: `[0-9`",
+		},
+		"invalid delay": {
+			definition:    "- request:\n    method: GET\n    url: /user\n  response:\n    delay: soon\n",
+			expectedError: "mocks.yaml:5: invalid response delay: time: invalid duration",
+		},
+		"missing body file": {
+			definition:    "- request:\n    method: GET\n    url: /user\n  response:\n    bodyFile: missing.json\n",
+			expectedError: "mocks.yaml:5: open ",
+		},
+		"body and body template": {
+			definition:    "- request:\n    method: GET\n    url: /user\n  response:\n    body: a\n    bodyTemplate: b\n",
+			expectedError: "mocks.yaml:6: response body and bodyTemplate cannot both be defined",
+		},
+		"invalid body template": {
+			definition:    "- request:\n    method: GET\n    url: /user\n  response:\n    bodyTemplate: '{{.Name'\n",
+			expectedError: "mocks.yaml:5: invalid body template: template: body:1: unclosed action",
+		},
+		"invalid syntax": {
+			definition:    "- request:\n    method: GET\n  url: [\n",
+			expectedError: "mocks.yaml:3: did not find expected node content",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "mocks")
+			assert.NoError(t, err)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "mocks.yaml")
+			assert.NoError(t, ioutil.WriteFile(path, []byte(test.definition), 0644))
+
+			_, err = mocksFromFile(path)
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
+
+func TestMocksFromFile_PanicsIfInvalid(t *testing.T) {
+	assert.Panics(t, func() {
+		MocksFromFile("testdata/mocks/missing.yaml")
+	})
+}
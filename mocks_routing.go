@@ -0,0 +1,76 @@
+package apitest
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+type mockRoutingKey struct{}
+
+var (
+	mockRoutes        = &routingTransport{transports: map[string]*Transport{}}
+	installMockRoutes sync.Once
+	mockRoutingIDs    uint64
+)
+
+// routingTransport dispatches each request to the transport of the test that owns the request. It is installed
+// as http.DefaultTransport once so that tests using mocks do not replace the global transport while they run
+type routingTransport struct {
+	m               sync.RWMutex
+	transports      map[string]*Transport
+	nativeTransport http.RoundTripper
+}
+
+// RoundTrip routes the request to the mocks of the test identified by the request context. Requests that do not
+// belong to a test are sent using the original http.DefaultTransport
+func (r *routingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id, _ := req.Context().Value(mockRoutingKey{}).(string)
+
+	r.m.RLock()
+	transport, ok := r.transports[id]
+	r.m.RUnlock()
+
+	if ok {
+		return transport.RoundTrip(req)
+	}
+	return r.nativeTransport.RoundTrip(req)
+}
+
+func (r *routingTransport) add(id string, transport *Transport) {
+	installMockRoutes.Do(func() {
+		r.nativeTransport = http.DefaultTransport
+		http.DefaultTransport = r
+	})
+	transport.nativeTransport = r.nativeTransport
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.transports[id] = transport
+}
+
+func (r *routingTransport) remove(id string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	delete(r.transports, id)
+}
+
+func newMockRoutingID() string {
+	return strconv.FormatUint(atomic.AddUint64(&mockRoutingIDs, 1), 10)
+}
+
+// withMockRoutingID adds the routing id to the context of the request. The id is not sent as a header, so that the
+// handler, reports and downstream services do not see it
+func withMockRoutingID(req *http.Request, id string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), mockRoutingKey{}, id))
+}
+
+// withoutMockRouting removes the routing id from a request that is sent to the real service, so that the request is
+// not routed back to the mocks
+func withoutMockRouting(req *http.Request) *http.Request {
+	if id, _ := req.Context().Value(mockRoutingKey{}).(string); id == "" {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), mockRoutingKey{}, ""))
+}
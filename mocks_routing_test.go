@@ -0,0 +1,172 @@
+package apitest_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelMocks_RoutesRequestsToTheMocksOfEachTest(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 5; i++ {
+			req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, "http://localhost:8080/user", nil)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			data, _ := ioutil.ReadAll(res.Body)
+			_, _ = w.Write(data)
+		}
+	})
+
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("user %d", i)
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			apitest.New().
+				ParallelMocks().
+				Mocks(apitest.NewMock().
+					Get("http://localhost:8080/user").
+					RespondWith().
+					Body(name).
+					Status(http.StatusOK).
+					Times(5).
+					End()).
+				Handler(handler).
+				Get("/").
+				Expect(t).
+				Status(http.StatusOK).
+				Body(strings.Repeat(name, 5)).
+				End()
+		})
+	}
+}
+
+func TestParallelMocks_DoesNotChangeTheInboundRequest(t *testing.T) {
+	apitest.New().
+		ParallelMocks().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Header.Get("X-Apitest-Id")))
+		}).
+		Get("/").
+		Expect(t).
+		Status(http.StatusOK).
+		Body("").
+		End()
+}
+
+func TestParallelMocks_SendsRequestsOutsideOfTestsToTheNetwork(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("network"))
+	}))
+	defer srv.Close()
+
+	apitest.New().
+		ParallelMocks().
+		Mocks(apitest.NewMock().
+			Get(srv.URL).
+			RespondWith().
+			Body("mock").
+			Status(http.StatusOK).
+			AnyTimes().
+			End()).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := http.Get(srv.URL)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			data, _ := ioutil.ReadAll(res.Body)
+			_, _ = w.Write(data)
+		}).
+		Get("/").
+		Expect(t).
+		Status(http.StatusOK).
+		Body("network").
+		End()
+}
+
+func TestParallelMocks_SendsPassthroughRequestsToTheNetwork(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("real" + r.Header.Get("X-Apitest-Id")))
+	}))
+	defer srv.Close()
+
+	for i := 0; i < 2; i++ {
+		apitest.New().
+			ParallelMocks().
+			MockPassthrough().
+			Mocks(apitest.NewMock().
+				Get(srv.URL + "/user").
+				RespondWith().
+				Body("mock").
+				Status(http.StatusOK).
+				End()).
+			HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, path := range []string{"/user", "/order"} {
+					req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, srv.URL+path, nil)
+					res, err := http.DefaultClient.Do(req)
+					if err != nil {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					data, _ := ioutil.ReadAll(res.Body)
+					_, _ = w.Write(data)
+				}
+			}).
+			Get("/").
+			Expect(t).
+			Status(http.StatusOK).
+			Body("mockreal").
+			End()
+	}
+}
+
+func TestParallelMocks_RecordsCassettesFromTheNetwork(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("real" + r.Header.Get("X-Apitest-Id")))
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "cassette")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 2; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("user%d.json", i))
+
+		apitest.New().
+			ParallelMocks().
+			Cassette(apitest.NewCassette(path).Mode(apitest.CassetteRecord)).
+			HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, srv.URL+"/user", nil)
+				res, err := http.DefaultClient.Do(req)
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				data, _ := ioutil.ReadAll(res.Body)
+				_, _ = w.Write(data)
+			}).
+			Get("/").
+			Expect(t).
+			Status(http.StatusOK).
+			Body("real").
+			End()
+
+		data, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), srv.URL+"/user")
+		assert.NotContains(t, string(data), "X-Apitest-Id")
+	}
+}
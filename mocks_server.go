@@ -0,0 +1,144 @@
+package apitest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// MockServer serves mocks over a real network connection. Use it when the mocks cannot be provided by replacing
+// the transport of an http client, e.g. when the application under test creates its own transport or runs
+// in a separate process. Mocks are matched in the same way as mocks that are provided to the APITest
+type MockServer struct {
+	server    *httptest.Server
+	transport *Transport
+	mocks     []*Mock
+	closed    chan struct{}
+	closeOnce sync.Once
+	m         sync.Mutex
+	apiTest   *APITest
+}
+
+// NewMockServer creates and starts an http server that responds to requests using the given mocks.
+// The mocks should define a path without a host, e.g. Get("/user"), since the host is the address of the server
+func NewMockServer(mocks ...*Mock) *MockServer {
+	s := newMockServer(mocks)
+	s.server = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSMockServer creates and starts an https server that responds to requests using the given mocks.
+// Use Client to obtain an http client that trusts the certificate of the server
+func NewTLSMockServer(mocks ...*Mock) *MockServer {
+	s := newMockServer(mocks)
+	s.server = httptest.NewTLSServer(s)
+	return s
+}
+
+func newMockServer(mocks []*Mock) *MockServer {
+	s := &MockServer{closed: make(chan struct{})}
+	for i := range mocks {
+		s.mocks = append(s.mocks, mocks[i].copy())
+	}
+	s.transport = newTransport(s.mocks, nil, false, true, []Observe{s.observe}, nil)
+	return s
+}
+
+// Debug logs to the console the http wire representation of the requests received by the server
+func (s *MockServer) Debug() *MockServer {
+	s.transport.debugEnabled = true
+	return s
+}
+
+// URL returns the base url of the server, e.g. http://127.0.0.1:54321
+func (s *MockServer) URL() string {
+	return s.server.URL
+}
+
+// Client returns an http client that is configured to make requests to the server. For TLS servers the client
+// trusts the certificate of the server
+func (s *MockServer) Client() *http.Client {
+	return s.server.Client()
+}
+
+// UnmatchedMocks returns the mocks of the server that have not received all of the requests that they expect
+func (s *MockServer) UnmatchedMocks() []UnmatchedMock {
+	var unmatchedMocks []UnmatchedMock
+	for i, m := range s.mocks {
+		m.m.Lock()
+		if m.pending() {
+			unmatchedMocks = append(unmatchedMocks, newUnmatchedMock(i+1, m))
+		}
+		m.m.Unlock()
+	}
+	return unmatchedMocks
+}
+
+// Close shuts down the server. Requests to mocks that time out are aborted
+func (s *MockServer) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+	s.server.Close()
+}
+
+// ServeHTTP responds to the request using the first matching mock. If no mock matches the request the server
+// responds with 404 Not Found and the reasons why each mock did not match
+func (s *MockServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	outReq := req.Clone(req.Context())
+	outReq.RequestURI = ""
+	outReq.URL.Host = req.Host
+	outReq.URL.Scheme = "http"
+	if req.TLS != nil {
+		outReq.URL.Scheme = "https"
+	}
+
+	res, err := s.transport.RoundTrip(outReq)
+	if err != nil {
+		switch err.(type) {
+		case timeoutError:
+			select {
+			case <-req.Context().Done():
+			case <-s.closed:
+			}
+			panic(http.ErrAbortHandler)
+		case *unmatchedMockError:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer res.Body.Close()
+
+	for key, values := range res.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(res.StatusCode)
+	_, _ = io.Copy(w, res.Body)
+}
+
+func (s *MockServer) attach(apiTest *APITest) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.apiTest = apiTest
+}
+
+func (s *MockServer) detach() {
+	s.attach(nil)
+}
+
+// observe forwards the interactions with the server to the mock observers of the attached APITest
+func (s *MockServer) observe(res *http.Response, req *http.Request, _ *APITest) {
+	s.m.Lock()
+	apiTest := s.apiTest
+	s.m.Unlock()
+	if apiTest == nil {
+		return
+	}
+	if apiTest.debugEnabled && !s.transport.debugEnabled {
+		debugMock(res, req)
+	}
+	for _, observe := range apiTest.mocksObservers {
+		observe(res, req, apiTest)
+	}
+}
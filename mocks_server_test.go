@@ -0,0 +1,132 @@
+package apitest_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/steinfletcher/apitest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockServer_RespondsUsingMocks(t *testing.T) {
+	server := apitest.NewMockServer(
+		apitest.NewMock().
+			Get("/user/[0-9]+").
+			Header("Authorization", "Bearer abc").
+			RespondWith().
+			Header("X-Request-Id", "123").
+			Body(`{"name": "jon"}`).
+			Status(http.StatusOK).
+			End(),
+	)
+	defer server.Close()
+
+	// a client with its own transport cannot be hijacked
+	cli := &http.Client{Transport: &http.Transport{}}
+	req, _ := http.NewRequest(http.MethodGet, server.URL()+"/user/1234", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+	res, err := cli.Do(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "123", res.Header.Get("X-Request-Id"))
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	data, _ := ioutil.ReadAll(res.Body)
+	assert.JSONEq(t, `{"name": "jon"}`, string(data))
+	assert.Empty(t, server.UnmatchedMocks())
+}
+
+func TestMockServer_RespondsNotFoundIfNoMockMatches(t *testing.T) {
+	server := apitest.NewMockServer(
+		apitest.NewMock().
+			Post("/user").
+			RespondWith().
+			Status(http.StatusCreated).
+			End(),
+	)
+	defer server.Close()
+
+	res, err := http.Get(server.URL() + "/user")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	data, _ := ioutil.ReadAll(res.Body)
+	assert.Equal(t, "received request did not match any mocks\n\nMock 1 mismatches:\n• received method GET did not match mock method POST\n\n\n",
+		string(data))
+	assert.Len(t, server.UnmatchedMocks(), 1)
+}
+
+func TestMockServer_TLS(t *testing.T) {
+	server := apitest.NewTLSMockServer(
+		apitest.NewMock().
+			Get("/user").
+			RespondWith().
+			Status(http.StatusNoContent).
+			End(),
+	)
+	defer server.Close()
+
+	assert.True(t, strings.HasPrefix(server.URL(), "https://"))
+	res, err := server.Client().Get(server.URL() + "/user")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+}
+
+func TestMockServer_Timeout(t *testing.T) {
+	server := apitest.NewMockServer(
+		apitest.NewMock().
+			Get("/user").
+			RespondWith().
+			Timeout().
+			End(),
+	)
+	defer server.Close()
+
+	cli := &http.Client{Timeout: 50 * time.Millisecond}
+	_, err := cli.Get(server.URL() + "/user")
+
+	assert.Error(t, err)
+}
+
+func TestMockServer_ReportsInteractions(t *testing.T) {
+	server := apitest.NewMockServer(
+		apitest.NewMock().
+			Get("/user").
+			RespondWith().
+			Body(`{"name": "jon"}`).
+			Status(http.StatusOK).
+			End(),
+	)
+	defer server.Close()
+	reporter := &RecorderCaptor{}
+
+	apitest.New().
+		MockServers(server).
+		Report(reporter).
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cli := &http.Client{Transport: &http.Transport{}}
+			res, err := cli.Get(server.URL() + "/user")
+			if err != nil {
+				panic(err)
+			}
+			data, _ := ioutil.ReadAll(res.Body)
+			_, _ = w.Write(data)
+		})).
+		Get("/").
+		Expect(t).
+		Status(http.StatusOK).
+		Body(`{"name": "jon"}`).
+		End()
+
+	events := reporter.capturedRecorder.Events
+	assert.Len(t, events, 4)
+	host := strings.TrimPrefix(server.URL(), "http://")
+	assert.Equal(t, `"`+host+`"`, events[1].(apitest.HttpRequest).Target)
+	assert.Equal(t, "/user", events[1].(apitest.HttpRequest).Value.URL.Path)
+	assert.Equal(t, http.StatusOK, events[2].(apitest.HttpResponse).Value.StatusCode)
+}
@@ -0,0 +1,146 @@
+package apitest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// MockTemplateData is the data available to mock response templates, see MockResponse.BodyTemplate
+type MockTemplateData struct {
+	// Method is the http method of the received request
+	Method string
+	// URL is the url of the received request
+	URL *url.URL
+	// Path is the path of the received request
+	Path string
+	// PathSegments are the non empty segments of the path, e.g. /user/1234 is [user 1234]
+	PathSegments []string
+	// Query are the query parameters of the received request
+	Query url.Values
+	// Headers are the headers of the received request
+	Headers http.Header
+	// Body is the raw body of the received request
+	Body string
+	// JSON is the body of the received request decoded as JSON. It is nil if the body is not valid JSON
+	JSON interface{}
+}
+
+var mockTemplateFuncs = template.FuncMap{
+	"uuid": func() (string, error) {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		b[6] = (b[6] & 0x0f) | 0x40
+		b[8] = (b[8] & 0x3f) | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+	},
+	"now": func(layout ...string) string {
+		if len(layout) > 0 {
+			return time.Now().UTC().Format(layout[0])
+		}
+		return time.Now().UTC().Format(time.RFC3339)
+	},
+	"randomInt": func(min, max int) (int, error) {
+		if max <= min {
+			return 0, fmt.Errorf("randomInt max %d must be greater than min %d", max, min)
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(max-min)))
+		if err != nil {
+			return 0, err
+		}
+		return min + int(n.Int64()), nil
+	},
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+func parseMockTemplate(name, text string) *template.Template {
+	return template.Must(newMockTemplate(name, text))
+}
+
+func newMockTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(mockTemplateFuncs).Parse(text)
+}
+
+func newMockTemplateData(req *http.Request) MockTemplateData {
+	data := MockTemplateData{
+		Method:  req.Method,
+		URL:     req.URL,
+		Path:    req.URL.Path,
+		Query:   req.URL.Query(),
+		Headers: req.Header,
+	}
+
+	for _, segment := range strings.Split(req.URL.Path, "/") {
+		if segment != "" {
+			data.PathSegments = append(data.PathSegments, segment)
+		}
+	}
+
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		if err == nil {
+			data.Body = string(body)
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+			var v interface{}
+			if decoder.Decode(&v) == nil {
+				data.JSON = v
+			}
+		}
+	}
+
+	return data
+}
+
+func executeMockTemplate(tmpl *template.Template, data MockTemplateData) (string, error) {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render mock response template: %s", err)
+	}
+	return out.String(), nil
+}
+
+// BodyTemplate sets the mock response body from a text/template rendered with the received request.
+// The template data is MockTemplateData, e.g. {{index .PathSegments 1}}, {{.Query.Get "page"}}, {{.JSON.name}}.
+// The functions uuid, now, randomInt and json are also available, e.g. {"id": "{{uuid}}", "created": "{{now}}"}
+func (r *MockResponse) BodyTemplate(text string) *MockResponse {
+	r.bodyTemplate = parseMockTemplate("body", text)
+	return r
+}
+
+// BodyTemplateFromFile sets the mock response body from a text/template file, see BodyTemplate
+func (r *MockResponse) BodyTemplateFromFile(f string) *MockResponse {
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		panic(err)
+	}
+	return r.BodyTemplate(string(b))
+}
+
+// HeaderTemplate respond with the given header where the value is a text/template, see BodyTemplate
+func (r *MockResponse) HeaderTemplate(key string, text string) *MockResponse {
+	r.headerTemplates = append(r.headerTemplates, headerTemplate{
+		key:      textproto.CanonicalMIMEHeaderKey(key),
+		template: parseMockTemplate(key, text),
+	})
+	return r
+}
+
+type headerTemplate struct {
+	key      string
+	template *template.Template
+}
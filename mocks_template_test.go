@@ -0,0 +1,109 @@
+package apitest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMocks_Response_BodyTemplate(t *testing.T) {
+	mockResponse := NewMock().
+		Post("/user/.+").
+		RespondWith().
+		BodyTemplate(`{"id": "{{index .PathSegments 1}}", "name": "{{.JSON.name}}", "page": "{{.Query.Get "page"}}", "auth": "{{.Headers.Get "Authorization"}}", "method": "{{.Method}}"}`).
+		Status(http.StatusOK)
+	req := httptest.NewRequest(http.MethodPost, "/user/1234?page=2", strings.NewReader(`{"name": "jan"}`))
+	req.Header.Set("Authorization", "Bearer abc")
+
+	response, err := buildResponseFromMock(mockResponse, req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	bytes, _ := ioutil.ReadAll(response.Body)
+	assert.JSONEq(t, `{"id": "1234", "name": "jan", "page": "2", "auth": "Bearer abc", "method": "POST"}`, string(bytes))
+	assert.Equal(t, int64(len(bytes)), response.ContentLength)
+	bytes, _ = ioutil.ReadAll(req.Body)
+	assert.JSONEq(t, `{"name": "jan"}`, string(bytes), "expected the request body to remain readable")
+}
+
+func TestMocks_Response_BodyTemplateFromFile(t *testing.T) {
+	mockResponse := NewMock().
+		Get("/user/.+").
+		RespondWith().
+		BodyTemplateFromFile("testdata/mock_response_template.json").
+		Status(http.StatusOK)
+
+	response, err := buildResponseFromMock(mockResponse, httptest.NewRequest(http.MethodGet, "/user/1234?page=3", nil))
+
+	assert.NoError(t, err)
+	bytes, _ := ioutil.ReadAll(response.Body)
+	assert.JSONEq(t, `{"id": "1234", "page": "3"}`, string(bytes))
+}
+
+func TestMocks_Response_BodyTemplate_Helpers(t *testing.T) {
+	mockResponse := NewMock().
+		Get("/user").
+		RespondWith().
+		BodyTemplate(`{{uuid}}|{{now}}|{{now "2006-01-02"}}|{{randomInt 5 10}}|{{json .Query}}`).
+		Status(http.StatusOK)
+
+	response, err := buildResponseFromMock(mockResponse, httptest.NewRequest(http.MethodGet, "/user?a=b", nil))
+
+	assert.NoError(t, err)
+	bytes, _ := ioutil.ReadAll(response.Body)
+	parts := strings.Split(string(bytes), "|")
+	assert.Len(t, parts, 5)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), parts[0])
+	_, err = time.Parse(time.RFC3339, parts[1])
+	assert.NoError(t, err)
+	assert.Equal(t, time.Now().UTC().Format("2006-01-02"), parts[2])
+	n, err := strconv.Atoi(parts[3])
+	assert.NoError(t, err)
+	assert.True(t, n >= 5 && n < 10)
+	assert.Equal(t, `{"a":["b"]}`, parts[4])
+}
+
+func TestMocks_Response_HeaderTemplate(t *testing.T) {
+	mockResponse := NewMock().
+		Post("/user").
+		RespondWith().
+		Header("Location", "/default").
+		HeaderTemplate("location", "/user/{{.JSON.id}}").
+		HeaderTemplate("X-Request-Id", `{{.Headers.Get "X-Request-Id"}}`).
+		Status(http.StatusCreated)
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"id": 42}`))
+	req.Header.Set("X-Request-Id", "abc")
+
+	response, err := buildResponseFromMock(mockResponse, req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/user/42"}, response.Header["Location"])
+	assert.Equal(t, "abc", response.Header.Get("X-Request-Id"))
+}
+
+func TestMocks_Response_BodyTemplate_PanicsOnInvalidTemplate(t *testing.T) {
+	assert.Panics(t, func() {
+		NewMock().Get("/user").RespondWith().BodyTemplate("{{.Path")
+	})
+}
+
+func TestMocks_Response_BodyTemplate_ReturnsRenderErrorToClient(t *testing.T) {
+	defer NewMock().
+		HttpClient(customCli).
+		Get("http://localhost:8080/path").
+		RespondWith().
+		BodyTemplate(`{{randomInt 10 1}}`).
+		EndStandalone()()
+
+	_, err := customCli.Get("http://localhost:8080/path")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to render mock response template")
+}
@@ -1,10 +1,12 @@
 package apitest
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -518,6 +520,41 @@ func TestMocks_FormDataMatcher(t *testing.T) {
 	}
 }
 
+func TestMocks_MultipartMatcher(t *testing.T) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("name", "John")
+	file, _ := w.CreateFormFile("file", "hello.txt")
+	_, _ = file.Write([]byte("hello world\n"))
+	_ = w.Close()
+
+	tests := map[string]struct {
+		mock          *MockRequest
+		expectedError error
+	}{
+		"matches": {
+			mock: NewMock().Post("/upload").MultipartFormData("name", "Jo").MultipartFile("file", "testdata/multipart/hello.txt"),
+		},
+		"value does not match": {
+			mock:          NewMock().Post("/upload").MultipartFormData("name", "Jack"),
+			expectedError: errors.New("received multipart form data values [John] of name did not match expected mock value Jack"),
+		},
+		"file not received": {
+			mock:          NewMock().Post("/upload").MultipartFile("avatar", "testdata/multipart/hello.txt"),
+			expectedError: errors.New("expected multipart file hello.txt of avatar not received"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(body.Bytes()))
+			req.Header.Set("Content-Type", w.FormDataContentType())
+			matchError := multipartMatcher(req, test.mock)
+			assert.Equal(t, test.expectedError, matchError)
+		})
+	}
+}
+
 func TestMocks_FormDataPresent(t *testing.T) {
 	tests := []struct {
 		name                       string
@@ -635,6 +672,92 @@ func TestMocks_BodyMatcher(t *testing.T) {
 	}
 }
 
+func TestMocks_JSONSchemaMatcher(t *testing.T) {
+	schema := `{"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}`
+	tests := map[string]struct {
+		requestBody   string
+		expectedError error
+	}{
+		"matches":      {`{"name": "jan", "age": 30}`, nil},
+		"invalid":      {`{"name": 1}`, errors.New("received body did not match mock JSON schema: /name: expected string, got integer")},
+		"missing":      {`{}`, errors.New("received body did not match mock JSON schema: /: missing required property 'name'")},
+		"invalid json": {`name=jan`, errors.New("received body is not valid JSON: invalid character 'a' in literal null (expecting 'u')")},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader(test.requestBody))
+			matchError := jsonSchemaMatcher(req, NewMock().Post("/path").JSONSchema(schema))
+			assert.Equal(t, test.expectedError, matchError)
+		})
+	}
+}
+
+func TestMocks_JSONPathMatcher(t *testing.T) {
+	tests := map[string]struct {
+		mock          *MockRequest
+		expectedError error
+	}{
+		"matches": {
+			mock: NewMock().Post("/path").JSONPath("$.name").Equal("jan").JSONPath("$.tags").Contains("a"),
+		},
+		"does not match": {
+			mock:          NewMock().Post("/path").JSONPath("$.name").Equal("jan").JSONPath("$.tags").Len(1),
+			expectedError: errors.New("received body did not match mock JSONPath $.tags: expected length 1, got 2"),
+		},
+		"not present": {
+			mock:          NewMock().Post("/path").JSONPath("$.email").Present(),
+			expectedError: errors.New("received body did not match mock JSONPath $.email: expected a value, got nothing"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader(`{"name": "jan", "tags": ["a", "b"]}`))
+			matchError := jsonPathMatcher(req, test.mock)
+			assert.Equal(t, test.expectedError, matchError)
+		})
+	}
+}
+
+func TestMocks_XMLMatcher(t *testing.T) {
+	tests := map[string]struct {
+		mock          *MockRequest
+		body          string
+		expectedError error
+	}{
+		"matches": {
+			mock: NewMock().Post("/path").XML(`<user role="admin" id="1"><name>jan</name></user>`),
+			body: "<user id=\"1\" role=\"admin\">\n  <name>jan</name>\n</user>",
+		},
+		"does not match": {
+			mock:          NewMock().Post("/path").XML(`<user><name>jan</name></user>`),
+			body:          `<user><name>kim</name></user>`,
+			expectedError: errors.New("received body did not match expected mock XML body\n\n\nDiff:\n--- Expected\n+++ Actual\n@@ -1,3 +1,3 @@\n <user>\n-    <name>jan</name>\n+    <name>kim</name>\n </user>\n"),
+		},
+		"not XML": {
+			mock:          NewMock().Post("/path").XML(`<user/>`),
+			body:          `{"name": "jan"}`,
+			expectedError: errors.New("received body is not valid XML: unexpected text outside of an element"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader(test.body))
+			matchError := xmlMatcher(req, test.mock)
+			assert.Equal(t, test.expectedError, matchError)
+		})
+	}
+}
+
+func TestMocks_Response_XML(t *testing.T) {
+	mock := NewMock().Get("/user").RespondWith().XML(`<user id="1"/>`).Status(http.StatusOK)
+
+	assert.Equal(t, `<user id="1"/>`, mock.body)
+	assert.Equal(t, []string{"application/xml"}, mock.headers["Content-Type"])
+}
+
 func TestMocks_RequestBody(t *testing.T) {
 	tests := map[string]struct {
 		requestBody interface{}
@@ -822,6 +945,154 @@ func TestMocks_Matches_NilIfNoMatch(t *testing.T) {
 	assert.Equal(t, newUnmatchedMockError(), matchErrors)
 }
 
+func TestMocks_Matches_RespondsTheGivenNumberOfTimes(t *testing.T) {
+	getUser := NewMock().
+		Get("/user/1234").
+		RespondWith().
+		Status(http.StatusOK).
+		Times(2).
+		End()
+
+	for i := 0; i < 2; i++ {
+		_, matchErrors := matches(httptest.NewRequest(http.MethodGet, "/user/1234", nil), []*Mock{getUser})
+		assert.Nil(t, matchErrors)
+	}
+	mockResponse, matchErrors := matches(httptest.NewRequest(http.MethodGet, "/user/1234", nil), []*Mock{getUser})
+
+	assert.Nil(t, mockResponse)
+	assert.Equal(t, "received request did not match any mocks\n\nMock 1 mismatches:\n• mock expected 2 calls, received 3\n\n",
+		matchErrors.Error())
+	assert.Equal(t, 3, getUser.calls)
+}
+
+func TestMocks_Matches_FallsThroughToNextMockWhenExhausted(t *testing.T) {
+	first := NewMock().Get("/user").RespondWith().Body("1").End()
+	second := NewMock().Get("/user").RespondWith().Body("2").AnyTimes().End()
+	mocks := []*Mock{first, second}
+
+	var bodies []string
+	for i := 0; i < 3; i++ {
+		mockResponse, matchErrors := matches(httptest.NewRequest(http.MethodGet, "/user", nil), mocks)
+		assert.Nil(t, matchErrors)
+		bodies = append(bodies, mockResponse.body)
+	}
+
+	assert.Equal(t, []string{"1", "2", "2"}, bodies)
+	assert.Equal(t, 1, first.calls)
+	assert.Equal(t, 2, second.calls)
+}
+
+func TestMocks_Matches_ScenarioState(t *testing.T) {
+	created := NewMock().InScenario("order").WillSetState("paid").Post("/order").RespondWith().Body("created").End()
+	paid := NewMock().InScenario("order").WhenState("paid").Get("/order").RespondWith().Body("paid").End()
+	mocks := []*Mock{created, paid}
+	newTransport(mocks, nil, false, false, nil, nil)
+
+	mockResponse, matchErrors := matches(httptest.NewRequest(http.MethodGet, "/order", nil), mocks)
+	assert.Nil(t, mockResponse)
+	assert.Equal(t, "received request did not match any mocks\n\n"+
+		"Mock 1 mismatches:\n• received method GET did not match mock method POST\n\n"+
+		"Mock 2 mismatches:\n• scenario 'order' is in state 'Started', expected state 'paid'\n\n",
+		matchErrors.Error())
+
+	_, matchErrors = matches(httptest.NewRequest(http.MethodPost, "/order", nil), mocks)
+	assert.Nil(t, matchErrors)
+
+	mockResponse, matchErrors = matches(httptest.NewRequest(http.MethodGet, "/order", nil), mocks)
+	assert.Nil(t, matchErrors)
+	assert.Equal(t, "paid", mockResponse.body)
+	assert.Equal(t, "paid", created.states.state("order"))
+}
+
+func TestMocks_Matches_ResponseSequence(t *testing.T) {
+	tests := map[string]struct {
+		mock           *Mock
+		expectedBodies []string
+	}{
+		"responds once to each response": {
+			mock:           NewMock().Get("/user").RespondWith().Body("1").ThenRespondWith().Body("2").End(),
+			expectedBodies: []string{"1", "2", ""},
+		},
+		"repeats the last response": {
+			mock:           NewMock().Get("/user").RespondWith().Body("1").ThenRespondWith().Body("2").RepeatLast().End(),
+			expectedBodies: []string{"1", "2", "2", "2"},
+		},
+		"repeats the last response up to times": {
+			mock:           NewMock().Get("/user").RespondWith().Body("1").ThenRespondWith().Body("2").Times(3).End(),
+			expectedBodies: []string{"1", "2", "2", ""},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var bodies []string
+			for range test.expectedBodies {
+				mockResponse, _ := matches(httptest.NewRequest(http.MethodGet, "/user", nil), []*Mock{test.mock})
+				if mockResponse == nil {
+					bodies = append(bodies, "")
+					continue
+				}
+				bodies = append(bodies, mockResponse.body)
+			}
+			assert.Equal(t, test.expectedBodies, bodies)
+		})
+	}
+}
+
+func TestMocks_Never_DoesNotMatch(t *testing.T) {
+	mock := NewMock().Get("/user").RespondWith().Never().End()
+
+	mockResponse, matchErrors := matches(httptest.NewRequest(http.MethodGet, "/user", nil), []*Mock{mock})
+
+	assert.Nil(t, mockResponse)
+	assert.Error(t, matchErrors)
+	assert.Equal(t, 1, mock.calls)
+	assert.False(t, mock.satisfied())
+}
+
+func TestMocks_ExpectedCalls(t *testing.T) {
+	tests := map[string]struct {
+		mock      *Mock
+		calls     int
+		expected  string
+		satisfied bool
+	}{
+		"times":              {NewMock().Get("/").RespondWith().Times(3).End(), 1, "3", false},
+		"times satisfied":    {NewMock().Get("/").RespondWith().Times(3).End(), 3, "3", true},
+		"any times":          {NewMock().Get("/").RespondWith().AnyTimes().End(), 0, "any number of", true},
+		"at least":           {NewMock().Get("/").RespondWith().AtLeast(2).End(), 1, "at least 2", false},
+		"at least satisfied": {NewMock().Get("/").RespondWith().AtLeast(2).End(), 5, "at least 2", true},
+		"at most":            {NewMock().Get("/").RespondWith().AtMost(2).End(), 3, "at most 2", false},
+		"at most satisfied":  {NewMock().Get("/").RespondWith().AtMost(2).End(), 0, "at most 2", true},
+		"between":            {NewMock().Get("/").RespondWith().AtLeast(2).AtMost(4).End(), 3, "between 2 and 4", true},
+		"never":              {NewMock().Get("/").RespondWith().Never().End(), 1, "0", false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.mock.calls = test.calls
+
+			assert.Equal(t, test.expected, test.mock.expectedCalls())
+			assert.Equal(t, test.satisfied, test.mock.satisfied())
+		})
+	}
+}
+
+func TestMocks_UnmatchedMock_String(t *testing.T) {
+	mock := NewMock().
+		Label("create user").
+		Post("http://localhost:8080/user").
+		Header("Content-Type", "application/json").
+		Query("notify", "true").
+		Body(`{"name": "jon"}`).
+		RespondWith().
+		End()
+
+	unmatchedMock := newUnmatchedMock(3, mock)
+
+	assert.Equal(t,
+		`mock 3 (create user): POST http://localhost:8080/user, headers map[Content-Type:[application/json]], query map[notify:[true]], body {"name": "jon"}`,
+		unmatchedMock.String())
+}
+
 func TestMocks_UnmatchedMockErrorOrderedMockKeys(t *testing.T) {
 	unmatchedMockError := newUnmatchedMockError().
 		addErrors(3, errors.New("oh no")).
@@ -949,7 +1220,7 @@ func TestMocks_Response_SetsTextPlainIfNoContentTypeSet(t *testing.T) {
 		RespondWith().
 		Body("abcdef")
 
-	response := buildResponseFromMock(mockResponse)
+	response, _ := buildResponseFromMock(mockResponse, nil)
 
 	bytes, _ := ioutil.ReadAll(response.Body)
 	assert.Equal(t, string(bytes), "abcdef")
@@ -962,7 +1233,7 @@ func TestMocks_Response_SetsTheBodyAsJSON(t *testing.T) {
 		RespondWith().
 		Body(`{"a": 123}`)
 
-	response := buildResponseFromMock(mockResponse)
+	response, _ := buildResponseFromMock(mockResponse, nil)
 
 	bytes, _ := ioutil.ReadAll(response.Body)
 	assert.Equal(t, string(bytes), `{"a": 123}`)
@@ -975,7 +1246,7 @@ func TestMocks_ResponseJSON(t *testing.T) {
 		RespondWith().
 		JSON(map[string]int{"a": 123})
 
-	response := buildResponseFromMock(mockResponse)
+	response, _ := buildResponseFromMock(mockResponse, nil)
 
 	bytes, _ := ioutil.ReadAll(response.Body)
 	assert.Equal(t, string(bytes), `{"a":123}`)
@@ -989,7 +1260,7 @@ func TestMocks_Response_SetsTheBodyAsOther(t *testing.T) {
 		Body(`<html>123</html>`).
 		Header("Content-Type", "text/html")
 
-	response := buildResponseFromMock(mockResponse)
+	response, _ := buildResponseFromMock(mockResponse, nil)
 
 	bytes, _ := ioutil.ReadAll(response.Body)
 	assert.Equal(t, string(bytes), `<html>123</html>`)
@@ -1004,7 +1275,7 @@ func TestMocks_Response_Headers_WithNormalizedKeys(t *testing.T) {
 		Headers(map[string]string{"B": "2"}).
 		Header("c", "3")
 
-	response := buildResponseFromMock(mockResponse)
+	response, _ := buildResponseFromMock(mockResponse, nil)
 
 	assert.Equal(t, http.Header(map[string][]string{"A": {"1"}, "B": {"2"}, "C": {"3"}}), response.Header)
 }
@@ -1017,7 +1288,7 @@ func TestMocks_Response_Cookies(t *testing.T) {
 		Cookies(NewCookie("B").Value("2")).
 		Cookie("C", "3")
 
-	response := buildResponseFromMock(mockResponse)
+	response, _ := buildResponseFromMock(mockResponse, nil)
 
 	assert.Equal(t, []*http.Cookie{
 		{Name: "A", Value: "1", Raw: "A=1"},
@@ -1026,6 +1297,105 @@ func TestMocks_Response_Cookies(t *testing.T) {
 	}, response.Cookies())
 }
 
+func TestMocks_Response_BodyFunc(t *testing.T) {
+	mockResponse := NewMock().
+		Get("/user/.+").
+		RespondWith().
+		BodyFunc(func(req *http.Request) string {
+			return fmt.Sprintf(`{"id": "%s"}`, strings.TrimPrefix(req.URL.Path, "/user/"))
+		}).
+		Status(http.StatusOK)
+
+	response, err := buildResponseFromMock(mockResponse, httptest.NewRequest(http.MethodGet, "/user/1234", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	assert.Equal(t, int64(14), response.ContentLength)
+	bytes, _ := ioutil.ReadAll(response.Body)
+	assert.JSONEq(t, `{"id": "1234"}`, string(bytes))
+}
+
+func TestMocks_RespondWithFunc(t *testing.T) {
+	mockResponse := NewMock().
+		Post("/user").
+		RespondWithFunc(func(req *http.Request) (*http.Response, error) {
+			var user User
+			if err := json.NewDecoder(req.Body).Decode(&user); err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Header:     http.Header{"Location": {"/user/" + user.ID}},
+				Body:       ioutil.NopCloser(strings.NewReader(fmt.Sprintf(`{"id": "%s"}`, user.ID))),
+			}, nil
+		})
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"id": "42"}`))
+
+	response, err := buildResponseFromMock(mockResponse, req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, "/user/42", response.Header.Get("Location"))
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	assert.Equal(t, 1, response.ProtoMajor)
+	bytes, _ := ioutil.ReadAll(response.Body)
+	assert.JSONEq(t, `{"id": "42"}`, string(bytes))
+	bytes, _ = ioutil.ReadAll(req.Body)
+	assert.JSONEq(t, `{"id": "42"}`, string(bytes), "expected the request body to remain readable")
+}
+
+func TestMocks_RespondWithFunc_ReturnsErrorToClient(t *testing.T) {
+	defer NewMock().
+		HttpClient(customCli).
+		Get("http://localhost:8080/path").
+		RespondWithFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection reset")
+		}).
+		EndStandalone()()
+
+	_, err := customCli.Get("http://localhost:8080/path")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "connection reset")
+}
+
+func TestMocks_ApiTest_ObservesDynamicResponses(t *testing.T) {
+	var observedBodies []string
+	getUser := NewMock().
+		Get("http://localhost:8080").
+		RespondWith().
+		BodyFunc(func(req *http.Request) string {
+			return req.URL.Query().Get("id")
+		}).
+		Status(http.StatusOK).
+		Times(2).
+		End()
+
+	New().
+		ObserveMocks(func(res *http.Response, req *http.Request, a *APITest) {
+			bytes, _ := ioutil.ReadAll(copyHttpResponse(res).Body)
+			observedBodies = append(observedBodies, string(bytes))
+		}).
+		Mocks(getUser).
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, id := range []string{"1", "2"} {
+				res, err := http.Get("http://localhost:8080?id=" + id)
+				if err != nil {
+					panic(err)
+				}
+				bytes, _ := ioutil.ReadAll(res.Body)
+				_, _ = w.Write(bytes)
+			}
+		})).
+		Get("/").
+		Expect(t).
+		Status(http.StatusOK).
+		Body("12").
+		End()
+
+	assert.Equal(t, []string{"1", "2"}, observedBodies)
+}
+
 func TestMocks_Standalone(t *testing.T) {
 	cli := http.Client{Timeout: 5}
 	defer NewMock().
@@ -0,0 +1,755 @@
+package apitest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type (
+	wireMockMappings struct {
+		Mappings []wireMockMapping `json:"mappings"`
+	}
+
+	wireMockMapping struct {
+		Name                  string           `json:"name,omitempty"`
+		Priority              int              `json:"priority,omitempty"`
+		ScenarioName          string           `json:"scenarioName,omitempty"`
+		RequiredScenarioState string           `json:"requiredScenarioState,omitempty"`
+		NewScenarioState      string           `json:"newScenarioState,omitempty"`
+		Request               wireMockRequest  `json:"request"`
+		Response              wireMockResponse `json:"response"`
+	}
+
+	wireMockRequest struct {
+		Method          string                          `json:"method,omitempty"`
+		URL             string                          `json:"url,omitempty"`
+		URLPath         string                          `json:"urlPath,omitempty"`
+		URLPattern      string                          `json:"urlPattern,omitempty"`
+		URLPathPattern  string                          `json:"urlPathPattern,omitempty"`
+		Headers         map[string]wireMockValuePattern `json:"headers,omitempty"`
+		QueryParameters map[string]wireMockValuePattern `json:"queryParameters,omitempty"`
+		Cookies         map[string]wireMockValuePattern `json:"cookies,omitempty"`
+		BasicAuth       *wireMockBasicAuth              `json:"basicAuthCredentials,omitempty"`
+		BodyPatterns    []wireMockBodyPattern           `json:"bodyPatterns,omitempty"`
+	}
+
+	wireMockValuePattern struct {
+		EqualTo         *string `json:"equalTo,omitempty"`
+		Contains        *string `json:"contains,omitempty"`
+		Matches         *string `json:"matches,omitempty"`
+		DoesNotMatch    *string `json:"doesNotMatch,omitempty"`
+		Absent          *bool   `json:"absent,omitempty"`
+		CaseInsensitive bool    `json:"caseInsensitive,omitempty"`
+		// Includes matches multi-valued headers and query parameters where each pattern matches one of the values
+		Includes []wireMockValuePattern `json:"includes,omitempty"`
+	}
+
+	wireMockBasicAuth struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	wireMockBodyPattern struct {
+		wireMockValuePattern
+		EqualToJSON         json.RawMessage `json:"equalToJson,omitempty"`
+		IgnoreArrayOrder    bool            `json:"ignoreArrayOrder,omitempty"`
+		IgnoreExtraElements bool            `json:"ignoreExtraElements,omitempty"`
+		MatchesJSONPath     json.RawMessage `json:"matchesJsonPath,omitempty"`
+	}
+
+	wireMockJSONPathPattern struct {
+		wireMockValuePattern
+		Expression string `json:"expression"`
+	}
+
+	wireMockResponse struct {
+		Status                 int                             `json:"status,omitempty"`
+		Headers                map[string]wireMockHeaderValues `json:"headers,omitempty"`
+		Body                   string                          `json:"body,omitempty"`
+		JSONBody               json.RawMessage                 `json:"jsonBody,omitempty"`
+		Base64Body             string                          `json:"base64Body,omitempty"`
+		BodyFileName           string                          `json:"bodyFileName,omitempty"`
+		FixedDelayMilliseconds int64                           `json:"fixedDelayMilliseconds,omitempty"`
+		Fault                  string                          `json:"fault,omitempty"`
+	}
+
+	// wireMockHeaderValues is a single header value or a list of values
+	wireMockHeaderValues []string
+)
+
+// wireMockDefaultPriority is the priority WireMock assigns to mappings without a priority
+const wireMockDefaultPriority = 5
+
+// wireMockFault is the fault used to export mocks that time out
+const wireMockFault = "CONNECTION_RESET_BY_PEER"
+
+func (v *wireMockHeaderValues) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*v = wireMockHeaderValues{value}
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*v = values
+	return nil
+}
+
+func (v wireMockHeaderValues) MarshalJSON() ([]byte, error) {
+	if len(v) == 1 {
+		return json.Marshal(v[0])
+	}
+	return json.Marshal([]string(v))
+}
+
+// MocksFromWireMock converts WireMock stub mappings to mocks. The path is a mapping file, which contains a single
+// mapping or a list of mappings, or a directory of mapping files, e.g. wiremock/mappings. As in WireMock, body files
+// are loaded from the __files directory next to the directory of the mapping file. Mappings are ordered by priority.
+// Faults are converted to mocks that time out. MocksFromWireMock panics if a mapping is invalid or unsupported
+func MocksFromWireMock(path string) []*Mock {
+	mocks, err := mocksFromWireMock(path)
+	if err != nil {
+		panic(err)
+	}
+	return mocks
+}
+
+type wireMockSource struct {
+	mapping wireMockMapping
+	file    string
+}
+
+func mocksFromWireMock(path string) ([]*Mock, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+
+	var sources []wireMockSource
+	for _, file := range files {
+		mappings, err := readWireMockMappings(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		for _, mapping := range mappings {
+			sources = append(sources, wireMockSource{mapping: mapping, file: file})
+		}
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].mapping.priority() < sources[j].mapping.priority()
+	})
+
+	var mocks []*Mock
+	for _, source := range sources {
+		mock, err := source.mapping.newMock(filepath.Join(filepath.Dir(filepath.Dir(source.file)), "__files"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", source.file, err)
+		}
+		mocks = append(mocks, mock)
+	}
+	return mocks, nil
+}
+
+func readWireMockMappings(file string) ([]wireMockMapping, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields["mappings"]; ok {
+		var mappings wireMockMappings
+		err := json.Unmarshal(data, &mappings)
+		return mappings.Mappings, err
+	}
+
+	var mapping wireMockMapping
+	err = json.Unmarshal(data, &mapping)
+	return []wireMockMapping{mapping}, err
+}
+
+func (m wireMockMapping) priority() int {
+	if m.Priority == 0 {
+		return wireMockDefaultPriority
+	}
+	return m.Priority
+}
+
+func (m wireMockMapping) newMock(filesDir string) (*Mock, error) {
+	mock := NewMock().Label(m.Name)
+	if m.ScenarioName != "" {
+		mock.InScenario(m.ScenarioName).WhenState(m.RequiredScenarioState).WillSetState(m.NewScenarioState)
+	}
+
+	req := m.Request
+	method := req.Method
+	if method == "ANY" {
+		method = ""
+	}
+	mockRequest := mock.Method(method)
+	mock.request.url = &url.URL{}
+
+	switch {
+	case req.URL != "":
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			return nil, err
+		}
+		mock.request.url.Path = exactly(u.Path)
+		mockRequest.AddMatcher(func(r *http.Request, _ *MockRequest) error {
+			return errorOrNil(r.URL.RequestURI() == req.URL, func() string {
+				return fmt.Sprintf("received url %s did not match mock url %s", r.URL.RequestURI(), req.URL)
+			})
+		})
+	case req.URLPath != "":
+		mock.request.url.Path = exactly(req.URLPath)
+	case req.URLPathPattern != "":
+		mock.request.url.Path = fullMatch(req.URLPathPattern)
+	case req.URLPattern != "":
+		pattern, err := regexp.Compile(fullMatch(req.URLPattern))
+		if err != nil {
+			return nil, err
+		}
+		mockRequest.AddMatcher(func(r *http.Request, _ *MockRequest) error {
+			return errorOrNil(pattern.MatchString(r.URL.RequestURI()), func() string {
+				return fmt.Sprintf("received url %s did not match mock url pattern %s", r.URL.RequestURI(), req.URLPattern)
+			})
+		})
+	}
+	if _, err := regexp.Compile(mock.request.url.Path); err != nil {
+		return nil, err
+	}
+
+	for key, pattern := range req.Headers {
+		if err := pattern.apply(key, mockRequest.Header, mockRequest.HeaderPresent, mockRequest.HeaderNotPresent,
+			func(r *http.Request) (string, bool) {
+				value, ok := r.Header[http.CanonicalHeaderKey(key)]
+				return strings.Join(value, ","), ok
+			}, mockRequest); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, pattern := range req.QueryParameters {
+		if err := pattern.apply(key, mockRequest.Query, mockRequest.QueryPresent, mockRequest.QueryNotPresent,
+			func(r *http.Request) (string, bool) {
+				value, ok := r.URL.Query()[key]
+				return strings.Join(value, ","), ok
+			}, mockRequest); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, pattern := range req.Cookies {
+		cookieValue := func(r *http.Request) (string, bool) {
+			cookie, err := r.Cookie(name)
+			if err != nil {
+				return "", false
+			}
+			return cookie.Value, true
+		}
+		if pattern.EqualTo != nil && !pattern.CaseInsensitive {
+			mockRequest.Cookie(name, *pattern.EqualTo)
+			continue
+		}
+		if err := pattern.apply(name, nil, mockRequest.CookiePresent, mockRequest.CookieNotPresent, cookieValue, mockRequest); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.BasicAuth != nil {
+		mockRequest.BasicAuth(req.BasicAuth.Username, req.BasicAuth.Password)
+	}
+
+	for _, bodyPattern := range req.BodyPatterns {
+		matcher, err := bodyPattern.matcher()
+		if err != nil {
+			return nil, err
+		}
+		mockRequest.AddMatcher(matcher)
+	}
+
+	res := m.Response
+	status := res.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	// as in WireMock, a mapping matches any number of requests, while in the required state of its scenario
+	mockResponse := mockRequest.RespondWith().Status(status).AnyTimes()
+	for key, values := range res.Headers {
+		for _, value := range values {
+			mockResponse.Header(key, value)
+		}
+	}
+
+	switch {
+	case res.Body != "":
+		mockResponse.Body(res.Body)
+	case len(res.JSONBody) > 0:
+		mockResponse.Body(string(res.JSONBody))
+	case res.Base64Body != "":
+		body, err := base64.StdEncoding.DecodeString(res.Base64Body)
+		if err != nil {
+			return nil, err
+		}
+		mockResponse.Body(string(body))
+	case res.BodyFileName != "":
+		body, err := ioutil.ReadFile(filepath.Join(filesDir, res.BodyFileName))
+		if err != nil {
+			return nil, err
+		}
+		mockResponse.Body(string(body))
+	}
+
+	if res.FixedDelayMilliseconds > 0 {
+		mockResponse.FixedDelay(res.FixedDelayMilliseconds)
+	}
+	if res.Fault != "" {
+		mockResponse.Timeout()
+	}
+	return mockResponse.End(), nil
+}
+
+// apply adds the pattern to the mock request using the built in matchers where possible
+func (p wireMockValuePattern) apply(
+	key string,
+	matches func(key, value string) *MockRequest,
+	present func(key string) *MockRequest,
+	notPresent func(key string) *MockRequest,
+	value func(*http.Request) (string, bool),
+	mockRequest *MockRequest) error {
+
+	if p.Absent != nil {
+		if *p.Absent {
+			notPresent(key)
+		} else {
+			present(key)
+		}
+		return nil
+	}
+
+	for _, include := range p.Includes {
+		if err := include.apply(key, matches, present, notPresent, value, mockRequest); err != nil {
+			return err
+		}
+	}
+	if len(p.Includes) > 0 {
+		return nil
+	}
+
+	if regex, ok := p.regexp(); ok && matches != nil {
+		if _, err := regexp.Compile(regex); err != nil {
+			return err
+		}
+		matches(key, regex)
+		return nil
+	}
+
+	match, err := p.compile()
+	if err != nil {
+		return err
+	}
+	mockRequest.AddMatcher(func(r *http.Request, _ *MockRequest) error {
+		v, ok := value(r)
+		return errorOrNil(ok && match(v), func() string {
+			return fmt.Sprintf("received value %q of %s did not match mock pattern %s", v, key, p)
+		})
+	})
+	return nil
+}
+
+// regexp returns a regular expression equivalent to the pattern if there is one
+func (p wireMockValuePattern) regexp() (string, bool) {
+	prefix := ""
+	if p.CaseInsensitive {
+		prefix = "(?i)"
+	}
+	switch {
+	case p.EqualTo != nil:
+		return prefix + exactly(*p.EqualTo), true
+	case p.Contains != nil:
+		return prefix + regexp.QuoteMeta(*p.Contains), true
+	case p.Matches != nil:
+		return fullMatch(*p.Matches), true
+	}
+	return "", false
+}
+
+// compile returns a function that reports whether a value matches the pattern
+func (p wireMockValuePattern) compile() (func(string) bool, error) {
+	if p.DoesNotMatch != nil {
+		re, err := regexp.Compile(fullMatch(*p.DoesNotMatch))
+		if err != nil {
+			return nil, err
+		}
+		return func(v string) bool { return !re.MatchString(v) }, nil
+	}
+	if p.Absent != nil {
+		return nil, errors.New("absent is only supported for headers, query parameters and cookies")
+	}
+	regex, ok := p.regexp()
+	if !ok {
+		return nil, fmt.Errorf("unsupported pattern %s", p)
+	}
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
+
+func (p wireMockValuePattern) String() string {
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+func (p wireMockBodyPattern) matcher() (Matcher, error) {
+	switch {
+	case len(p.EqualToJSON) > 0:
+		expected, err := decodeWireMockJSON(p.EqualToJSON)
+		if err != nil {
+			return nil, err
+		}
+		return func(r *http.Request, _ *MockRequest) error {
+			actual, err := requestJSON(r)
+			if err != nil {
+				return err
+			}
+			return errorOrNil(jsonMatches(expected, actual, p.IgnoreArrayOrder, p.IgnoreExtraElements), func() string {
+				return fmt.Sprintf("received body did not match mock json %s", p.EqualToJSON)
+			})
+		}, nil
+	case len(p.MatchesJSONPath) > 0:
+		var pattern wireMockJSONPathPattern
+		if err := json.Unmarshal(p.MatchesJSONPath, &pattern.Expression); err != nil {
+			if err := json.Unmarshal(p.MatchesJSONPath, &pattern); err != nil {
+				return nil, err
+			}
+		}
+		path, err := compileJSONPath(pattern.Expression)
+		if err != nil {
+			return nil, err
+		}
+		var match func(string) bool
+		if !reflect.DeepEqual(pattern.wireMockValuePattern, wireMockValuePattern{}) && pattern.Absent == nil {
+			if match, err = pattern.compile(); err != nil {
+				return nil, err
+			}
+		}
+		absent := pattern.Absent != nil && *pattern.Absent
+		return func(r *http.Request, _ *MockRequest) error {
+			actual, err := requestJSON(r)
+			if err != nil {
+				return err
+			}
+			values := path.evaluate(actual)
+			matched := len(values) > 0
+			if match != nil {
+				matched = false
+				for _, value := range values {
+					if match(jsonPathValueString(value)) {
+						matched = true
+					}
+				}
+			}
+			return errorOrNil(matched != absent, func() string {
+				return fmt.Sprintf("received body did not match mock json path %s", p.MatchesJSONPath)
+			})
+		}, nil
+	}
+
+	match, err := p.wireMockValuePattern.compile()
+	if err != nil {
+		return nil, err
+	}
+	return func(r *http.Request, _ *MockRequest) error {
+		body, err := requestBody(r)
+		if err != nil {
+			return err
+		}
+		return errorOrNil(match(string(body)), func() string {
+			return fmt.Sprintf("received body did not match mock body pattern %s", p.wireMockValuePattern)
+		})
+	}, nil
+}
+
+// decodeWireMockJSON decodes an equalToJson value, which is either JSON or a string containing JSON
+func decodeWireMockJSON(data json.RawMessage) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	if s, ok := value.(string); ok {
+		if err := json.Unmarshal([]byte(s), &value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// requestBody reads the body of the request and replaces it so that it can be read again
+func requestBody(r *http.Request) ([]byte, error) {
+	return readBody(r.Body, func(replacementBody io.ReadCloser) {
+		r.Body = replacementBody
+	})
+}
+
+func requestJSON(r *http.Request) (interface{}, error) {
+	body, err := requestBody(r)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, fmt.Errorf("received body is not valid JSON: %s", err)
+	}
+	return value, nil
+}
+
+// jsonMatches reports whether the actual JSON value equals the expected value. Arrays can be compared
+// ignoring the order of their elements and objects and arrays can contain elements that are not expected
+func jsonMatches(expected, actual interface{}, ignoreArrayOrder, ignoreExtraElements bool) bool {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok || (!ignoreExtraElements && len(a) != len(e)) {
+			return false
+		}
+		for key, value := range e {
+			actualValue, ok := a[key]
+			if !ok || !jsonMatches(value, actualValue, ignoreArrayOrder, ignoreExtraElements) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) < len(e) || (!ignoreExtraElements && len(a) != len(e)) {
+			return false
+		}
+		if !ignoreArrayOrder {
+			for i := range e {
+				if !jsonMatches(e[i], a[i], ignoreArrayOrder, ignoreExtraElements) {
+					return false
+				}
+			}
+			return true
+		}
+		used := make([]bool, len(a))
+		for _, value := range e {
+			found := false
+			for i := range a {
+				if !used[i] && jsonMatches(value, a[i], ignoreArrayOrder, ignoreExtraElements) {
+					used[i] = true
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return jsonValuesEqual(expected, actual)
+}
+
+// WriteWireMockMappings writes the mocks to a WireMock mappings file, e.g. for use by a stub server. The mappings are
+// prioritised in the order of the mocks, so the stub server matches requests in the same way as the mocks.
+// Custom matchers, form data, invocation counts and dynamic responses cannot be represented and are not written
+func WriteWireMockMappings(path string, mocks ...*Mock) error {
+	mappings := wireMockMappings{Mappings: []wireMockMapping{}}
+	for i, mock := range mocks {
+		mappings.Mappings = append(mappings.Mappings, newWireMockMapping(mock, i+1))
+	}
+
+	data, err := json.MarshalIndent(mappings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func newWireMockMapping(mock *Mock, priority int) wireMockMapping {
+	req := mock.request
+	mapping := wireMockMapping{
+		Name:     mock.label,
+		Priority: priority,
+		Request: wireMockRequest{
+			Method:          req.method,
+			Headers:         map[string]wireMockValuePattern{},
+			QueryParameters: map[string]wireMockValuePattern{},
+			Cookies:         map[string]wireMockValuePattern{},
+		},
+	}
+	if mapping.Request.Method == "" {
+		mapping.Request.Method = "ANY"
+	}
+	if mock.scenario != "" {
+		mapping.ScenarioName = mock.scenario
+		mapping.RequiredScenarioState = mock.requiredState
+		mapping.NewScenarioState = mock.newState
+	}
+
+	if req.url != nil && req.url.Path != "" {
+		path := req.url.Path
+		inner, anchored := unanchor(path)
+		literal, isLiteral := literalOf(inner)
+		switch {
+		case anchored && isLiteral:
+			mapping.Request.URLPath = literal
+		case anchored:
+			mapping.Request.URLPathPattern = inner
+		default:
+			// the path of a mock is searched for the regular expression, while WireMock matches the whole path
+			mapping.Request.URLPathPattern = ".*(?:" + path + ").*"
+		}
+	}
+
+	for key, values := range req.headers {
+		mapping.Request.Headers[key] = wireMockPatternFromRegexps(values)
+	}
+	for key, values := range req.query {
+		mapping.Request.QueryParameters[key] = wireMockPatternFromRegexps(values)
+	}
+	for _, cookie := range req.cookie {
+		if cookie.name != nil && cookie.value != nil {
+			mapping.Request.Cookies[*cookie.name] = wireMockValuePattern{EqualTo: cookie.value}
+		}
+	}
+	addPresence(mapping.Request.Headers, req.headerPresent, req.headerNotPresent)
+	addPresence(mapping.Request.QueryParameters, req.queryPresent, req.queryNotPresent)
+	addPresence(mapping.Request.Cookies, req.cookiePresent, req.cookieNotPresent)
+
+	if req.basicAuthUsername != "" {
+		mapping.Request.BasicAuth = &wireMockBasicAuth{Username: req.basicAuthUsername, Password: req.basicAuthPassword}
+	}
+
+	if req.body != "" {
+		body := req.body
+		if json.Valid([]byte(body)) {
+			mapping.Request.BodyPatterns = append(mapping.Request.BodyPatterns, wireMockBodyPattern{EqualToJSON: json.RawMessage(body)})
+		} else {
+			mapping.Request.BodyPatterns = append(mapping.Request.BodyPatterns, wireMockBodyPattern{wireMockValuePattern: wireMockValuePattern{EqualTo: &body}})
+		}
+	}
+
+	res := mock.response
+	mapping.Response = wireMockResponse{
+		Status:                 res.statusCode,
+		Headers:                map[string]wireMockHeaderValues{},
+		Body:                   res.body,
+		FixedDelayMilliseconds: res.fixedDelayMillis,
+	}
+	for key, values := range res.headers {
+		mapping.Response.Headers[key] = append(wireMockHeaderValues(nil), values...)
+	}
+	for _, cookie := range res.cookies {
+		if v := cookie.ToHttpCookie().String(); v != "" {
+			mapping.Response.Headers["Set-Cookie"] = append(mapping.Response.Headers["Set-Cookie"], v)
+		}
+	}
+	if res.timeout {
+		mapping.Response.Fault = wireMockFault
+	}
+	return mapping
+}
+
+func addPresence(patterns map[string]wireMockValuePattern, present, notPresent []string) {
+	absent, notAbsent := true, false
+	for _, key := range present {
+		patterns[key] = wireMockValuePattern{Absent: &notAbsent}
+	}
+	for _, key := range notPresent {
+		patterns[key] = wireMockValuePattern{Absent: &absent}
+	}
+}
+
+// wireMockPatternFromRegexps converts the regular expressions of a header or query parameter. Multiple expected values
+// are converted to an includes pattern, which requires each of the values
+func wireMockPatternFromRegexps(regexps []string) wireMockValuePattern {
+	if len(regexps) == 1 {
+		return wireMockPatternFromRegexp(regexps[0])
+	}
+	var pattern wireMockValuePattern
+	for _, regex := range regexps {
+		pattern.Includes = append(pattern.Includes, wireMockPatternFromRegexp(regex))
+	}
+	return pattern
+}
+
+// wireMockPatternFromRegexp converts the regular expression of a mock matcher to a WireMock pattern. The matchers
+// of mocks search for the regular expression, so unanchored expressions are converted to contains or a partial match
+func wireMockPatternFromRegexp(regex string) wireMockValuePattern {
+	if inner, ok := unanchor(regex); ok {
+		if literal, ok := literalOf(inner); ok {
+			return wireMockValuePattern{EqualTo: &literal}
+		}
+		return wireMockValuePattern{Matches: &inner}
+	}
+	if literal, ok := literalOf(regex); ok {
+		return wireMockValuePattern{Contains: &literal}
+	}
+	partial := ".*(?:" + regex + ").*"
+	return wireMockValuePattern{Matches: &partial}
+}
+
+// fullMatch returns a regular expression that only matches if the whole value matches the given expression
+func fullMatch(regex string) string {
+	return "^(?:" + regex + ")$"
+}
+
+// unanchor removes the anchors added by exactly and fullMatch
+func unanchor(regex string) (string, bool) {
+	if strings.HasPrefix(regex, "^(?:") && strings.HasSuffix(regex, ")$") {
+		return regex[4 : len(regex)-2], true
+	}
+	if strings.HasPrefix(regex, "^") && strings.HasSuffix(regex, "$") && !strings.HasSuffix(regex, `\$`) {
+		return regex[1 : len(regex)-1], true
+	}
+	return "", false
+}
+
+// literalOf returns the value matched by a regular expression that does not contain any meta characters
+func literalOf(regex string) (string, bool) {
+	var literal strings.Builder
+	for i := 0; i < len(regex); i++ {
+		if regex[i] == '\\' && i+1 < len(regex) {
+			i++
+		}
+		literal.WriteByte(regex[i])
+	}
+	if regexp.QuoteMeta(literal.String()) != regex {
+		return "", false
+	}
+	return literal.String(), true
+}
@@ -0,0 +1,264 @@
+package apitest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMocksFromWireMock_Directory(t *testing.T) {
+	mocks := MocksFromWireMock("testdata/wiremock/mappings")
+
+	assert.Len(t, mocks, 4)
+
+	getOrder := mocks[0]
+	assert.Equal(t, "get order", getOrder.label)
+	assert.Equal(t, "", getOrder.request.method)
+	assert.Equal(t, "^(?:/orders/[0-9]+)$", getOrder.request.url.Path)
+	assert.Equal(t, "order", getOrder.scenario)
+	assert.Equal(t, MockStateStarted, getOrder.requiredState)
+	assert.Equal(t, "Shipped", getOrder.newState)
+	assert.Equal(t, map[string][]string{"X-Status": {"pending", "processing"}}, getOrder.response.headers)
+	assert.Equal(t, `{"status": "pending"}`, getOrder.response.body)
+	assert.Equal(t, int64(10), getOrder.response.fixedDelayMillis)
+
+	createOrder := mocks[1]
+	assert.Equal(t, "create order", createOrder.label)
+	assert.Equal(t, http.MethodPost, createOrder.request.method)
+	assert.Equal(t, http.StatusCreated, createOrder.response.statusCode)
+	assert.JSONEq(t, `{"id": "5678"}`, createOrder.response.body)
+
+	deleteOrder := mocks[2]
+	assert.Equal(t, "^/orders$", deleteOrder.request.url.Path)
+	assert.Equal(t, http.StatusOK, deleteOrder.response.statusCode)
+	assert.True(t, deleteOrder.response.timeout)
+
+	getUser := mocks[3]
+	assert.Equal(t, "get user", getUser.label)
+	assert.Equal(t, "^/users/1234$", getUser.request.url.Path)
+	assert.Equal(t, map[string][]string{"Accept": {"^application/json$"}, "Authorization": {"^(?:Bearer .+)$"}}, getUser.request.headers)
+	assert.Equal(t, []string{"X-Debug"}, getUser.request.headerNotPresent)
+	assert.Equal(t, map[string][]string{"expand": {"address"}}, getUser.request.query)
+	assert.JSONEq(t, `{"id": "1234", "name": "Jan"}`, getUser.response.body)
+}
+
+func TestMocksFromWireMock_MatchesRequests(t *testing.T) {
+	tests := map[string]struct {
+		method         string
+		url            string
+		body           string
+		headers        map[string]string
+		expectedStatus int
+	}{
+		"url path and headers": {
+			method:         http.MethodGet,
+			url:            "http://users/users/1234?expand=address,orders",
+			headers:        map[string]string{"Accept": "application/json", "Authorization": "Bearer abc"},
+			expectedStatus: http.StatusOK,
+		},
+		"absent header is present": {
+			method:  http.MethodGet,
+			url:     "http://users/users/1234?expand=address",
+			headers: map[string]string{"Accept": "application/json", "Authorization": "Bearer abc", "X-Debug": "true"},
+		},
+		"url path pattern with any method": {
+			method:         http.MethodPut,
+			url:            "http://orders/orders/1",
+			expectedStatus: http.StatusOK,
+		},
+		"json body patterns": {
+			method:         http.MethodPost,
+			url:            "http://orders/orders",
+			body:           `{"customer": {"id": 1}, "items": [{"sku": "b", "quantity": 2}, {"sku": "a", "quantity": 1}]}`,
+			expectedStatus: http.StatusCreated,
+		},
+		"json path does not match": {
+			method: http.MethodPost,
+			url:    "http://orders/orders",
+			body:   `{"customer": {"id": 1}, "items": [{"sku": "b", "quantity": 1}, {"sku": "a", "quantity": 2}]}`,
+		},
+		"json body missing element": {
+			method: http.MethodPost,
+			url:    "http://orders/orders",
+			body:   `{"customer": {"id": 1}, "items": [{"sku": "b", "quantity": 2}]}`,
+		},
+		"url with query": {
+			method:         http.MethodDelete,
+			url:            "http://orders/orders?force=true",
+			expectedStatus: http.StatusOK,
+		},
+		"url with query does not match": {
+			method: http.MethodDelete,
+			url:    "http://orders/orders?force=false",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mocks := MocksFromWireMock("testdata/wiremock/mappings")
+			req, _ := http.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			for key, value := range test.headers {
+				req.Header.Set(key, value)
+			}
+
+			mockResponse, err := matches(req, mocks)
+
+			if test.expectedStatus == 0 {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedStatus, mockResponse.statusCode)
+		})
+	}
+}
+
+func TestMocksFromWireMock_MatchesRepeatedRequests(t *testing.T) {
+	mocks := MocksFromWireMock("testdata/wiremock/mappings")
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodDelete, "http://orders/orders?force=true", nil)
+
+		mockResponse, err := matches(req, mocks)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, mockResponse.statusCode)
+	}
+}
+
+func TestMocksFromWireMock_MatchesRepeatedRequestsInScenario(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wiremock")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mapping.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{
+	  "scenarioName": "order",
+	  "requiredScenarioState": "Started",
+	  "request": {"method": "GET", "urlPath": "/orders/1"},
+	  "response": {"status": 200}
+	}`), 0644))
+	mocks := MocksFromWireMock(path)
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://orders/orders/1", nil)
+
+		mockResponse, err := matches(req, mocks)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, mockResponse.statusCode)
+	}
+}
+
+func TestMocksFromWireMock_PanicsIfInvalid(t *testing.T) {
+	assert.Panics(t, func() {
+		MocksFromWireMock("testdata/wiremock/mappings/missing.json")
+	})
+}
+
+func TestWriteWireMockMappings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wiremock")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mappings", "mocks.json")
+
+	err = WriteWireMockMappings(path,
+		NewMock().
+			Label("get user").
+			Get("http://users/user/[0-9]+").
+			Header("Accept", "^application/json$").
+			Header("Authorization", "Bearer").
+			QueryNotPresent("debug").
+			Query("tag", "^a$").
+			Query("tag", "^b$").
+			RespondWith().
+			Header("Content-Type", "application/json").
+			Body(`{"id": "1234"}`).
+			Status(http.StatusOK).
+			End(),
+		NewMock().
+			Post("^/user$").
+			Body(`{"name": "jan"}`).
+			RespondWith().
+			Status(http.StatusCreated).
+			Timeout().
+			End(),
+	)
+
+	assert.NoError(t, err)
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+	  "mappings": [
+	    {
+	      "name": "get user",
+	      "priority": 1,
+	      "request": {
+	        "method": "GET",
+	        "urlPathPattern": ".*(?:/user/[0-9]+).*",
+	        "headers": {
+	          "Accept": {"equalTo": "application/json"},
+	          "Authorization": {"contains": "Bearer"}
+	        },
+	        "queryParameters": {
+	          "debug": {"absent": true},
+	          "tag": {"includes": [{"equalTo": "a"}, {"equalTo": "b"}]}
+	        }
+	      },
+	      "response": {
+	        "status": 200,
+	        "headers": {"Content-Type": "application/json"},
+	        "body": "{\"id\": \"1234\"}"
+	      }
+	    },
+	    {
+	      "priority": 2,
+	      "request": {
+	        "method": "POST",
+	        "urlPath": "/user",
+	        "bodyPatterns": [{"equalToJson": {"name": "jan"}}]
+	      },
+	      "response": {
+	        "status": 201,
+	        "fault": "CONNECTION_RESET_BY_PEER"
+	      }
+	    }
+	  ]
+	}`, string(data))
+
+	mocks := MocksFromWireMock(path)
+	req, _ := http.NewRequest(http.MethodGet, "http://users/v1/user/12?tag=a&tag=b", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer abc")
+	mockResponse, err := matches(req, mocks)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id": "1234"}`, mockResponse.body)
+
+	req, _ = http.NewRequest(http.MethodGet, "http://users/user/12?tag=a", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer abc")
+	_, err = matches(req, mocks)
+	assert.Error(t, err)
+}
+
+func TestWireMockPatternFromRegexp(t *testing.T) {
+	tests := map[string]wireMockValuePattern{
+		`^application/json$`: {EqualTo: stringPtr("application/json")},
+		`^(?:v[0-9]+)$`:      {Matches: stringPtr("v[0-9]+")},
+		`^a\.b$`:             {EqualTo: stringPtr("a.b")},
+		`json`:               {Contains: stringPtr("json")},
+		`a|b`:                {Matches: stringPtr(".*(?:a|b).*")},
+	}
+	for regex, expected := range tests {
+		t.Run(regex, func(t *testing.T) {
+			assert.Equal(t, expected, wireMockPatternFromRegexp(regex))
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
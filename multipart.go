@@ -0,0 +1,191 @@
+package apitest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxMultipartMemory is the number of bytes of a multipart body that are held in memory while matching mocks
+const maxMultipartMemory = 32 << 20
+
+// multipartPart is a part of a multipart/form-data body. A part is either a form value or the content of a file
+type multipartPart struct {
+	field       string
+	value       string
+	file        string
+	content     []byte
+	contentType string
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// multipartBody streams the parts as a multipart/form-data body and returns the body and its content type. The files
+// are opened before the body is streamed, so that a file that cannot be read fails the test instead of truncating
+// the body
+func multipartBody(parts []multipartPart) (io.Reader, string, error) {
+	files, err := openMultipartFiles(parts)
+	if err != nil {
+		return nil, "", err
+	}
+	pr, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+	go func() {
+		defer closeFiles(files)
+		pw.CloseWithError(writeMultipart(w, parts, files))
+	}()
+	return pr, w.FormDataContentType(), nil
+}
+
+// openMultipartFiles opens the files of the parts, which are indexed by the index of the part
+func openMultipartFiles(parts []multipartPart) (map[int]*os.File, error) {
+	files := map[int]*os.File{}
+	for i, part := range parts {
+		if part.file == "" {
+			continue
+		}
+		f, err := os.Open(part.file)
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files[i] = f
+		info, err := f.Stat()
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		if info.IsDir() {
+			closeFiles(files)
+			return nil, fmt.Errorf("multipart file %s is a directory", part.file)
+		}
+	}
+	return files, nil
+}
+
+func closeFiles(files map[int]*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+func writeMultipart(w *multipart.Writer, parts []multipartPart, files map[int]*os.File) error {
+	for i, part := range parts {
+		header := textproto.MIMEHeader{}
+		if part.file == "" {
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(part.field)))
+			if part.contentType != "" {
+				header.Set("Content-Type", part.contentType)
+			}
+			pw, err := w.CreatePart(header)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(pw, part.value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(part.field), quoteEscaper.Replace(filepath.Base(part.file))))
+		header.Set("Content-Type", part.fileContentType())
+		pw, err := w.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(pw, files[i]); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// fileContentType returns the content type of the part or infers it from the extension of the file
+func (p multipartPart) fileContentType() string {
+	if p.contentType != "" {
+		return p.contentType
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(p.file)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+var multipartMatcher = func(req *http.Request, spec *MockRequest) error {
+	if len(spec.multipart) == 0 {
+		return nil
+	}
+
+	r := copyHttpRequest(req)
+	if err := r.ParseMultipartForm(maxMultipartMemory); err != nil {
+		return fmt.Errorf("unable to parse multipart form data: %s", err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	for _, part := range spec.multipart {
+		if part.file == "" {
+			if err := matchMultipartValue(r.MultipartForm.Value[part.field], part); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := matchMultipartFile(r.MultipartForm.File[part.field], part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchMultipartValue(received []string, part multipartPart) error {
+	for _, value := range received {
+		match, err := regexp.MatchString(part.value, value)
+		if err != nil {
+			return fmt.Errorf("failed to parse regexp for multipart form data %s with value %s", part.field, part.value)
+		}
+		if match {
+			return nil
+		}
+	}
+	return fmt.Errorf("received multipart form data values %s of %s did not match expected mock value %s", received, part.field, part.value)
+}
+
+func matchMultipartFile(received []*multipart.FileHeader, part multipartPart) error {
+	name := filepath.Base(part.file)
+	for _, header := range received {
+		if header.Filename != name {
+			continue
+		}
+		content, err := readMultipartFile(header)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(content, part.content) {
+			return nil
+		}
+		return fmt.Errorf("received multipart file %s of %s did not match the content of expected mock file %s", name, part.field, part.file)
+	}
+	return fmt.Errorf("expected multipart file %s of %s not received", name, part.field)
+}
+
+func readMultipartFile(header *multipart.FileHeader) ([]byte, error) {
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// errNoMultipartParts is returned when a content type is set before any part is added
+var errNoMultipartParts = errors.New("MultipartContentType must follow MultipartFormData or MultipartFile")
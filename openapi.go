@@ -0,0 +1,705 @@
+package apitest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type (
+	// openAPISpec is an OpenAPI 3 document. Values are decoded as JSON values so that schemas and references
+	// can be resolved against the document
+	openAPISpec struct {
+		document   map[string]interface{}
+		server     *url.URL
+		operations []*openAPIOperation
+	}
+
+	// openAPIOperation is an operation of an OpenAPI document with its path and operation level parameters
+	openAPIOperation struct {
+		spec       *openAPISpec
+		id         string
+		method     string
+		path       string
+		pathParams []string
+		pathRegexp *regexp.Regexp
+		// templateRegexp matches the path template without the path of the server, e.g. /users/{id} instead of /v1/users/{id}
+		templateRegexp *regexp.Regexp
+		parameters     []map[string]interface{}
+		requestBody    map[string]interface{}
+		responses      map[string]interface{}
+	}
+
+	// OpenAPIMockOption configures the mocks created by MocksFromOpenAPI
+	OpenAPIMockOption func(*openAPIMockOptions)
+
+	openAPIMockOptions struct {
+		server   string
+		statuses map[string]int
+		examples map[string]string
+	}
+)
+
+var openAPIMethods = []string{
+	http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+	http.MethodOptions, http.MethodHead, http.MethodPatch, http.MethodTrace,
+}
+
+var pathTemplateParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// openAPISpecs caches the documents used to validate tests, as the same document is usually used by many tests
+var openAPISpecs sync.Map
+
+func loadOpenAPISpec(path string) (*openAPISpec, error) {
+	if spec, ok := openAPISpecs.Load(path); ok {
+		return spec.(*openAPISpec), nil
+	}
+	document, err := readJSONDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := newOpenAPISpec(document)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	openAPISpecs.Store(path, spec)
+	return spec, nil
+}
+
+func newOpenAPISpec(document interface{}) (*openAPISpec, error) {
+	doc, ok := document.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid OpenAPI document")
+	}
+	if version, _ := doc["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, errors.New("only OpenAPI 3 documents are supported")
+	}
+
+	spec := &openAPISpec{document: doc, server: &url.URL{}}
+	if servers, ok := doc["servers"].([]interface{}); ok && len(servers) > 0 {
+		server, err := openAPIServerURL(servers[0])
+		if err != nil {
+			return nil, err
+		}
+		spec.server = server
+	}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	for _, path := range sortedKeys(paths) {
+		pathItem, ok := spec.resolve(paths[path])
+		if !ok {
+			return nil, fmt.Errorf("invalid path item %s", path)
+		}
+		for _, method := range openAPIMethods {
+			operation, ok := pathItem[strings.ToLower(method)].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			op, err := spec.newOperation(method, path, pathItem, operation)
+			if err != nil {
+				return nil, err
+			}
+			spec.operations = append(spec.operations, op)
+		}
+	}
+
+	// paths without templates take precedence over templated paths that match the same request, e.g. /users/me and /users/{id}
+	sort.SliceStable(spec.operations, func(i, j int) bool {
+		return len(spec.operations[i].pathParams) < len(spec.operations[j].pathParams)
+	})
+	return spec, nil
+}
+
+// openAPIServerURL returns the url of a server object, replacing the server variables by their default value
+func openAPIServerURL(server interface{}) (*url.URL, error) {
+	s, _ := server.(map[string]interface{})
+	rawURL, _ := s["url"].(string)
+	variables, _ := s["variables"].(map[string]interface{})
+	for name, variable := range variables {
+		v, _ := variable.(map[string]interface{})
+		rawURL = strings.Replace(rawURL, "{"+name+"}", fmt.Sprint(v["default"]), -1)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server url %s: %s", rawURL, err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u, nil
+}
+
+func (s *openAPISpec) newOperation(method, path string, pathItem, operation map[string]interface{}) (*openAPIOperation, error) {
+	op := &openAPIOperation{
+		spec:   s,
+		id:     method + " " + path,
+		method: method,
+		path:   path,
+	}
+	if id, ok := operation["operationId"].(string); ok && id != "" {
+		op.id = id
+	}
+
+	// operation parameters override path item parameters with the same name and location
+	byKey := map[string]int{}
+	for _, parameters := range []interface{}{pathItem["parameters"], operation["parameters"]} {
+		list, _ := parameters.([]interface{})
+		for _, item := range list {
+			parameter, ok := s.resolve(item)
+			if !ok {
+				return nil, fmt.Errorf("operation %s has an invalid parameter", op.id)
+			}
+			key := fmt.Sprintf("%s:%s", parameter["in"], parameter["name"])
+			if i, ok := byKey[key]; ok {
+				op.parameters[i] = parameter
+				continue
+			}
+			byKey[key] = len(op.parameters)
+			op.parameters = append(op.parameters, parameter)
+		}
+	}
+
+	if body, ok := operation["requestBody"]; ok {
+		op.requestBody, _ = s.resolve(body)
+	}
+	op.responses, _ = operation["responses"].(map[string]interface{})
+
+	pattern := ""
+	last := 0
+	for _, match := range pathTemplateParam.FindAllStringSubmatchIndex(path, -1) {
+		pattern += regexp.QuoteMeta(path[last:match[0]]) + "([^/]+)"
+		op.pathParams = append(op.pathParams, path[match[2]:match[3]])
+		last = match[1]
+	}
+	pattern += regexp.QuoteMeta(path[last:]) + "$"
+
+	var err error
+	if op.pathRegexp, err = regexp.Compile("^" + regexp.QuoteMeta(s.server.Path) + pattern); err != nil {
+		return nil, err
+	}
+	if op.templateRegexp, err = regexp.Compile("^" + pattern); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// resolve follows the reference of an object, e.g. #/components/parameters/limit, and returns the object
+func (s *openAPISpec) resolve(node interface{}) (map[string]interface{}, bool) {
+	for depth := 0; depth < maxSchemaDepth; depth++ {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return object, true
+		}
+		target, err := resolveJSONPointer(s.document, strings.TrimPrefix(ref, "#"))
+		if err != nil || !strings.HasPrefix(ref, "#") {
+			return nil, false
+		}
+		node = target
+	}
+	return nil, false
+}
+
+func (s *openAPISpec) schema(schema interface{}) *jsonSchema {
+	return newJSONSchema(s.document, schema)
+}
+
+// operation returns the operation identified by its operationId or by its method and path, e.g. GET /users/{id}
+func (s *openAPISpec) operation(name string) *openAPIOperation {
+	for _, op := range s.operations {
+		if op.id == name || op.method+" "+op.path == name {
+			return op
+		}
+	}
+	return nil
+}
+
+// findOperation returns the operation that matches the method and path of the request. The path of the request
+// may omit the path of the server, as handlers under test are often not mounted at the path of the server
+func (s *openAPISpec) findOperation(req *http.Request) *openAPIOperation {
+	for _, op := range s.operations {
+		if op.method == req.Method && op.pathRegexp.MatchString(req.URL.Path) {
+			return op
+		}
+	}
+	for _, op := range s.operations {
+		if op.method == req.Method && op.templateRegexp.MatchString(req.URL.Path) {
+			return op
+		}
+	}
+	return nil
+}
+
+// pathValues returns the values of the path parameters of the operation in the given path
+func (o *openAPIOperation) pathValues(path string) []string {
+	if values := o.pathRegexp.FindStringSubmatch(path); values != nil {
+		return values[1:]
+	}
+	if values := o.templateRegexp.FindStringSubmatch(path); values != nil {
+		return values[1:]
+	}
+	return nil
+}
+
+// validateRequest checks the parameters and body of the request against the operation
+func (o *openAPIOperation) validateRequest(req *http.Request) []string {
+	var errs []string
+	pathValues := o.pathValues(req.URL.Path)
+	query := req.URL.Query()
+
+	for _, parameter := range o.parameters {
+		name, _ := parameter["name"].(string)
+		in, _ := parameter["in"].(string)
+
+		var values []string
+		switch in {
+		case "path":
+			for i, param := range o.pathParams {
+				if param == name && i < len(pathValues) {
+					value, err := url.PathUnescape(pathValues[i])
+					if err != nil {
+						value = pathValues[i]
+					}
+					values = []string{value}
+				}
+			}
+		case "query":
+			values = query[name]
+		case "header":
+			values = req.Header[http.CanonicalHeaderKey(name)]
+		case "cookie":
+			if cookie, err := req.Cookie(name); err == nil {
+				values = []string{cookie.Value}
+			}
+		}
+
+		if len(values) == 0 {
+			if parameter["required"] == true || in == "path" {
+				errs = append(errs, fmt.Sprintf("missing required %s parameter '%s'", in, name))
+			}
+			continue
+		}
+
+		schema, ok := parameter["schema"]
+		if !ok {
+			continue
+		}
+		value := parameterValue(o.spec.schema(schema), values)
+		for _, err := range o.spec.schema(schema).validate(value) {
+			errs = append(errs, fmt.Sprintf("%s parameter '%s' %s", in, name, err))
+		}
+	}
+
+	return append(errs, o.validateRequestBody(req)...)
+}
+
+func (o *openAPIOperation) validateRequestBody(req *http.Request) []string {
+	if o.requestBody == nil {
+		return nil
+	}
+	body, err := requestBody(req)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if len(body) == 0 {
+		if o.requestBody["required"] == true {
+			return []string{"request body is required"}
+		}
+		return nil
+	}
+	content, _ := o.requestBody["content"].(map[string]interface{})
+	return o.spec.validateContent("request", content, req.Header.Get("Content-Type"), body)
+}
+
+// validateResponse checks the status, headers and body of the response against the operation
+func (o *openAPIOperation) validateResponse(res *http.Response) []string {
+	key := o.responseKey(res.StatusCode)
+	if key == "" {
+		return []string{fmt.Sprintf("response status %d is not declared", res.StatusCode)}
+	}
+	response, ok := o.spec.resolve(o.responses[key])
+	if !ok {
+		return []string{fmt.Sprintf("response %s is invalid", key)}
+	}
+
+	var errs []string
+	headers, _ := response["headers"].(map[string]interface{})
+	for _, name := range sortedKeys(headers) {
+		header, ok := o.spec.resolve(headers[name])
+		if !ok || strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+		values := res.Header[http.CanonicalHeaderKey(name)]
+		if len(values) == 0 {
+			if header["required"] == true {
+				errs = append(errs, fmt.Sprintf("missing required response header '%s'", name))
+			}
+			continue
+		}
+		if schema, ok := header["schema"]; ok {
+			for _, err := range o.spec.schema(schema).validate(parameterValue(o.spec.schema(schema), values)) {
+				errs = append(errs, fmt.Sprintf("response header '%s' %s", name, err))
+			}
+		}
+	}
+
+	body, err := readBody(res.Body, func(replacementBody io.ReadCloser) {
+		res.Body = replacementBody
+	})
+	if err != nil {
+		return append(errs, err.Error())
+	}
+	if len(body) == 0 {
+		return errs
+	}
+	content, _ := response["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return append(errs, fmt.Sprintf("response body is not declared for status %s", key))
+	}
+	return append(errs, o.spec.validateContent("response", content, res.Header.Get("Content-Type"), body)...)
+}
+
+// validateContent checks that the content type is declared by the content of a request or response and that JSON bodies
+// match the schema of the content type
+func (s *openAPISpec) validateContent(kind string, content map[string]interface{}, contentType string, body []byte) []string {
+	mediaType, media := openAPIMediaType(content, contentType)
+	if media == nil {
+		return []string{fmt.Sprintf("%s content type '%s' is not declared", kind, contentType)}
+	}
+	schema, ok := media["schema"]
+	if !ok || !isJSONMediaType(mediaType) {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []string{fmt.Sprintf("%s body is not valid JSON: %s", kind, err)}
+	}
+	var errs []string
+	for _, err := range s.schema(schema).validate(value) {
+		errs = append(errs, kind+" body "+err.Error())
+	}
+	return errs
+}
+
+// parameterValue converts the values of a parameter to the type defined by its schema, so that it can be validated
+func parameterValue(schema *jsonSchema, values []string) interface{} {
+	types := schemaTypes(resolvedSchema(schema)["type"])
+	typ := ""
+	if len(types) > 0 {
+		typ = types[0]
+	}
+	if typ == "array" {
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		items := newJSONSchema(schema.document, resolvedSchema(schema)["items"])
+		array := make([]interface{}, len(values))
+		for i, value := range values {
+			array[i] = parameterValue(items, []string{value})
+		}
+		return array
+	}
+
+	value := values[0]
+	switch typ {
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// resolvedSchema returns the schema object, following its reference
+func resolvedSchema(schema *jsonSchema) map[string]interface{} {
+	node := schema.schema
+	for depth := 0; depth < maxSchemaDepth; depth++ {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return object
+		}
+		target, err := schema.resolve(ref)
+		if err != nil {
+			return nil
+		}
+		node = target
+	}
+	return nil
+}
+
+// openAPIMediaType returns the media type object of the content that matches the content type, e.g. application/*
+func openAPIMediaType(content map[string]interface{}, contentType string) (string, map[string]interface{}) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	candidates := []string{mediaType}
+	if i := strings.Index(mediaType, "/"); i > 0 {
+		candidates = append(candidates, mediaType[:i]+"/*")
+	}
+	candidates = append(candidates, "*/*")
+	for _, candidate := range candidates {
+		for declared, media := range content {
+			declaredType, _, err := mime.ParseMediaType(declared)
+			if err != nil {
+				declaredType = declared
+			}
+			if strings.EqualFold(declaredType, candidate) {
+				object, _ := media.(map[string]interface{})
+				if object == nil {
+					object = map[string]interface{}{}
+				}
+				return declared, object
+			}
+		}
+	}
+	return "", nil
+}
+
+func isJSONMediaType(mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	return strings.HasPrefix(mediaType, "application/json") || strings.Contains(mediaType, "+json")
+}
+
+// response returns the response of the operation for the given status, or the first success response by default
+func (o *openAPIOperation) response(status int) (int, map[string]interface{}, error) {
+	var key string
+	if status != 0 {
+		key = o.responseKey(status)
+		if key == "" {
+			return 0, nil, fmt.Errorf("operation %s does not declare a response with status %d", o.id, status)
+		}
+	} else {
+		codes := sortedKeys(o.responses)
+		for _, code := range codes {
+			if strings.HasPrefix(code, "2") {
+				key = code
+				break
+			}
+		}
+		if key == "" && len(codes) > 0 {
+			key = codes[0]
+		}
+		if key == "" {
+			return 0, nil, fmt.Errorf("operation %s does not declare any responses", o.id)
+		}
+		status = openAPIStatusCode(key)
+	}
+
+	response, ok := o.spec.resolve(o.responses[key])
+	if !ok {
+		return 0, nil, fmt.Errorf("operation %s has an invalid response %s", o.id, key)
+	}
+	return status, response, nil
+}
+
+// responseKey returns the key of the response declared for the status, i.e. the status itself, its range, e.g. 2XX,
+// or default. An empty key is returned if the status is not declared
+func (o *openAPIOperation) responseKey(status int) string {
+	for _, candidate := range []string{strconv.Itoa(status), strconv.Itoa(status/100) + "XX", "default"} {
+		if _, ok := o.responses[candidate]; ok {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// openAPIStatusCode converts the key of a response to a status code, e.g. 2XX is 200 and default is 200
+func openAPIStatusCode(key string) int {
+	if key == "default" {
+		return http.StatusOK
+	}
+	if code, err := strconv.Atoi(strings.Replace(strings.ToUpper(key), "XX", "00", 1)); err == nil {
+		return code
+	}
+	return http.StatusOK
+}
+
+// OpenAPIStatus serves the response declared for the given status code by the operation instead of the first
+// success response. The operation is identified by its operationId or by its method and path, e.g. GET /users/{id}
+func OpenAPIStatus(operation string, status int) OpenAPIMockOption {
+	return func(o *openAPIMockOptions) {
+		o.statuses[operation] = status
+	}
+}
+
+// OpenAPIExample serves the named example of the response of the operation instead of the first example
+func OpenAPIExample(operation string, name string) OpenAPIMockOption {
+	return func(o *openAPIMockOptions) {
+		o.examples[operation] = name
+	}
+}
+
+// OpenAPIServer mocks the operations of the server at the given url instead of the first server of the document,
+// e.g. http://localhost:8080/v1
+func OpenAPIServer(url string) OpenAPIMockOption {
+	return func(o *openAPIMockOptions) {
+		o.server = url
+	}
+}
+
+// MocksFromOpenAPI creates a mock for each operation of an OpenAPI 3 document in JSON or YAML format. Mocks match the
+// path of the operation on the first server of the document and respond with the first success response of the operation.
+// The body is the example of the response, or is generated from its schema. Requests that do not match the parameters or
+// request body of the operation are not matched. Mocks respond to any number of requests.
+// MocksFromOpenAPI panics if the document is invalid
+func MocksFromOpenAPI(path string, options ...OpenAPIMockOption) []*Mock {
+	mocks, err := mocksFromOpenAPI(path, options...)
+	if err != nil {
+		panic(err)
+	}
+	return mocks
+}
+
+func mocksFromOpenAPI(path string, options ...OpenAPIMockOption) ([]*Mock, error) {
+	opts := &openAPIMockOptions{statuses: map[string]int{}, examples: map[string]string{}}
+	for _, option := range options {
+		option(opts)
+	}
+
+	document, err := readJSONDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	if opts.server != "" {
+		if doc, ok := document.(map[string]interface{}); ok {
+			doc["servers"] = []interface{}{map[string]interface{}{"url": opts.server}}
+		}
+	}
+	spec, err := newOpenAPISpec(document)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	statuses := map[*openAPIOperation]int{}
+	for name, status := range opts.statuses {
+		op := spec.operation(name)
+		if op == nil {
+			return nil, fmt.Errorf("%s: unknown operation %s", path, name)
+		}
+		statuses[op] = status
+	}
+	examples := map[*openAPIOperation]string{}
+	for name, example := range opts.examples {
+		op := spec.operation(name)
+		if op == nil {
+			return nil, fmt.Errorf("%s: unknown operation %s", path, name)
+		}
+		examples[op] = example
+	}
+
+	var mocks []*Mock
+	for _, op := range spec.operations {
+		mock, err := op.newMock(statuses[op], examples[op])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		mocks = append(mocks, mock)
+	}
+	return mocks, nil
+}
+
+func (o *openAPIOperation) newMock(status int, example string) (*Mock, error) {
+	status, response, err := o.response(status)
+	if err != nil {
+		return nil, err
+	}
+
+	mock := NewMock().Label(o.id)
+	mockRequest := mock.Method(o.method)
+	mock.request.url = &url.URL{Host: o.spec.server.Host, Path: o.pathRegexp.String()}
+	mockRequest.AddMatcher(func(req *http.Request, _ *MockRequest) error {
+		if !o.pathRegexp.MatchString(req.URL.Path) {
+			return nil
+		}
+		errs := o.validateRequest(req)
+		return errorOrNil(len(errs) == 0, func() string {
+			return fmt.Sprintf("request does not match OpenAPI operation %s: %s", o.id, strings.Join(errs, "; "))
+		})
+	})
+
+	mockResponse := mockRequest.RespondWith().Status(status).AnyTimes()
+
+	headers, _ := response["headers"].(map[string]interface{})
+	for _, name := range sortedKeys(headers) {
+		header, ok := o.spec.resolve(headers[name])
+		if !ok || strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+		value, ok := header["example"]
+		if !ok {
+			value = o.spec.schema(header["schema"]).example()
+		}
+		if value != nil {
+			mockResponse.Header(name, jsonPathValueString(value))
+		}
+	}
+
+	content, _ := response["content"].(map[string]interface{})
+	if len(content) == 0 {
+		if example != "" {
+			return nil, fmt.Errorf("operation %s has no example %s", o.id, example)
+		}
+		return mockResponse.End(), nil
+	}
+
+	mediaType, media := openAPIMediaType(content, "application/json")
+	if media == nil {
+		mediaType = sortedKeys(content)[0]
+		media, _ = content[mediaType].(map[string]interface{})
+	}
+	value, err := o.example(media, example)
+	if err != nil {
+		return nil, err
+	}
+	mockResponse.Header("Content-Type", mediaType)
+
+	if s, ok := value.(string); ok && !isJSONMediaType(mediaType) {
+		return mockResponse.Body(s).End(), nil
+	}
+	body, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return mockResponse.Body(string(body)).End(), nil
+}
+
+// example returns the named example of the media type, or its first example or an example generated from its schema
+func (o *openAPIOperation) example(media map[string]interface{}, name string) (interface{}, error) {
+	examples, _ := media["examples"].(map[string]interface{})
+	if name != "" {
+		example, ok := o.spec.resolve(examples[name])
+		if !ok {
+			return nil, fmt.Errorf("operation %s has no example %s", o.id, name)
+		}
+		return example["value"], nil
+	}
+	if value, ok := media["example"]; ok {
+		return value, nil
+	}
+	if len(examples) > 0 {
+		if example, ok := o.spec.resolve(examples[sortedKeys(examples)[0]]); ok {
+			return example["value"], nil
+		}
+	}
+	return o.spec.schema(media["schema"]).example(), nil
+}
@@ -0,0 +1,333 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmlTemplate "html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+type (
+	// OpenAPICoverageCollector records the operations of an OpenAPI document and the status codes of their responses that
+	// are exercised by tests. The collector is safe for concurrent use, so the same collector can be attached to all tests
+	// of a package either as an observer or as a report formatter
+	OpenAPICoverageCollector struct {
+		spec        *openAPISpec
+		specPath    string
+		storagePath string
+		fs          fileSystem
+		mu          sync.Mutex
+		// calls counts the requests per operation and the responses per operation and response key
+		calls     map[*openAPIOperation]int
+		responses map[*openAPIOperation]map[string]int
+	}
+
+	openAPICoverageReport struct {
+		Spec       string                     `json:"spec"`
+		Summary    openAPICoverageSummary     `json:"summary"`
+		Operations []openAPICoverageOperation `json:"operations"`
+	}
+
+	openAPICoverageSummary struct {
+		Operations        int     `json:"operations"`
+		TestedOperations  int     `json:"testedOperations"`
+		OperationCoverage float64 `json:"operationCoverage"`
+		Responses         int     `json:"responses"`
+		TestedResponses   int     `json:"testedResponses"`
+		ResponseCoverage  float64 `json:"responseCoverage"`
+	}
+
+	openAPICoverageOperation struct {
+		OperationID string                    `json:"operationId,omitempty"`
+		Method      string                    `json:"method"`
+		Path        string                    `json:"path"`
+		Tested      bool                      `json:"tested"`
+		Calls       int                       `json:"calls"`
+		Responses   []openAPICoverageResponse `json:"responses"`
+	}
+
+	openAPICoverageResponse struct {
+		Status string `json:"status"`
+		Tested bool   `json:"tested"`
+		Calls  int    `json:"calls"`
+	}
+)
+
+// OpenAPICoverage creates a collector for the OpenAPI 3 document at the given spec path. The coverage report is written to
+// the given path or .coverage by default. Panics if the document cannot be read
+func OpenAPICoverage(specPath string, path ...string) *OpenAPICoverageCollector {
+	spec, err := loadOpenAPISpec(specPath)
+	if err != nil {
+		panic(err)
+	}
+	storagePath := ".coverage"
+	if len(path) > 0 {
+		storagePath = path[0]
+	}
+	return &OpenAPICoverageCollector{
+		spec:        spec,
+		specPath:    specPath,
+		storagePath: storagePath,
+		fs:          &osFileSystem{},
+		calls:       map[*openAPIOperation]int{},
+		responses:   map[*openAPIOperation]map[string]int{},
+	}
+}
+
+// Observe records the operation of the inbound request and the status of the final response. It can be passed to
+// APITest.Observe
+func (c *OpenAPICoverageCollector) Observe(res *http.Response, req *http.Request, _ *APITest) {
+	if res == nil || req == nil {
+		return
+	}
+	c.record(req, res.StatusCode)
+}
+
+// Format records the operations and statuses of the inbound requests and final responses received by the recorder,
+// which allows the collector to be used as the report formatter of a test or scenario
+func (c *OpenAPICoverageCollector) Format(recorder *Recorder) {
+	var inbound []*http.Request
+	for _, event := range recorder.Events {
+		switch e := event.(type) {
+		case HttpRequest:
+			if e.Source == quoted(ConsumerName) && e.Value != nil {
+				inbound = append(inbound, e.Value)
+			}
+		case HttpResponse:
+			if e.Target == quoted(ConsumerName) && e.Value != nil && len(inbound) > 0 {
+				c.record(inbound[0], e.Value.StatusCode)
+				inbound = inbound[1:]
+			}
+		}
+	}
+}
+
+// TestMain runs the tests, writes the coverage report and exits with the exit code of the tests, e.g.
+//
+//	var coverage = apitest.OpenAPICoverage("api/openapi.yaml")
+//
+//	func TestMain(m *testing.M) {
+//		coverage.TestMain(m)
+//	}
+func (c *OpenAPICoverageCollector) TestMain(m interface{ Run() int }) {
+	code := m.Run()
+	if err := c.WriteReport(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// WriteReport writes the coverage collected so far as openapi-coverage.json and openapi-coverage.html to the storage
+// path. The report lists the operations and declared response status codes which have not been tested
+func (c *OpenAPICoverageCollector) WriteReport() error {
+	report := c.report()
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := htmlTemplate.New("openAPICoverage").Parse(openAPICoverageTemplate)
+	if err != nil {
+		return err
+	}
+	var html bytes.Buffer
+	if err := tmpl.Execute(&html, report); err != nil {
+		return err
+	}
+
+	if err := c.fs.mkdirAll(c.storagePath, os.ModePerm); err != nil {
+		return err
+	}
+	if err := c.writeFile(fmt.Sprintf("%s/openapi-coverage.json", c.storagePath), data); err != nil {
+		return err
+	}
+	if err := c.writeFile(fmt.Sprintf("%s/openapi-coverage.html", c.storagePath), html.Bytes()); err != nil {
+		return err
+	}
+
+	s, _ := filepath.Abs(fmt.Sprintf("%s/openapi-coverage.html", c.storagePath))
+	fmt.Printf("Created OpenAPI coverage report (%d/%d operations, %d/%d responses): %s\n",
+		report.Summary.TestedOperations, report.Summary.Operations,
+		report.Summary.TestedResponses, report.Summary.Responses, filepath.FromSlash(s))
+	return nil
+}
+
+func (c *OpenAPICoverageCollector) writeFile(name string, data []byte) error {
+	f, err := c.fs.create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
+
+// record counts the call of the operation matching the request. Requests which do not match an operation are ignored,
+// OpenAPISpec reports those as contract violations
+func (c *OpenAPICoverageCollector) record(req *http.Request, status int) {
+	op := c.spec.findOperation(req)
+	if op == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[op]++
+	if key := op.responseKey(status); key != "" {
+		if c.responses[op] == nil {
+			c.responses[op] = map[string]int{}
+		}
+		c.responses[op][key]++
+	}
+}
+
+func (c *OpenAPICoverageCollector) report() openAPICoverageReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := openAPICoverageReport{Spec: c.specPath, Operations: []openAPICoverageOperation{}}
+	for _, op := range c.spec.operations {
+		operation := openAPICoverageOperation{
+			OperationID: op.id,
+			Method:      op.method,
+			Path:        op.path,
+			Tested:      c.calls[op] > 0,
+			Calls:       c.calls[op],
+			Responses:   []openAPICoverageResponse{},
+		}
+		for _, key := range sortedKeys(op.responses) {
+			calls := c.responses[op][key]
+			operation.Responses = append(operation.Responses, openAPICoverageResponse{Status: key, Tested: calls > 0, Calls: calls})
+			report.Summary.Responses++
+			if calls > 0 {
+				report.Summary.TestedResponses++
+			}
+		}
+		report.Summary.Operations++
+		if operation.Tested {
+			report.Summary.TestedOperations++
+		}
+		report.Operations = append(report.Operations, operation)
+	}
+	report.Summary.OperationCoverage = percentage(report.Summary.TestedOperations, report.Summary.Operations)
+	report.Summary.ResponseCoverage = percentage(report.Summary.TestedResponses, report.Summary.Responses)
+
+	sort.SliceStable(report.Operations, func(i, j int) bool {
+		a, b := report.Operations[i], report.Operations[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return methodIndex(a.Method) < methodIndex(b.Method)
+	})
+	return report
+}
+
+func percentage(n, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(n*1000/total) / 10
+}
+
+func methodIndex(method string) int {
+	for i, m := range openAPIMethods {
+		if m == method {
+			return i
+		}
+	}
+	return len(openAPIMethods)
+}
+
+const openAPICoverageTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <link rel="stylesheet" href="https://stackpath.bootstrapcdn.com/bootstrap/4.1.2/css/bootstrap.min.css">
+    <title>OpenAPI coverage</title>
+    <style>
+        body {
+            padding-top: 2rem;
+            padding-bottom: 2rem;
+        }
+    </style>
+</head>
+<body>
+<div class="container">
+    <h1>OpenAPI coverage</h1>
+    <p class="lead">{{ .Spec }}</p>
+    <table class="table table-sm">
+        <tbody>
+        <tr>
+            <th>Operations</th>
+            <td>{{ .Summary.TestedOperations }}/{{ .Summary.Operations }} ({{ printf "%.1f" .Summary.OperationCoverage }}%)</td>
+        </tr>
+        <tr>
+            <th>Responses</th>
+            <td>{{ .Summary.TestedResponses }}/{{ .Summary.Responses }} ({{ printf "%.1f" .Summary.ResponseCoverage }}%)</td>
+        </tr>
+        </tbody>
+    </table>
+
+    <h2>Untested</h2>
+    <table class="table table-sm">
+        <thead>
+        <tr>
+            <th>Operation</th>
+            <th>Status codes</th>
+        </tr>
+        </thead>
+        <tbody>
+        {{- range .Operations }}
+        {{- $operation := . }}
+        {{- range .Responses }}
+        {{- if not .Tested }}
+        <tr>
+            <td><code>{{ $operation.Method }} {{ $operation.Path }}</code>{{ if not $operation.Tested }} <span class="badge badge-danger">untested</span>{{ end }}</td>
+            <td>{{ .Status }}</td>
+        </tr>
+        {{- end }}
+        {{- end }}
+        {{- end }}
+        </tbody>
+    </table>
+
+    <h2>Operations</h2>
+    <table class="table table-sm">
+        <thead>
+        <tr>
+            <th>Method</th>
+            <th>Path</th>
+            <th>Operation ID</th>
+            <th>Calls</th>
+            <th>Responses</th>
+        </tr>
+        </thead>
+        <tbody>
+        {{- range .Operations }}
+        <tr class="{{ if .Tested }}table-success{{ else }}table-danger{{ end }}">
+            <td>{{ .Method }}</td>
+            <td><code>{{ .Path }}</code></td>
+            <td>{{ .OperationID }}</td>
+            <td>{{ .Calls }}</td>
+            <td>
+                {{- range .Responses }}
+                <span class="badge {{ if .Tested }}badge-success{{ else }}badge-danger{{ end }}" title="{{ .Calls }} calls">{{ .Status }}</span>
+                {{- end }}
+            </td>
+        </tr>
+        {{- end }}
+        </tbody>
+    </table>
+</div>
+</body>
+</html>
+`
@@ -0,0 +1,108 @@
+package apitest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPICoverage_Observe(t *testing.T) {
+	coverage := OpenAPICoverage("testdata/openapi/users.yaml")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, path := range []string{"/users/1", "/v1/users/1", "/users/2", "/users/me", "/unknown"} {
+		New().
+			Observe(coverage.Observe).
+			Handler(handler).
+			Get(path).
+			Expect(t).
+			End()
+	}
+
+	report := coverage.report()
+	assert.Equal(t, openAPICoverageSummary{
+		Operations:        5,
+		TestedOperations:  2,
+		OperationCoverage: 40,
+		Responses:         7,
+		TestedResponses:   3,
+		ResponseCoverage:  42.8,
+	}, report.Summary)
+	assert.Equal(t, openAPICoverageOperation{
+		OperationID: "getUser",
+		Method:      http.MethodGet,
+		Path:        "/users/{id}",
+		Tested:      true,
+		Calls:       3,
+		Responses: []openAPICoverageResponse{
+			{Status: "200", Tested: true, Calls: 2},
+			{Status: "404", Tested: true, Calls: 1},
+		},
+	}, report.Operations[3])
+	assert.Equal(t, "/users", report.Operations[0].Path)
+	assert.False(t, report.Operations[0].Tested)
+}
+
+func TestOpenAPICoverage_Format(t *testing.T) {
+	coverage := OpenAPICoverage("testdata/openapi/users.yaml")
+
+	New().
+		Report(coverage).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}).
+		Post("/users").
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+
+	report := coverage.report()
+	assert.Equal(t, 1, report.Summary.TestedOperations)
+	assert.Equal(t, 1, report.Summary.TestedResponses)
+	assert.Equal(t, http.MethodPost, report.Operations[1].Method)
+	assert.Equal(t, []openAPICoverageResponse{
+		{Status: "201", Tested: false, Calls: 0},
+		{Status: "400", Tested: true, Calls: 1},
+	}, report.Operations[1].Responses)
+}
+
+func TestOpenAPICoverage_WriteReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	coverage := OpenAPICoverage("testdata/openapi/users.yaml", dir)
+	coverage.record(httpRequest(http.MethodDelete, "/users/1"), http.StatusNoContent)
+
+	err = coverage.WriteReport()
+
+	assert.NoError(t, err)
+	data, err := ioutil.ReadFile(dir + "/openapi-coverage.json")
+	assert.NoError(t, err)
+	var report openAPICoverageReport
+	assert.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, coverage.report(), report)
+
+	html, err := ioutil.ReadFile(dir + "/openapi-coverage.html")
+	assert.NoError(t, err)
+	assert.Contains(t, string(html), "1/5 (20.0%)")
+	assert.Contains(t, string(html), "<code>GET /users/{id}</code>")
+	assert.False(t, strings.Contains(string(html), "<code>DELETE /users/{id}</code>"))
+}
+
+func httpRequest(method, path string) *http.Request {
+	req, _ := http.NewRequest(method, path, nil)
+	return req
+}
@@ -0,0 +1,162 @@
+package apitest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMocksFromOpenAPI(t *testing.T) {
+	mocks := MocksFromOpenAPI("testdata/openapi/users.yaml")
+
+	assert.Len(t, mocks, 5)
+	var labels []string
+	for _, mock := range mocks {
+		labels = append(labels, mock.label)
+	}
+	assert.Equal(t, []string{"listUsers", "createUser", "getCurrentUser", "getUser", "DELETE /users/{id}"}, labels)
+
+	getUser := mocks[3]
+	assert.Equal(t, http.MethodGet, getUser.request.method)
+	assert.Equal(t, "users.example.com", getUser.request.url.Host)
+	assert.Equal(t, `^/v1/users/([^/]+)$`, getUser.request.url.Path)
+	assert.Equal(t, http.StatusOK, getUser.response.statusCode)
+	assert.JSONEq(t, `{"id": 1, "name": "jan"}`, getUser.response.body)
+	assert.Equal(t, 0, getUser.minTimes)
+	assert.Equal(t, unlimitedTimes, getUser.maxTimes)
+
+	listUsers := mocks[0]
+	assert.Equal(t, map[string][]string{"X-Total-Count": {"1"}, "Content-Type": {"application/json"}}, listUsers.response.headers)
+	assert.JSONEq(t, `[{"id": 0, "name": "string", "email": "user@example.com", "createdAt": "2020-01-01T00:00:00Z"}]`, listUsers.response.body)
+
+	createUser := mocks[1]
+	assert.Equal(t, http.StatusCreated, createUser.response.statusCode)
+	assert.JSONEq(t, `{"id": 1234, "name": "jan", "email": "jan@example.com"}`, createUser.response.body)
+
+	deleteUser := mocks[4]
+	assert.Equal(t, http.StatusNoContent, deleteUser.response.statusCode)
+	assert.Equal(t, "", deleteUser.response.body)
+}
+
+func TestMocksFromOpenAPI_Options(t *testing.T) {
+	mocks := MocksFromOpenAPI("testdata/openapi/users.yaml",
+		OpenAPIServer("http://localhost:8080/api"),
+		OpenAPIStatus("createUser", http.StatusBadRequest),
+		OpenAPIStatus("GET /users/{id}", http.StatusNotFound))
+
+	getUser := mocks[3]
+	assert.Equal(t, "localhost:8080", getUser.request.url.Host)
+	assert.Equal(t, `^/api/users/([^/]+)$`, getUser.request.url.Path)
+	assert.Equal(t, http.StatusNotFound, getUser.response.statusCode)
+
+	createUser := mocks[1]
+	assert.Equal(t, http.StatusBadRequest, createUser.response.statusCode)
+	assert.Equal(t, []string{"application/problem+json"}, createUser.response.headers["Content-Type"])
+	assert.JSONEq(t, `{"title": "Not Found"}`, createUser.response.body)
+
+	mocks = MocksFromOpenAPI("testdata/openapi/users.yaml", OpenAPIExample("getUser", "tom"))
+	assert.JSONEq(t, `{"id": 2, "name": "tom"}`, mocks[3].response.body)
+
+	_, err := mocksFromOpenAPI("testdata/openapi/users.yaml", OpenAPIStatus("getCurrentUser", http.StatusNotFound))
+	assert.EqualError(t, err, "testdata/openapi/users.yaml: operation getCurrentUser does not declare a response with status 404")
+	_, err = mocksFromOpenAPI("testdata/openapi/users.yaml", OpenAPIExample("getUser", "unknown"))
+	assert.EqualError(t, err, "testdata/openapi/users.yaml: operation getUser has no example unknown")
+	_, err = mocksFromOpenAPI("testdata/openapi/users.yaml", OpenAPIStatus("updateUser", http.StatusOK))
+	assert.EqualError(t, err, "testdata/openapi/users.yaml: unknown operation updateUser")
+}
+
+func TestMocksFromOpenAPI_MatchesRequests(t *testing.T) {
+	tests := map[string]struct {
+		method        string
+		url           string
+		body          string
+		headers       map[string]string
+		expectedLabel string
+		expectedError string
+	}{
+		"path without template": {
+			method:        http.MethodGet,
+			url:           "http://users.example.com/v1/users/me",
+			expectedLabel: "getCurrentUser",
+		},
+		"path parameter": {
+			method:        http.MethodGet,
+			url:           "http://users.example.com/v1/users/12",
+			headers:       map[string]string{"X-Request-Id": "3fa85f64-5717-4562-b3fc-2c963f66afa6"},
+			expectedLabel: "getUser",
+		},
+		"invalid path parameter": {
+			method:        http.MethodDelete,
+			url:           "http://users.example.com/v1/users/abc",
+			expectedError: "request does not match OpenAPI operation DELETE /users/{id}: path parameter 'id' /: expected integer, got string",
+		},
+		"missing header": {
+			method:        http.MethodGet,
+			url:           "http://users.example.com/v1/users/12",
+			expectedError: "request does not match OpenAPI operation getUser: missing required header parameter 'X-Request-Id'",
+		},
+		"query parameter": {
+			method:        http.MethodGet,
+			url:           "http://users.example.com/v1/users?limit=10",
+			expectedLabel: "listUsers",
+		},
+		"invalid query parameter": {
+			method:        http.MethodGet,
+			url:           "http://users.example.com/v1/users?limit=500",
+			expectedError: "request does not match OpenAPI operation listUsers: query parameter 'limit' /: value 500 is greater than maximum 100",
+		},
+		"request body": {
+			method:        http.MethodPost,
+			url:           "http://users.example.com/v1/users",
+			body:          `{"name": "jan", "email": "jan@example.com"}`,
+			headers:       map[string]string{"Content-Type": "application/json; charset=utf-8"},
+			expectedLabel: "createUser",
+		},
+		"invalid request body": {
+			method:        http.MethodPost,
+			url:           "http://users.example.com/v1/users",
+			body:          `{"name": "", "admin": true}`,
+			headers:       map[string]string{"Content-Type": "application/json"},
+			expectedError: "request does not match OpenAPI operation createUser: request body /: missing required property 'email'; request body /admin: property 'admin' is not allowed; request body /name: length 0 is less than minLength 1",
+		},
+		"missing request body": {
+			method:        http.MethodPost,
+			url:           "http://users.example.com/v1/users",
+			expectedError: "request does not match OpenAPI operation createUser: request body is required",
+		},
+		"undeclared content type": {
+			method:        http.MethodPost,
+			url:           "http://users.example.com/v1/users",
+			body:          `name=jan`,
+			headers:       map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			expectedError: "request does not match OpenAPI operation createUser: request content type 'application/x-www-form-urlencoded' is not declared",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mocks := MocksFromOpenAPI("testdata/openapi/users.yaml")
+			req, _ := http.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			for key, value := range test.headers {
+				req.Header.Set(key, value)
+			}
+
+			mockResponse, err := matches(req, mocks)
+
+			if test.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedLabel, mockResponse.mock.label)
+		})
+	}
+}
+
+func TestMocksFromOpenAPI_PanicsIfInvalid(t *testing.T) {
+	assert.Panics(t, func() {
+		MocksFromOpenAPI("testdata/mocks/payments.yaml")
+	})
+}
@@ -50,6 +50,8 @@ type (
 		Target    string
 		Value     *http.Request
 		Timestamp time.Time
+		// Passthrough is true if the request was sent to the real service instead of a mock
+		Passthrough bool
 	}
 
 	// HttpResponse represents an http response
@@ -58,6 +60,8 @@ type (
 		Target    string
 		Value     *http.Response
 		Timestamp time.Time
+		// Passthrough is true if the response was returned by the real service instead of a mock
+		Passthrough bool
 	}
 )
 
@@ -0,0 +1,284 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var variablePattern = regexp.MustCompile(`{{\s*([A-Za-z0-9_.-]+)\s*}}`)
+
+// Scenario chains multiple api tests into a single flow, e.g. login and then use the returned token.
+// Values captured from the response of a step are available to the requests of subsequent steps
+// using the {{name}} placeholder syntax. The whole scenario is reported as a single sequence diagram
+type Scenario struct {
+	name                 string
+	debugEnabled         bool
+	networkingEnabled    bool
+	networkingHTTPClient *http.Client
+	handler              http.Handler
+	reporter             ReportFormatter
+	recorder             *Recorder
+	verifier             Verifier
+	meta                 map[string]interface{}
+	vars                 map[string]string
+	steps                []*APITest
+}
+
+// NewScenario creates a new scenario. The name is optional and will appear in test reports
+func NewScenario(name ...string) *Scenario {
+	scenario := &Scenario{
+		meta: map[string]interface{}{},
+		vars: map[string]string{},
+	}
+
+	if len(name) > 0 {
+		scenario.name = name[0]
+	}
+
+	return scenario
+}
+
+// Handler defines the http handler that is invoked by each step of the scenario
+func (s *Scenario) Handler(handler http.Handler) *Scenario {
+	s.handler = handler
+	return s
+}
+
+// HandlerFunc defines the http handler that is invoked by each step of the scenario
+func (s *Scenario) HandlerFunc(handlerFunc http.HandlerFunc) *Scenario {
+	s.handler = handlerFunc
+	return s
+}
+
+// EnableNetworking will enable networking for provided clients in each step of the scenario
+func (s *Scenario) EnableNetworking(cli ...*http.Client) *Scenario {
+	s.networkingEnabled = true
+	if len(cli) == 1 {
+		s.networkingHTTPClient = cli[0]
+		return s
+	}
+	s.networkingHTTPClient = http.DefaultClient
+	return s
+}
+
+// Debug logs to the console the http wire representation of all http interactions in each step of the scenario
+func (s *Scenario) Debug() *Scenario {
+	s.debugEnabled = true
+	return s
+}
+
+// Report provides a hook to add custom formatting to the output of the scenario.
+// The report is generated once the scenario has ended
+func (s *Scenario) Report(reporter ReportFormatter) *Scenario {
+	s.reporter = reporter
+	return s
+}
+
+// Recorder provides a hook to add a recorder to the scenario
+func (s *Scenario) Recorder(recorder *Recorder) *Scenario {
+	s.recorder = recorder
+	return s
+}
+
+// Verifier allows consumers to override the verification implementation used by each step of the scenario
+func (s *Scenario) Verifier(v Verifier) *Scenario {
+	s.verifier = v
+	return s
+}
+
+// Meta provides a hook to add custom meta data to the scenario which can be picked up when defining a custom reporter
+func (s *Scenario) Meta(meta map[string]interface{}) *Scenario {
+	s.meta = meta
+	return s
+}
+
+// Var sets a variable that can be referenced by the requests of the scenario
+func (s *Scenario) Var(name, value string) *Scenario {
+	s.vars[name] = value
+	return s
+}
+
+// Vars returns the variables that were set or captured while running the scenario
+func (s *Scenario) Vars() map[string]string {
+	return s.vars
+}
+
+// Step creates the next api test of the scenario. The step inherits the configuration of the scenario and
+// runs when End is called on its response, so any captured variables are available to the steps that follow
+func (s *Scenario) Step(name ...string) *APITest {
+	step := New(name...)
+	step.scenario = s
+	step.vars = s.vars
+	step.handler = s.handler
+	step.debugEnabled = s.debugEnabled
+	step.networkingEnabled = s.networkingEnabled
+	step.networkingHTTPClient = s.networkingHTTPClient
+	step.verifier = s.verifier
+
+	if s.reporter != nil {
+		if s.recorder == nil {
+			s.recorder = NewTestRecorder()
+		}
+		step.reporter = s.reporter
+		step.recorder = s.recorder
+	}
+
+	s.steps = append(s.steps, step)
+	return step
+}
+
+// End completes the scenario and formats the events recorded by all of its steps as a single report
+func (s *Scenario) End() {
+	if s.reporter == nil || len(s.steps) == 0 {
+		return
+	}
+	defer s.recorder.Reset()
+
+	first, last := s.steps[0], s.steps[len(s.steps)-1]
+
+	s.recorder.
+		AddTitle(s.name).
+		AddSubTitle(fmt.Sprintf("%d steps", len(s.steps)))
+
+	sort.SliceStable(s.recorder.Events, func(i, j int) bool {
+		return s.recorder.Events[i].GetTime().Before(s.recorder.Events[j].GetTime())
+	})
+
+	meta := map[string]interface{}{}
+	for k, v := range s.meta {
+		meta[k] = v
+	}
+
+	if status, err := s.recorder.ResponseStatus(); err == nil {
+		meta["status_code"] = status
+	}
+	meta["path"] = first.interpolate(first.request.url)
+	meta["method"] = first.request.method
+	meta["name"] = s.name
+	meta["hash"] = createHash(meta)
+	meta["duration"] = last.finished.Sub(first.started).Nanoseconds()
+
+	s.recorder.AddMeta(meta)
+	s.reporter.Format(s.recorder)
+}
+
+type capture struct {
+	name    string
+	extract func(*http.Response) (string, error)
+}
+
+// CaptureJSON stores the value of the given field of the JSON response body in the named variable.
+// Nested fields and array elements are separated by dots, e.g. "data.items.0.id"
+func (r *Response) CaptureJSON(name, field string) *Response {
+	r.captures = append(r.captures, capture{name: name, extract: func(res *http.Response) (string, error) {
+		return jsonField(res, field)
+	}})
+	return r
+}
+
+// CaptureHeader stores the value of the given response header in the named variable
+func (r *Response) CaptureHeader(name, header string) *Response {
+	r.captures = append(r.captures, capture{name: name, extract: func(res *http.Response) (string, error) {
+		value := res.Header.Get(header)
+		if value == "" {
+			return "", fmt.Errorf("header '%s' not present in response", header)
+		}
+		return value, nil
+	}})
+	return r
+}
+
+// CaptureCookie stores the value of the given response cookie in the named variable
+func (r *Response) CaptureCookie(name, cookie string) *Response {
+	r.captures = append(r.captures, capture{name: name, extract: func(res *http.Response) (string, error) {
+		for _, c := range res.Cookies() {
+			if c.Name == cookie {
+				return c.Value, nil
+			}
+		}
+		return "", fmt.Errorf("cookie '%s' not present in response", cookie)
+	}})
+	return r
+}
+
+func (a *APITest) captureVars(res *http.Response) {
+	for _, c := range a.response.captures {
+		value, err := c.extract(copyHttpResponse(res))
+		if err != nil {
+			a.verifier.Fail(a.t, fmt.Sprintf("failed to capture variable '%s': %s", c.name, err))
+			continue
+		}
+		a.vars[c.name] = value
+	}
+}
+
+// interpolate replaces {{name}} placeholders with the value of the variable. Unknown variables are left as is
+func (a *APITest) interpolate(in string) string {
+	if len(a.vars) == 0 {
+		return in
+	}
+	return variablePattern.ReplaceAllStringFunc(in, func(placeholder string) string {
+		name := variablePattern.FindStringSubmatch(placeholder)[1]
+		if value, ok := a.vars[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+func jsonField(res *http.Response, field string) (string, error) {
+	if res.Body == nil {
+		return "", fmt.Errorf("field '%s' not present in empty response body", field)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("response body is not valid JSON: %s", err)
+	}
+
+	for _, key := range strings.Split(field, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return "", fmt.Errorf("field '%s' not present in response body", field)
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return "", fmt.Errorf("field '%s' not present in response body", field)
+			}
+			value = v[index]
+		default:
+			return "", fmt.Errorf("field '%s' not present in response body", field)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case nil:
+		return "", fmt.Errorf("field '%s' is null", field)
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		out, err := json.Marshal(v)
+		return string(out), err
+	}
+}
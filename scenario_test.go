@@ -0,0 +1,190 @@
+package apitest_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+	"github.com/steinfletcher/apitest/mocks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScenario_CapturesJSONFieldForSubsequentSteps(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"token": "abc123", "user": {"id": 42}}}`))
+	})
+	handler.HandleFunc("/user/42", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	scenario := apitest.NewScenario("login flow").Handler(handler)
+
+	scenario.Step().
+		Post("/login").
+		Expect(t).
+		Status(http.StatusOK).
+		CaptureJSON("token", "data.token").
+		CaptureJSON("userID", "data.user.id").
+		End()
+
+	scenario.Step().
+		Get("/user/{{userID}}").
+		Header("Authorization", "Bearer {{token}}").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	scenario.End()
+
+	assert.Equal(t, map[string]string{"token": "abc123", "userID": "42"}, scenario.Vars())
+}
+
+func TestScenario_CapturesHeadersAndCookies(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/session", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/orders/987")
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s3ss10n"})
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler.HandleFunc("/orders/987", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil || cookie.Value != "s3ss10n" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		var body map[string]string
+		if err := json.Unmarshal(data, &body); err != nil || body["session"] != "s3ss10n" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("sid") != "s3ss10n" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	scenario := apitest.NewScenario().Handler(handler)
+
+	scenario.Step().
+		Post("/session").
+		Expect(t).
+		Status(http.StatusCreated).
+		CaptureHeader("location", "Location").
+		CaptureCookie("sid", "sid").
+		End()
+
+	scenario.Step().
+		Put("{{location}}").
+		Query("sid", "{{sid}}").
+		Cookie("session", "{{sid}}").
+		JSON(`{"session": "{{sid}}"}`).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	scenario.End()
+}
+
+func TestScenario_LeavesUnknownVariablesUntouched(t *testing.T) {
+	apitest.NewScenario().
+		Var("known", "value").
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := ioutil.ReadAll(r.Body)
+			_, _ = w.Write(data)
+		}).
+		Step().
+		Post("/echo").
+		Body("{{known}} {{unknown}}").
+		Expect(t).
+		Body("value {{unknown}}").
+		End()
+}
+
+func TestScenario_FailsIfVariableCannotBeCaptured(t *testing.T) {
+	var failureMessage string
+	verifier := mocks.NewVerifier()
+	verifier.FailFn = func(t apitest.TestingT, message string, msgAndArgs ...interface{}) bool {
+		failureMessage = message
+		return true
+	}
+
+	apitest.NewScenario().
+		Verifier(verifier).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"a": 1}`))
+		}).
+		Step().
+		Get("/").
+		Expect(t).
+		CaptureJSON("token", "data.token").
+		End()
+
+	assert.Equal(t, "failed to capture variable 'token': field 'data.token' not present in response body", failureMessage)
+}
+
+func TestScenario_ReportsAllStepsAsASingleDiagram(t *testing.T) {
+	getUser := apitest.NewMock().
+		Get("http://localhost:8080").
+		RespondWith().
+		Status(http.StatusOK).
+		Body("1").
+		End()
+
+	reporter := &RecorderCaptor{}
+	handler := http.NewServeMux()
+	handler.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Token", "abc")
+		w.WriteHeader(http.StatusOK)
+	})
+	handler.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		getUserData()
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	scenario := apitest.NewScenario("user journey").
+		Meta(map[string]interface{}{"host": "abc.com"}).
+		Report(reporter).
+		Handler(handler)
+
+	scenario.Step().
+		Post("/login").
+		Expect(t).
+		CaptureHeader("token", "Token").
+		End()
+
+	scenario.Step().
+		Mocks(getUser).
+		Get("/user").
+		Header("Token", "{{token}}").
+		Expect(t).
+		Status(http.StatusAccepted).
+		End()
+
+	assert.Empty(t, reporter.capturedRecorder.Events)
+
+	scenario.End()
+
+	r := reporter.capturedRecorder
+	assert.Equal(t, "user journey", r.Title)
+	assert.Equal(t, "2 steps", r.SubTitle)
+	assert.Len(t, r.Events, 6)
+	assert.Equal(t, "/login", r.Events[0].(apitest.HttpRequest).Value.URL.Path)
+	assert.Equal(t, "abc", r.Events[2].(apitest.HttpRequest).Value.Header.Get("Token"))
+	assert.Equal(t, http.StatusAccepted, r.Meta["status_code"])
+	assert.Equal(t, "/login", r.Meta["path"])
+	assert.Equal(t, "POST", r.Meta["method"])
+	assert.Equal(t, "user journey", r.Meta["name"])
+	assert.Equal(t, "abc.com", r.Meta["host"])
+	assert.NotEmpty(t, r.Meta["duration"])
+}
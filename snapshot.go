@@ -0,0 +1,174 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// UpdateSnapshotsEnv is the environment variable that rewrites the stored snapshots when set to true. Snapshots are also
+// rewritten if the test binary defines an -update flag which is set, e.g. go test ./... -update
+const UpdateSnapshotsEnv = "APITEST_UPDATE_SNAPSHOTS"
+
+// snapshotDir is the directory of the snapshots relative to the package of the test
+var snapshotDir = filepath.Join("testdata", "__snapshots__")
+
+// snapshotMask replaces values that are masked in snapshots
+const snapshotMask = "<masked>"
+
+type (
+	// SnapshotOption configures the content of a snapshot
+	SnapshotOption func(*snapshot)
+
+	snapshot struct {
+		headers      []string
+		masks        []*jsonPath
+		replacements []snapshotReplacement
+	}
+
+	snapshotReplacement struct {
+		re          *regexp.Regexp
+		replacement string
+	}
+)
+
+var snapshotNameReplacer = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// snapshotCalls counts the snapshots taken by each test, so that a test can match more than one snapshot
+var snapshotCalls sync.Map
+
+// SnapshotHeaders selects the response headers stored in the snapshot. Only the Content-Type header is stored by default
+func SnapshotHeaders(headers ...string) SnapshotOption {
+	return func(s *snapshot) {
+		s.headers = headers
+	}
+}
+
+// SnapshotMask replaces the values selected by the JSONPath expressions in the JSON response body with <masked>, which
+// allows volatile values such as timestamps and generated ids to be ignored
+func SnapshotMask(exprs ...string) SnapshotOption {
+	return func(s *snapshot) {
+		for _, expr := range exprs {
+			path, err := compileJSONPath(expr)
+			if err != nil {
+				panic(err)
+			}
+			s.masks = append(s.masks, path)
+		}
+	}
+}
+
+// SnapshotReplace replaces the matches of the regular expression in the snapshot with the replacement, which
+// supports the expansion of the regexp package, e.g. ${1}
+func SnapshotReplace(regex string, replacement string) SnapshotOption {
+	return func(s *snapshot) {
+		s.replacements = append(s.replacements, snapshotReplacement{re: regexp.MustCompile(regex), replacement: replacement})
+	}
+}
+
+func newSnapshot(options ...SnapshotOption) *snapshot {
+	s := &snapshot{headers: []string{"Content-Type"}}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// format renders the status, the selected headers and the body of the response. JSON bodies are masked and indented
+func (s *snapshot) format(res *http.Response) (string, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%d %s\n", res.StatusCode, http.StatusText(res.StatusCode))
+	for _, name := range s.headers {
+		for _, value := range res.Header[http.CanonicalHeaderKey(name)] {
+			fmt.Fprintf(&out, "%s: %s\n", http.CanonicalHeaderKey(name), value)
+		}
+	}
+
+	body, err := readBody(res.Body, func(replacementBody io.ReadCloser) {
+		res.Body = replacementBody
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(body) > 0 {
+		out.WriteString("\n")
+		out.Write(s.formatBody(body))
+		out.WriteString("\n")
+	}
+
+	text := out.String()
+	for _, r := range s.replacements {
+		text = r.re.ReplaceAllString(text, r.replacement)
+	}
+	return text, nil
+}
+
+func (s *snapshot) formatBody(body []byte) []byte {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return bytes.TrimRight(body, "\n")
+	}
+	for _, mask := range s.masks {
+		mask.replace(value, func(interface{}) interface{} {
+			return snapshotMask
+		})
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return bytes.TrimRight(body, "\n")
+	}
+	return bytes.TrimRight(out.Bytes(), "\n")
+}
+
+// snapshotPath returns the file of the snapshot, e.g. testdata/__snapshots__/TestGetUser.snap. Subsequent snapshots
+// of the same test are numbered, e.g. TestGetUser_2.snap
+func snapshotPath(t TestingT, name string) (string, error) {
+	if named, ok := t.(interface{ Name() string }); ok {
+		name = named.Name()
+	}
+	if name == "" {
+		return "", fmt.Errorf("snapshots require the name of the test, use apitest.New(name)")
+	}
+	name = snapshotNameReplacer.ReplaceAllString(name, "_")
+
+	calls, _ := snapshotCalls.LoadOrStore(t, new(int))
+	count := calls.(*int)
+	*count++
+	if *count > 1 {
+		name += "_" + strconv.Itoa(*count)
+	}
+	return filepath.Join(snapshotDir, name+".snap"), nil
+}
+
+func updateSnapshots() bool {
+	if update, _ := strconv.ParseBool(os.Getenv(UpdateSnapshotsEnv)); update {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		update, _ := strconv.ParseBool(f.Value.String())
+		return update
+	}
+	return false
+}
+
+func writeSnapshot(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(content), 0644)
+}
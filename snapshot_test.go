@@ -0,0 +1,182 @@
+package apitest
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot_CreatesAndUpdatesSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(previous string) { snapshotDir = previous }(snapshotDir)
+	snapshotDir = dir
+
+	body := `{"name": "jan"}`
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	})
+	matchSnapshots := func() {
+		New().
+			Handler(handler).
+			Get("/user").
+			Expect(t).
+			MatchSnapshot().
+			End()
+		New().
+			Handler(handler).
+			Get("/user").
+			Expect(t).
+			MatchSnapshot(SnapshotHeaders()).
+			End()
+	}
+
+	matchSnapshots()
+
+	snapshot, err := ioutil.ReadFile(filepath.Join(dir, "TestSnapshot_CreatesAndUpdatesSnapshots.snap"))
+	assert.NoError(t, err)
+	assert.Equal(t, "200 OK\nContent-Type: application/json\n\n{\n  \"name\": \"jan\"\n}\n", string(snapshot))
+	snapshot, err = ioutil.ReadFile(filepath.Join(dir, "TestSnapshot_CreatesAndUpdatesSnapshots_2.snap"))
+	assert.NoError(t, err)
+	assert.Equal(t, "200 OK\n\n{\n  \"name\": \"jan\"\n}\n", string(snapshot))
+
+	body = `{"name": "tom"}`
+	snapshotCalls.Delete(t)
+	_ = os.Setenv(UpdateSnapshotsEnv, "true")
+	defer os.Unsetenv(UpdateSnapshotsEnv)
+
+	matchSnapshots()
+
+	snapshot, err = ioutil.ReadFile(filepath.Join(dir, "TestSnapshot_CreatesAndUpdatesSnapshots.snap"))
+	assert.NoError(t, err)
+	assert.Equal(t, "200 OK\nContent-Type: application/json\n\n{\n  \"name\": \"tom\"\n}\n", string(snapshot))
+}
+
+func TestSnapshot_ReportsDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(previous string) { snapshotDir = previous }(snapshotDir)
+	snapshotDir = dir
+	defer disableSnapshotUpdates()()
+	path := filepath.Join(dir, "reports_diff.snap")
+	assert.NoError(t, writeSnapshot(path, "200 OK\nContent-Type: text/plain\n\nhello\nthere\n"))
+	recorder := &testingTRecorder{}
+
+	New("reports diff").
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("hello\nworld"))
+		}).
+		Get("/hello").
+		Expect(recorder).
+		MatchSnapshot().
+		End()
+
+	assert.Len(t, recorder.errors, 1)
+	assert.Contains(t, recorder.errors[0], "response does not match snapshot "+path)
+	assert.Contains(t, recorder.errors[0], "-there")
+	assert.Contains(t, recorder.errors[0], "+world")
+	snapshot, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "200 OK\nContent-Type: text/plain\n\nhello\nthere\n", string(snapshot))
+}
+
+func TestSnapshot_UpdateRewritesSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(previous string) { snapshotDir = previous }(snapshotDir)
+	snapshotDir = dir
+	defer disableSnapshotUpdates()()
+	_ = os.Setenv(UpdateSnapshotsEnv, "true")
+	path := filepath.Join(dir, "update.snap")
+	assert.NoError(t, writeSnapshot(path, "200 OK\nContent-Type: text/plain\n\nhello\nthere\n"))
+	recorder := &testingTRecorder{}
+
+	New("update").
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("hello\nworld"))
+		}).
+		Get("/hello").
+		Expect(recorder).
+		MatchSnapshot().
+		End()
+
+	assert.Empty(t, recorder.errors)
+	snapshot, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "200 OK\nContent-Type: text/plain\n\nhello\nworld\n", string(snapshot))
+}
+
+func TestSnapshot_FormatMasksJSONValues(t *testing.T) {
+	s := newSnapshot(SnapshotMask("$.items[*].id", "$..createdAt"))
+
+	formatted := s.formatBody([]byte(`{"items": [{"id": 1, "createdAt": "2020-01-01", "price": 1.50}], "total": 12345678901234567890}`))
+
+	assert.Equal(t, `{
+  "items": [
+    {
+      "createdAt": "<masked>",
+      "id": "<masked>",
+      "price": 1.50
+    }
+  ],
+  "total": 12345678901234567890
+}`, string(formatted))
+}
+
+// disableSnapshotUpdates turns off the update mode, which may be enabled by the environment or the -update flag, and
+// returns a function that restores it
+func disableSnapshotUpdates() func() {
+	env, envSet := os.LookupEnv(UpdateSnapshotsEnv)
+	_ = os.Setenv(UpdateSnapshotsEnv, "false")
+	update := flag.Lookup("update")
+	var updateValue string
+	if update != nil {
+		updateValue = update.Value.String()
+		_ = update.Value.Set("false")
+	}
+	return func() {
+		if envSet {
+			_ = os.Setenv(UpdateSnapshotsEnv, env)
+		} else {
+			_ = os.Unsetenv(UpdateSnapshotsEnv)
+		}
+		if update != nil {
+			_ = update.Value.Set(updateValue)
+		}
+	}
+}
+
+// testingTRecorder records the failures of a test that is expected to fail
+type testingTRecorder struct {
+	errors []string
+}
+
+func (r *testingTRecorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *testingTRecorder) Fatal(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func (r *testingTRecorder) Fatalf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,26 @@
+module github.com/steinfletcher/apitest/x/grpctest
+
+go 1.23.0
+
+replace (
+	github.com/steinfletcher/apitest => ../../
+	github.com/steinfletcher/apitest/x/protobuf => ../protobuf
+)
+
+require (
+	github.com/steinfletcher/apitest v0.0.0-20261017031423-44b65859c6e6
+	github.com/steinfletcher/apitest/x/protobuf v0.0.0-20261017031423-44b65859c6e6
+	github.com/stretchr/testify v1.7.0
+	google.golang.org/grpc v1.75.0
+	google.golang.org/protobuf v1.36.6
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.41.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/text v0.26.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
@@ -0,0 +1,323 @@
+// Package grpctest tests gRPC services with the fluent API of apitest, e.g.
+//
+//	grpctest.New().
+//		Service(func(s *grpc.Server) { pb.RegisterUsersServer(s, server) }).
+//		Call("/acme.Users/GetUser").
+//		Message(&pb.GetUserRequest{Id: "1"}).
+//		Expect(t).
+//		Status(codes.OK).
+//		Message(&pb.User{Id: "1", Name: "jan"}).
+//		End()
+//
+// The services are served over an in-memory bufconn listener. Each call runs within an apitest test, so that mocks of
+// the http services called by the application, verifiers, recorders and report formatters work as they do for http
+// tests. The call is recorded as the http/2 request of the gRPC protocol, with the status in the Grpc-Status header.
+package grpctest
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/steinfletcher/apitest"
+	"github.com/steinfletcher/apitest/x/protobuf"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ContentType is the content type of the gRPC messages in reports
+const ContentType = "application/grpc+proto"
+
+const bufSize = 1024 * 1024
+
+// GRPCTest is the top level struct holding the test spec
+type GRPCTest struct {
+	name          string
+	services      []func(*grpc.Server)
+	serverOptions []grpc.ServerOption
+	mocks         []*apitest.Mock
+	reporter      apitest.ReportFormatter
+	recorder      *apitest.Recorder
+	verifier      apitest.Verifier
+	debugEnabled  bool
+	request       *Request
+	response      *Response
+	t             apitest.TestingT
+}
+
+// New creates a new gRPC test. The name is optional and will appear in test reports
+func New(name ...string) *GRPCTest {
+	g := &GRPCTest{}
+	if len(name) > 0 {
+		g.name = name[0]
+	}
+	g.request = &Request{grpcTest: g, metadata: metadata.MD{}}
+	g.response = &Response{grpcTest: g}
+	return g
+}
+
+// Service registers the services of the application on the server, e.g. pb.RegisterUsersServer(s, server)
+func (g *GRPCTest) Service(register func(*grpc.Server)) *GRPCTest {
+	g.services = append(g.services, register)
+	return g
+}
+
+// ServerOptions are used to create the server, e.g. to add the interceptors of the application
+func (g *GRPCTest) ServerOptions(options ...grpc.ServerOption) *GRPCTest {
+	g.serverOptions = append(g.serverOptions, options...)
+	return g
+}
+
+// Mocks is a builder method for setting the mocks of the http services called by the application
+func (g *GRPCTest) Mocks(mocks ...*apitest.Mock) *GRPCTest {
+	g.mocks = mocks
+	return g
+}
+
+// Report provides a hook to add custom formatting to the output of the test
+func (g *GRPCTest) Report(reporter apitest.ReportFormatter) *GRPCTest {
+	g.reporter = reporter
+	return g
+}
+
+// Recorder provides a hook to add a recorder to the test
+func (g *GRPCTest) Recorder(recorder *apitest.Recorder) *GRPCTest {
+	g.recorder = recorder
+	return g
+}
+
+// Verifier allows consumers to override the verification implementation
+func (g *GRPCTest) Verifier(v apitest.Verifier) *GRPCTest {
+	g.verifier = v
+	return g
+}
+
+// Debug logs to the console the http wire representation of the call and of all interactions with mocks
+func (g *GRPCTest) Debug() *GRPCTest {
+	g.debugEnabled = true
+	return g
+}
+
+// Call is the full name of the method to call, e.g. /acme.Users/GetUser
+func (g *GRPCTest) Call(method string) *Request {
+	g.request.method = method
+	return g.request
+}
+
+// Request is the user defined request that will be sent to the service under test
+type Request struct {
+	grpcTest *GRPCTest
+	method   string
+	message  proto.Message
+	metadata metadata.MD
+}
+
+// Message is the request message. An empty message is sent if it is not set
+func (r *Request) Message(msg proto.Message) *Request {
+	r.message = msg
+	return r
+}
+
+// Metadata adds the values to the metadata of the request
+func (r *Request) Metadata(key string, values ...string) *Request {
+	r.metadata.Append(key, values...)
+	return r
+}
+
+// Expect marks the request spec as complete and following code will define the expected response
+func (r *Request) Expect(t apitest.TestingT) *Response {
+	r.grpcTest.t = t
+	return r.grpcTest.response
+}
+
+// Response is the user defined expected response from the service under test
+type Response struct {
+	grpcTest *GRPCTest
+	status   *codes.Code
+	message  proto.Message
+	asserts  []Assert
+}
+
+// Assert is a user defined custom assertion function
+type Assert func(Result) error
+
+// Status is the expected status code of the call
+func (r *Response) Status(code codes.Code) *Response {
+	r.status = &code
+	return r
+}
+
+// Message is the expected response message. Messages are compared semantically using proto.Equal
+func (r *Response) Message(expected proto.Message) *Response {
+	r.message = expected
+	return r
+}
+
+// Assert allows the consumer to provide a user defined function containing their own assertions
+func (r *Response) Assert(fn Assert) *Response {
+	r.asserts = append(r.asserts, fn)
+	return r
+}
+
+// Result is the result of the call
+type Result struct {
+	// Message is the response message, which is nil if the call failed
+	Message proto.Message
+	Status  *status.Status
+	Header  metadata.MD
+	Trailer metadata.MD
+}
+
+// End runs the test and returns the result of the call
+func (r *Response) End() Result {
+	g := r.grpcTest
+	req := g.request
+	if req.message == nil {
+		req.message = &emptypb.Empty{}
+	}
+	reply, err := replyMessage(req.method, r.message)
+	if err != nil {
+		g.t.Fatal(err)
+		return Result{}
+	}
+
+	var result Result
+	apiTest := apitest.New(g.name).
+		Mocks(g.mocks...).
+		Verifier(g.verifier).
+		HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+			result = g.invoke(httpReq.Context(), reply)
+			writeResult(w, result)
+		})
+	if g.reporter != nil {
+		apiTest.Report(g.reporter)
+	}
+	if g.recorder != nil {
+		apiTest.Recorder(g.recorder)
+	}
+	if g.debugEnabled {
+		apiTest.Debug()
+	}
+
+	apiReq := apiTest.Post(req.method).
+		BodyBytes(protobuf.Marshal(req.message)).
+		ContentType(contentType(req.message))
+	for key, values := range req.metadata {
+		for _, value := range values {
+			apiReq.Header(key, value)
+		}
+	}
+
+	res := apiReq.Expect(g.t)
+	if r.status != nil {
+		expected := *r.status
+		res.Assert(func(*http.Response, *http.Request) error {
+			if code := result.Status.Code(); code != expected {
+				return fmt.Errorf("expected status %s, got %s: %s", expected, code, result.Status.Message())
+			}
+			return nil
+		})
+	}
+	if r.message != nil {
+		res.Assert(protobuf.Equal(r.message))
+	}
+	for _, assert := range r.asserts {
+		assert := assert
+		res.Assert(func(*http.Response, *http.Request) error {
+			return assert(result)
+		})
+	}
+	res.End()
+	return result
+}
+
+// invoke calls the method of the services served over an in-memory listener
+func (g *GRPCTest) invoke(ctx context.Context, reply proto.Message) Result {
+	listener := bufconn.Listen(bufSize)
+	server := grpc.NewServer(g.serverOptions...)
+	for _, register := range g.services {
+		register(server)
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return Result{Status: status.Convert(err)}
+	}
+	defer conn.Close()
+
+	result := Result{Header: metadata.MD{}, Trailer: metadata.MD{}}
+	ctx = metadata.NewOutgoingContext(ctx, g.request.metadata)
+	err = conn.Invoke(ctx, g.request.method, g.request.message, reply, grpc.Header(&result.Header), grpc.Trailer(&result.Trailer))
+	result.Status = status.Convert(err)
+	if err == nil {
+		result.Message = reply
+	}
+	return result
+}
+
+// writeResult writes the result as the http/2 response of the gRPC protocol, where the trailers are written as headers
+func writeResult(w http.ResponseWriter, result Result) {
+	for _, md := range []metadata.MD{result.Header, result.Trailer} {
+		for key, values := range md {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(result.Status.Code())))
+	if message := result.Status.Message(); message != "" {
+		w.Header().Set("Grpc-Message", message)
+	}
+	if result.Message == nil {
+		return
+	}
+	w.Header().Set("Content-Type", contentType(result.Message))
+	_, _ = w.Write(protobuf.Marshal(result.Message))
+}
+
+// replyMessage returns a new response message of the method. The type of the message is looked up in the global
+// registry or is the type of the expected message, if the service descriptor is not registered
+func replyMessage(method string, expected proto.Message) (proto.Message, error) {
+	name := strings.TrimPrefix(method, "/")
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid method %s, expected /<service>/<method>", method)
+	}
+
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name[:i]))
+	if service, ok := descriptor.(protoreflect.ServiceDescriptor); err == nil && ok {
+		if m := service.Methods().ByName(protoreflect.Name(name[i+1:])); m != nil {
+			if messageType, err := protoregistry.GlobalTypes.FindMessageByName(m.Output().FullName()); err == nil {
+				return messageType.New().Interface(), nil
+			}
+		}
+	}
+	if expected != nil {
+		return expected.ProtoReflect().New().Interface(), nil
+	}
+	return nil, fmt.Errorf("unknown response message of %s, register the service descriptor or set the expected message", method)
+}
+
+func contentType(msg proto.Message) string {
+	return mime.FormatMediaType(ContentType, map[string]string{"proto": string(msg.ProtoReflect().Descriptor().FullName())})
+}
@@ -0,0 +1,214 @@
+package grpctest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+	"github.com/steinfletcher/apitest/mocks"
+	"github.com/steinfletcher/apitest/x/grpctest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// usersServer gets users from the http users service at example.com
+type usersServer struct{}
+
+func (usersServer) Get(ctx context.Context, id *wrapperspb.StringValue) (*structpb.Struct, error) {
+	if md, _ := metadata.FromIncomingContext(ctx); len(md.Get("authorization")) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing authorization")
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/users/"+id.GetValue(), nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, status.Errorf(codes.NotFound, "user %s not found", id.GetValue())
+	}
+	var user map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&user); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-user-id", id.GetValue()))
+	return structpb.NewStruct(user)
+}
+
+var usersServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grpctest.test.Users",
+	HandlerType: (*interface {
+		Get(context.Context, *wrapperspb.StringValue) (*structpb.Struct, error)
+	})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Get",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(wrapperspb.StringValue)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			return srv.(usersServer).Get(ctx, in)
+		},
+	}},
+}
+
+func registerUsers(s *grpc.Server) {
+	s.RegisterService(&usersServiceDesc, usersServer{})
+}
+
+var getUserMock = apitest.NewMock().
+	Get("http://example.com/users/1").
+	RespondWith().
+	Body(`{"id": "1", "name": "jan"}`).
+	Status(http.StatusOK).
+	End()
+
+func TestCall(t *testing.T) {
+	result := grpctest.New().
+		Service(registerUsers).
+		Mocks(getUserMock).
+		Call("/grpctest.test.Users/Get").
+		Message(wrapperspb.String("1")).
+		Metadata("authorization", "Bearer token").
+		Expect(t).
+		Status(codes.OK).
+		Message(newUser(t, map[string]interface{}{"id": "1", "name": "jan"})).
+		Assert(func(result grpctest.Result) error {
+			assert.Equal(t, []string{"1"}, result.Header.Get("x-user-id"))
+			return nil
+		}).
+		End()
+
+	assert.Equal(t, codes.OK, result.Status.Code())
+	assert.Equal(t, "jan", result.Message.(*structpb.Struct).Fields["name"].GetStringValue())
+}
+
+func TestCall_Status(t *testing.T) {
+	grpctest.New().
+		Service(registerUsers).
+		Mocks(apitest.NewMock().Get("http://example.com/users/2").RespondWith().Status(http.StatusNotFound).End()).
+		Call("/grpctest.test.Users/Get").
+		Message(wrapperspb.String("2")).
+		Metadata("authorization", "Bearer token").
+		Expect(t).
+		Status(codes.NotFound).
+		Assert(func(result grpctest.Result) error {
+			assert.Equal(t, "user 2 not found", result.Status.Message())
+			assert.Nil(t, result.Message)
+			return nil
+		}).
+		End()
+}
+
+func TestCall_ReportsFailures(t *testing.T) {
+	var failures []string
+	verifier := mocks.NewVerifier()
+	verifier.NoErrorFn = func(t apitest.TestingT, err error, msgAndArgs ...interface{}) bool {
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+		return true
+	}
+
+	grpctest.New().
+		Service(registerUsers).
+		Verifier(verifier).
+		Call("/grpctest.test.Users/Get").
+		Message(wrapperspb.String("1")).
+		Expect(t).
+		Status(codes.OK).
+		End()
+
+	assert.Equal(t, []string{"expected status OK, got Unauthenticated: missing authorization"}, failures)
+}
+
+func TestCall_UnregisteredService(t *testing.T) {
+	accountsServiceDesc := usersServiceDesc
+	accountsServiceDesc.ServiceName = "grpctest.test.Accounts"
+
+	result := grpctest.New().
+		Service(func(s *grpc.Server) { s.RegisterService(&accountsServiceDesc, usersServer{}) }).
+		Mocks(getUserMock).
+		Call("/grpctest.test.Accounts/Get").
+		Message(wrapperspb.String("1")).
+		Metadata("authorization", "Bearer token").
+		Expect(t).
+		Message(newUser(t, map[string]interface{}{"id": "1", "name": "jan"})).
+		End()
+
+	assert.IsType(t, &structpb.Struct{}, result.Message)
+}
+
+func TestCall_Report(t *testing.T) {
+	var events []apitest.Event
+
+	grpctest.New("get user").
+		Service(registerUsers).
+		Mocks(getUserMock).
+		Report(reporterFunc(func(recorder *apitest.Recorder) {
+			events = append(events, recorder.Events...)
+		})).
+		Call("/grpctest.test.Users/Get").
+		Message(wrapperspb.String("1")).
+		Metadata("authorization", "Bearer token").
+		Expect(t).
+		Status(codes.OK).
+		End()
+
+	assert.Len(t, events, 4)
+	call := events[0].(apitest.HttpRequest)
+	assert.Equal(t, "/grpctest.test.Users/Get", call.Value.URL.Path)
+	assert.Equal(t, "application/grpc+proto; proto=google.protobuf.StringValue", call.Value.Header.Get("Content-Type"))
+	mock := events[1].(apitest.HttpRequest)
+	assert.Equal(t, "http://example.com/users/1", mock.Value.URL.String())
+	res := events[3].(apitest.HttpResponse)
+	assert.Equal(t, "0", res.Value.Header.Get("Grpc-Status"))
+	assert.Equal(t, "application/grpc+proto; proto=google.protobuf.Struct", res.Value.Header.Get("Content-Type"))
+}
+
+type reporterFunc func(*apitest.Recorder)
+
+func (f reporterFunc) Format(recorder *apitest.Recorder) { f(recorder) }
+
+// init registers the descriptor of the users service, as generated code does
+func init() {
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("grpctest/test/users.proto"),
+		Package:    proto.String("grpctest.test"),
+		Dependency: []string{"google/protobuf/wrappers.proto", "google/protobuf/struct.proto"},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Users"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Get"),
+				InputType:  proto.String(".google.protobuf.StringValue"),
+				OutputType: proto.String(".google.protobuf.Struct"),
+			}},
+		}},
+		Syntax: proto.String("proto3"),
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(err)
+	}
+}
+
+func newUser(t *testing.T, fields map[string]interface{}) proto.Message {
+	user, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -0,0 +1,17 @@
+module github.com/steinfletcher/apitest/x/protobuf
+
+go 1.23
+
+replace github.com/steinfletcher/apitest => ../../
+
+require (
+	github.com/pmezard/go-difflib v1.0.0
+	github.com/steinfletcher/apitest v0.0.0-20261017031324-6db76a159d8f
+	github.com/stretchr/testify v1.7.0
+	google.golang.org/protobuf v1.36.6
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
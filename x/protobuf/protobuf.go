@@ -0,0 +1,390 @@
+// Package protobuf adds support for Protocol Buffers payloads sent over HTTP, including gRPC-web. It is a separate
+// module so that apitest does not depend on the protobuf runtime. The builders of apitest are wrapped to add protobuf
+// bodies, e.g.
+//
+//	protobuf.Request(apitest.New().Handler(handler).Post("/users")).
+//		Protobuf(&pb.CreateUserRequest{Name: "jan"}).
+//		Expect(t).
+//		Status(http.StatusCreated)
+//
+// Importing the package registers Format with apitest.RegisterBodyFormatter, which decodes protobuf bodies in reports.
+package protobuf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/steinfletcher/apitest"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// ContentType is the media type of the binary encoding of protobuf messages
+const ContentType = "application/x-protobuf"
+
+// MessageHeader is the header that names the type of the message of a protobuf body, if the content type does not
+const MessageHeader = "X-Protobuf-Message"
+
+var (
+	registeredMu sync.RWMutex
+	registered   []protoreflect.MessageType
+)
+
+func init() {
+	apitest.RegisterBodyFormatter(Format)
+}
+
+// Request wraps the request builder to add a protobuf body
+func Request(r *apitest.Request) *RequestBuilder {
+	return &RequestBuilder{r}
+}
+
+// RequestBuilder is a request builder with protobuf bodies
+type RequestBuilder struct {
+	*apitest.Request
+}
+
+// Protobuf sets the request body to the binary encoding of the message and the content type to ContentTypeOf(msg)
+func (r *RequestBuilder) Protobuf(msg proto.Message) *apitest.Request {
+	return r.BodyBytes(Marshal(msg)).ContentType(ContentTypeOf(msg))
+}
+
+// Response wraps the response builder to assert protobuf bodies
+func Response(r *apitest.Response) *ResponseBuilder {
+	return &ResponseBuilder{r}
+}
+
+// ResponseBuilder is a response builder with protobuf bodies
+type ResponseBuilder struct {
+	*apitest.Response
+}
+
+// Protobuf asserts that the response body is equal to the expected message, see Equal
+func (r *ResponseBuilder) Protobuf(expected proto.Message) *apitest.Response {
+	return r.Assert(Equal(expected))
+}
+
+// MockRequest wraps the mock request builder to match protobuf bodies
+func MockRequest(r *apitest.MockRequest) *MockRequestBuilder {
+	return &MockRequestBuilder{r}
+}
+
+// MockRequestBuilder is a mock request builder with protobuf bodies
+type MockRequestBuilder struct {
+	*apitest.MockRequest
+}
+
+// Protobuf matches requests with a body that is equal to the expected message, see Matcher
+func (r *MockRequestBuilder) Protobuf(expected proto.Message) *apitest.MockRequest {
+	return r.AddMatcher(Matcher(expected))
+}
+
+// MockResponse wraps the mock response builder to respond with protobuf bodies
+func MockResponse(r *apitest.MockResponse) *MockResponseBuilder {
+	return &MockResponseBuilder{r}
+}
+
+// MockResponseBuilder is a mock response builder with protobuf bodies
+type MockResponseBuilder struct {
+	*apitest.MockResponse
+}
+
+// Protobuf sets the response body to the binary encoding of the message and the content type to ContentTypeOf(msg)
+func (r *MockResponseBuilder) Protobuf(msg proto.Message) *apitest.MockResponse {
+	return r.Body(string(Marshal(msg))).Header("Content-Type", ContentTypeOf(msg))
+}
+
+// Register registers message types that are used to decode protobuf bodies in reports if the type of the message is
+// not named by the content type or by MessageHeader. The body is decoded as the first registered type without unknown
+// fields
+func Register(msgs ...proto.Message) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	for _, msg := range msgs {
+		registered = append(registered, msg.ProtoReflect().Type())
+	}
+}
+
+// Marshal returns the binary encoding of the message, e.g. BodyBytes(protobuf.Marshal(msg)). It panics if the message
+// cannot be encoded
+func Marshal(msg proto.Message) []byte {
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// ContentTypeOf returns the content type of the binary encoding of the message, which names the type of the message in
+// the proto parameter, e.g. application/x-protobuf; proto=acme.User
+func ContentTypeOf(msg proto.Message) string {
+	return mime.FormatMediaType(ContentType, map[string]string{"proto": string(msg.ProtoReflect().Descriptor().FullName())})
+}
+
+// Equal asserts that the response body is the binary encoding of a message equal to the expected message, e.g.
+// Assert(protobuf.Equal(expected)). Messages are compared semantically using proto.Equal and a difference is reported
+// as a diff of the messages formatted as protojson
+func Equal(expected proto.Message) apitest.Assert {
+	return func(res *http.Response, _ *http.Request) error {
+		body, err := readBody(&res.Body)
+		if err != nil {
+			return err
+		}
+		body, err = messageBody(res.Header, body)
+		if err != nil {
+			return fmt.Errorf("response body is not a valid %s message: %s", name(expected), err)
+		}
+		actual, err := unmarshal(expected, body)
+		if err != nil {
+			return fmt.Errorf("response body is not a valid %s message: %s", name(expected), err)
+		}
+		if !proto.Equal(expected, actual) {
+			return fmt.Errorf("response body does not match expected protobuf message%s", diff(expected, actual))
+		}
+		return nil
+	}
+}
+
+// Matcher matches mock requests with a body that is the binary encoding of a message equal to the expected message,
+// e.g. AddMatcher(protobuf.Matcher(expected))
+func Matcher(expected proto.Message) apitest.Matcher {
+	return func(req *http.Request, _ *apitest.MockRequest) error {
+		body, err := readBody(&req.Body)
+		if err != nil {
+			return err
+		}
+		body, err = messageBody(req.Header, body)
+		if err != nil {
+			return fmt.Errorf("received body is not a valid %s message: %s", name(expected), err)
+		}
+		actual, err := unmarshal(expected, body)
+		if err != nil {
+			return fmt.Errorf("received body is not a valid %s message: %s", name(expected), err)
+		}
+		if !proto.Equal(expected, actual) {
+			return fmt.Errorf("received body did not match expected mock protobuf message%s", diff(expected, actual))
+		}
+		return nil
+	}
+}
+
+// Format formats protobuf bodies as protojson, including gRPC-web bodies and the gRPC messages recorded by the grpctest
+// module. The type of the message is looked up in the global registry by the proto or messageType parameter of the
+// content type, see ContentTypeOf, or by MessageHeader. Otherwise the types added with Register are tried. ok is false
+// for other bodies and for unknown message types
+func Format(header http.Header, body []byte) (formatted string, ok bool) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return "", false
+	}
+	switch mediaType {
+	case ContentType, "application/protobuf", "application/vnd.google.protobuf":
+		msg, ok := decode(header, params, body)
+		if !ok {
+			return "", false
+		}
+		formatted, err = formatJSON(msg)
+		return formatted, err == nil
+	}
+	if !isGRPC(mediaType) {
+		return "", false
+	}
+
+	messages, trailer, err := grpcFrames(mediaType, body)
+	if err != nil {
+		return "", false
+	}
+	var out []string
+	for _, message := range messages {
+		msg, ok := decode(header, params, message)
+		if !ok {
+			return "", false
+		}
+		formatted, err := formatJSON(msg)
+		if err != nil {
+			return "", false
+		}
+		out = append(out, formatted)
+	}
+	if len(trailer) > 0 {
+		out = append(out, strings.TrimSpace(string(trailer)))
+	}
+	return strings.Join(out, "\n\n"), true
+}
+
+// decode unmarshals the body as the message type named by the content type or by MessageHeader, or as a registered type
+func decode(header http.Header, params map[string]string, body []byte) (proto.Message, bool) {
+	for _, name := range []string{params["proto"], params["messagetype"], header.Get(MessageHeader)} {
+		if name == "" {
+			continue
+		}
+		messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(name))
+		if err != nil {
+			return nil, false
+		}
+		msg := messageType.New().Interface()
+		if err := proto.Unmarshal(body, msg); err != nil {
+			return nil, false
+		}
+		return msg, true
+	}
+
+	registeredMu.RLock()
+	defer registeredMu.RUnlock()
+	for _, messageType := range registered {
+		msg := messageType.New().Interface()
+		if err := proto.Unmarshal(body, msg); err == nil && len(msg.ProtoReflect().GetUnknown()) == 0 {
+			return msg, true
+		}
+	}
+	return nil, false
+}
+
+func isGRPC(mediaType string) bool {
+	switch mediaType {
+	case "application/grpc", "application/grpc+proto",
+		"application/grpc-web", "application/grpc-web+proto",
+		"application/grpc-web-text", "application/grpc-web-text+proto":
+		return true
+	}
+	return false
+}
+
+// messageBody returns the message of a gRPC body without its frame, or the body of other content types
+func messageBody(header http.Header, body []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || !isGRPC(mediaType) {
+		return body, nil
+	}
+	messages, _, err := grpcFrames(mediaType, body)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) != 1 {
+		return nil, fmt.Errorf("expected a single gRPC message, got %d", len(messages))
+	}
+	return messages[0], nil
+}
+
+// grpcFrames splits a gRPC body into its messages and the trailer of gRPC-web responses. Each frame is prefixed by a flag
+// byte and the length of the frame, see https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md. gRPC-web bodies
+// are always framed. Bodies of the other gRPC media types, such as the bodies recorded by the grpctest module, are a
+// single message unless the whole body is a sequence of frames
+func grpcFrames(mediaType string, body []byte) (messages [][]byte, trailer []byte, err error) {
+	web := strings.HasPrefix(mediaType, "application/grpc-web")
+	if strings.HasPrefix(mediaType, "application/grpc-web-text") {
+		if body, err = base64.StdEncoding.DecodeString(string(body)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	frames, ok := splitGRPCFrames(body)
+	if !web && (!ok || len(frames) == 0) {
+		return [][]byte{body}, nil, nil
+	}
+	if !ok {
+		return nil, nil, errors.New("invalid gRPC-web frame")
+	}
+	for _, frame := range frames {
+		switch {
+		case frame.flags&0x80 != 0:
+			trailer = frame.data
+		case frame.flags&0x01 != 0:
+			return nil, nil, errors.New("compressed gRPC messages are not supported")
+		default:
+			messages = append(messages, frame.data)
+		}
+	}
+	return messages, trailer, nil
+}
+
+type grpcFrame struct {
+	flags byte
+	data  []byte
+}
+
+// splitGRPCFrames splits the body into length-prefixed frames. ok is false if the body is not a sequence of frames
+func splitGRPCFrames(body []byte) (frames []grpcFrame, ok bool) {
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, false
+		}
+		flags, length := body[0], binary.BigEndian.Uint32(body[1:5])
+		if flags&^0x81 != 0 || uint64(len(body)-5) < uint64(length) {
+			return nil, false
+		}
+		frames = append(frames, grpcFrame{flags: flags, data: body[5 : 5+length]})
+		body = body[5+length:]
+	}
+	return frames, true
+}
+
+func unmarshal(expected proto.Message, body []byte) (proto.Message, error) {
+	actual := expected.ProtoReflect().New().Interface()
+	if err := proto.Unmarshal(body, actual); err != nil {
+		return nil, err
+	}
+	return actual, nil
+}
+
+func name(msg proto.Message) protoreflect.FullName {
+	return msg.ProtoReflect().Descriptor().FullName()
+}
+
+// formatJSON formats the message as indented protojson. The output of protojson is deliberately unstable, so it is
+// indented again to get a stable format
+func formatJSON(msg proto.Message) (string, error) {
+	b, err := protojson.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, b, "", "    "); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func diff(expected, actual proto.Message) string {
+	e, err := formatJSON(expected)
+	if err != nil {
+		return ""
+	}
+	a, err := formatJSON(actual)
+	if err != nil {
+		return ""
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(e + "\n"),
+		B:        difflib.SplitLines(a + "\n"),
+		FromFile: "Expected",
+		ToFile:   "Actual",
+		Context:  1,
+	})
+	return "\n\nDiff:\n" + diff
+}
+
+// readBody reads the body and replaces it so that it can be read again
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
@@ -0,0 +1,323 @@
+package protobuf_test
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+	"github.com/steinfletcher/apitest/mocks"
+	"github.com/steinfletcher/apitest/x/protobuf"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestContentTypeOf(t *testing.T) {
+	assert.Equal(t, "application/x-protobuf; proto=google.protobuf.StringValue", protobuf.ContentTypeOf(wrapperspb.String("jan")))
+}
+
+func TestEqual(t *testing.T) {
+	user := newUser(t, map[string]interface{}{"id": 1, "name": "jan"})
+
+	apitest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", protobuf.ContentTypeOf(user))
+			_, _ = w.Write(protobuf.Marshal(user))
+		}).
+		Get("/user").
+		Expect(t).
+		Assert(protobuf.Equal(newUser(t, map[string]interface{}{"name": "jan", "id": 1}))).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestEqual_ReportsDiff(t *testing.T) {
+	var failures []string
+	verifier := mocks.NewVerifier()
+	verifier.NoErrorFn = func(t apitest.TestingT, err error, msgAndArgs ...interface{}) bool {
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+		return true
+	}
+
+	apitest.New().
+		Verifier(verifier).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(protobuf.Marshal(newUser(t, map[string]interface{}{"id": 1, "name": "kim"})))
+		}).
+		Get("/user").
+		Expect(t).
+		Assert(protobuf.Equal(newUser(t, map[string]interface{}{"id": 1, "name": "jan"}))).
+		End()
+
+	assert.Equal(t, []string{"response body does not match expected protobuf message\n\nDiff:\n" +
+		"--- Expected\n+++ Actual\n@@ -2,3 +2,3 @@\n     \"id\": 1,\n-    \"name\": \"jan\"\n+    \"name\": \"kim\"\n }\n"}, failures)
+}
+
+func TestMatcher(t *testing.T) {
+	user := newUser(t, map[string]interface{}{"name": "jan"})
+
+	tests := map[string]struct {
+		body          []byte
+		expectedError string
+	}{
+		"matches": {
+			body: protobuf.Marshal(user),
+		},
+		"does not match": {
+			body:          protobuf.Marshal(newUser(t, map[string]interface{}{"name": "kim"})),
+			expectedError: "received body did not match expected mock protobuf message\n\nDiff:\n",
+		},
+		"not a message": {
+			body:          []byte{0xff},
+			expectedError: "received body is not a valid google.protobuf.Struct message: ",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "/user", strings.NewReader(string(test.body)))
+
+			err := protobuf.Matcher(user)(req, nil)
+
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.True(t, strings.HasPrefix(err.Error(), test.expectedError), err.Error())
+		})
+	}
+}
+
+func TestMocks(t *testing.T) {
+	request := wrapperspb.String("jan")
+	response := newUser(t, map[string]interface{}{"id": 1, "name": "jan"})
+	mock := apitest.NewMock().
+		Post("http://example.com/users").
+		AddMatcher(protobuf.Matcher(request)).
+		RespondWith().
+		Body(string(protobuf.Marshal(response))).
+		Header("Content-Type", protobuf.ContentTypeOf(response)).
+		End()
+
+	apitest.New().
+		Mocks(mock).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := http.Post("http://example.com/users", protobuf.ContentTypeOf(request), strings.NewReader(string(protobuf.Marshal(request))))
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", res.Header.Get("Content-Type"))
+			w.WriteHeader(http.StatusCreated)
+			body := make([]byte, 0)
+			buf := make([]byte, 512)
+			for {
+				n, err := res.Body.Read(buf)
+				body = append(body, buf[:n]...)
+				if err != nil {
+					break
+				}
+			}
+			_, _ = w.Write(body)
+		}).
+		Post("/users").
+		BodyBytes(protobuf.Marshal(request)).
+		ContentType(protobuf.ContentTypeOf(request)).
+		Expect(t).
+		Assert(protobuf.Equal(response)).
+		Status(http.StatusCreated).
+		End()
+}
+
+func TestBuilders(t *testing.T) {
+	request := wrapperspb.String("jan")
+	response := newUser(t, map[string]interface{}{"id": 1, "name": "jan"})
+	mock := protobuf.MockResponse(protobuf.MockRequest(apitest.NewMock().
+		Post("http://example.com/users")).
+		Protobuf(request).
+		RespondWith()).
+		Protobuf(response).
+		Status(http.StatusCreated).
+		End()
+
+	res := protobuf.Request(apitest.New().
+		Mocks(mock).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Content-Type") != protobuf.ContentTypeOf(request) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			res, err := http.Post("http://example.com/users", r.Header.Get("Content-Type"), r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			body, _ := io.ReadAll(res.Body)
+			w.Header().Set("Content-Type", res.Header.Get("Content-Type"))
+			w.WriteHeader(res.StatusCode)
+			_, _ = w.Write(body)
+		}).
+		Post("/users")).
+		Protobuf(request).
+		Expect(t)
+
+	protobuf.Response(res).
+		Protobuf(response).
+		Header("Content-Type", protobuf.ContentTypeOf(response)).
+		Status(http.StatusCreated).
+		End()
+}
+
+func TestEqual_GRPCWeb(t *testing.T) {
+	user := newUser(t, map[string]interface{}{"name": "jan"})
+
+	apitest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/grpc-web+proto")
+			_, _ = w.Write(grpcWebBody(protobuf.Marshal(user), "grpc-status: 0\r\n"))
+		}).
+		Post("/acme.Users/GetUser").
+		Expect(t).
+		Assert(protobuf.Equal(user)).
+		End()
+}
+
+func TestEqual_UnframedGRPCMessageWithHighFieldNumber(t *testing.T) {
+	// field 16 with the varint wire type is encoded as 80 01, which starts like a gRPC-web trailer frame
+	options := &descriptorpb.FileOptions{CcGenericServices: proto.Bool(true)}
+	body := protobuf.Marshal(options)
+	header := http.Header{"Content-Type": []string{"application/grpc+proto; proto=google.protobuf.FileOptions"}}
+
+	apitest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", header.Get("Content-Type"))
+			_, _ = w.Write(body)
+		}).
+		Post("/acme.Files/GetOptions").
+		Expect(t).
+		Assert(protobuf.Equal(options)).
+		End()
+
+	req, _ := http.NewRequest(http.MethodPost, "/acme.Files/SetOptions", strings.NewReader(string(body)))
+	req.Header = header
+	assert.NoError(t, protobuf.Matcher(options)(req, nil))
+
+	formatted, ok := protobuf.Format(header, body)
+	assert.True(t, ok)
+	assert.Equal(t, "{\n    \"ccGenericServices\": true\n}", formatted)
+}
+
+func TestFormat(t *testing.T) {
+	user := newUser(t, map[string]interface{}{"name": "jan"})
+	header := http.Header{"Content-Type": []string{protobuf.ContentTypeOf(user)}}
+
+	formatted, ok := protobuf.Format(header, protobuf.Marshal(user))
+
+	assert.True(t, ok)
+	assert.Equal(t, "{\n    \"name\": \"jan\"\n}", formatted)
+}
+
+func TestFormat_GRPC(t *testing.T) {
+	header := http.Header{"Content-Type": []string{"application/grpc+proto; proto=google.protobuf.StringValue"}}
+
+	formatted, ok := protobuf.Format(header, protobuf.Marshal(wrapperspb.String("jan")))
+
+	assert.True(t, ok)
+	assert.Equal(t, `"jan"`, formatted)
+}
+
+func TestFormat_GRPCWeb(t *testing.T) {
+	body := grpcWebBody(protobuf.Marshal(wrapperspb.String("jan")), "grpc-status: 0\r\ngrpc-message: \r\n")
+
+	tests := map[string]struct {
+		contentType string
+		body        []byte
+	}{
+		"binary": {contentType: "application/grpc-web+proto; proto=google.protobuf.StringValue", body: body},
+		"text":   {contentType: "application/grpc-web-text; proto=google.protobuf.StringValue", body: []byte(base64.StdEncoding.EncodeToString(body))},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			formatted, ok := protobuf.Format(http.Header{"Content-Type": []string{test.contentType}}, test.body)
+
+			assert.True(t, ok)
+			assert.Equal(t, "\"jan\"\n\ngrpc-status: 0\r\ngrpc-message:", formatted)
+		})
+	}
+}
+
+func TestFormat_MessageType(t *testing.T) {
+	body := protobuf.Marshal(wrapperspb.String("jan"))
+
+	tests := map[string]http.Header{
+		"message type parameter": {"Content-Type": []string{protobuf.ContentType + "; messageType=google.protobuf.StringValue"}},
+		"message header": {
+			"Content-Type":         []string{protobuf.ContentType},
+			protobuf.MessageHeader: []string{"google.protobuf.StringValue"},
+		},
+	}
+	for name, header := range tests {
+		t.Run(name, func(t *testing.T) {
+			formatted, ok := protobuf.Format(header, body)
+
+			assert.True(t, ok)
+			assert.Equal(t, `"jan"`, formatted)
+		})
+	}
+}
+
+func TestFormat_RegisteredMessages(t *testing.T) {
+	protobuf.Register(&wrapperspb.Int64Value{})
+	header := http.Header{"Content-Type": []string{protobuf.ContentType}}
+
+	formatted, ok := protobuf.Format(header, protobuf.Marshal(wrapperspb.Int64(42)))
+	assert.True(t, ok)
+	assert.Equal(t, `"42"`, formatted)
+
+	_, ok = protobuf.Format(header, protobuf.Marshal(wrapperspb.String("jan")))
+	assert.False(t, ok)
+}
+
+func TestFormat_IgnoresUnknownMessages(t *testing.T) {
+	tests := map[string]string{
+		"other content type": "application/json",
+		"no message type":    protobuf.ContentType,
+		"unknown message":    protobuf.ContentType + "; proto=acme.Unknown",
+	}
+	for name, contentType := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, ok := protobuf.Format(http.Header{"Content-Type": []string{contentType}}, protobuf.Marshal(wrapperspb.String("jan")))
+
+			assert.False(t, ok)
+		})
+	}
+}
+
+func grpcWebBody(message []byte, trailer string) []byte {
+	var body []byte
+	for i, frame := range [][]byte{message, []byte(trailer)} {
+		prefix := make([]byte, 5)
+		if i == 1 {
+			prefix[0] = 0x80
+		}
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(frame)))
+		body = append(append(body, prefix...), frame...)
+	}
+	return body
+}
+
+func newUser(t *testing.T, fields map[string]interface{}) proto.Message {
+	user, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
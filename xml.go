@@ -0,0 +1,258 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type xmlNodeKind int
+
+const (
+	xmlDocumentNode xmlNodeKind = iota
+	xmlElementNode
+	xmlAttrNode
+	xmlTextNode
+	xmlCommentNode
+	xmlProcInstNode
+)
+
+// xmlNode is a node of a parsed XML document. Text nodes that only contain whitespace are dropped
+type xmlNode struct {
+	kind     xmlNodeKind
+	name     xml.Name
+	value    string
+	attrs    []*xmlNode
+	children []*xmlNode
+	parent   *xmlNode
+}
+
+// parseXML parses the XML document. Names are resolved to their namespace URI, e.g. {http://example.com/ns}user,
+// unless raw is set, in which case the prefixes, comments and processing instructions of the document are kept
+func parseXML(body []byte, raw bool) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	doc := &xmlNode{kind: xmlDocumentNode}
+	current := doc
+	for {
+		var token xml.Token
+		var err error
+		if raw {
+			token, err = decoder.RawToken()
+		} else {
+			token, err = decoder.Token()
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if current == doc && doc.root() != nil {
+				return nil, fmt.Errorf("unexpected element <%s> after the root element", t.Name.Local)
+			}
+			element := &xmlNode{kind: xmlElementNode, name: t.Name, parent: current}
+			for _, attr := range t.Attr {
+				if !raw && isNamespaceDeclaration(attr.Name) {
+					continue
+				}
+				element.attrs = append(element.attrs, &xmlNode{kind: xmlAttrNode, name: attr.Name, value: attr.Value, parent: element})
+			}
+			current.children = append(current.children, element)
+			current = element
+		case xml.EndElement:
+			if current == doc || current.name != t.Name {
+				return nil, fmt.Errorf("unexpected end element </%s>", t.Name.Local)
+			}
+			current = current.parent
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) == 0 {
+				continue
+			}
+			if current == doc {
+				return nil, errors.New("unexpected text outside of an element")
+			}
+			if last := current.lastChild(); last != nil && last.kind == xmlTextNode {
+				last.value += string(t)
+				continue
+			}
+			current.children = append(current.children, &xmlNode{kind: xmlTextNode, value: string(t), parent: current})
+		case xml.Comment:
+			if raw {
+				current.children = append(current.children, &xmlNode{kind: xmlCommentNode, value: string(t), parent: current})
+			}
+		case xml.ProcInst:
+			if raw {
+				current.children = append(current.children, &xmlNode{kind: xmlProcInstNode, name: xml.Name{Local: t.Target}, value: string(t.Inst), parent: current})
+			}
+		}
+	}
+	if current != doc {
+		return nil, fmt.Errorf("element <%s> is not closed", current.name.Local)
+	}
+	if doc.root() == nil {
+		return nil, errors.New("no root element")
+	}
+	return doc, nil
+}
+
+func isNamespaceDeclaration(name xml.Name) bool {
+	return name.Space == "xmlns" || (name.Space == "" && name.Local == "xmlns")
+}
+
+func (n *xmlNode) root() *xmlNode {
+	for _, child := range n.children {
+		if child.kind == xmlElementNode {
+			return child
+		}
+	}
+	return nil
+}
+
+func (n *xmlNode) lastChild() *xmlNode {
+	if len(n.children) == 0 {
+		return nil
+	}
+	return n.children[len(n.children)-1]
+}
+
+// text returns the string value of the node, i.e. the concatenated text of an element and its descendants
+func (n *xmlNode) text() string {
+	switch n.kind {
+	case xmlDocumentNode, xmlElementNode:
+		var out strings.Builder
+		for _, child := range n.children {
+			if child.kind == xmlTextNode || child.kind == xmlElementNode {
+				out.WriteString(child.text())
+			}
+		}
+		return out.String()
+	default:
+		return n.value
+	}
+}
+
+// canonicalXML returns a form of the document that ignores whitespace, the order of attributes and namespace
+// prefixes, so that equivalent documents have the same canonical form
+func canonicalXML(body []byte) (string, error) {
+	doc, err := parseXML(body, false)
+	if err != nil {
+		return "", err
+	}
+	return doc.canonical(), nil
+}
+
+func (n *xmlNode) canonical() string {
+	var out bytes.Buffer
+	n.root().write(&out, 0, true)
+	return out.String()
+}
+
+// indentXML pretty prints the XML document, keeping its namespace prefixes, comments and processing instructions
+func indentXML(body []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("<")) {
+		return "", errors.New("body is not XML")
+	}
+	doc, err := parseXML(body, true)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	for _, child := range doc.children {
+		child.write(&out, 0, false)
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
+
+// write writes the node and its descendants indented by depth. The canonical form uses the namespace URI of elements
+// instead of their prefixes and sorts the attributes
+func (n *xmlNode) write(out *bytes.Buffer, depth int, canonical bool) {
+	out.WriteString(strings.Repeat("    ", depth))
+	switch n.kind {
+	case xmlTextNode:
+		_ = xml.EscapeText(out, []byte(strings.TrimSpace(n.value)))
+		out.WriteString("\n")
+		return
+	case xmlCommentNode:
+		fmt.Fprintf(out, "<!--%s-->\n", n.value)
+		return
+	case xmlProcInstNode:
+		fmt.Fprintf(out, "<?%s %s?>\n", n.name.Local, n.value)
+		return
+	}
+
+	name := n.qualifiedName(canonical)
+	out.WriteString("<" + name)
+	if canonical && n.name.Space != n.parent.name.Space {
+		out.WriteString(` xmlns="`)
+		_ = xml.EscapeText(out, []byte(n.name.Space))
+		out.WriteString(`"`)
+	}
+	attrs := n.attrs
+	if canonical {
+		attrs = append([]*xmlNode(nil), attrs...)
+		sort.Slice(attrs, func(i, j int) bool {
+			if attrs[i].name.Space != attrs[j].name.Space {
+				return attrs[i].name.Space < attrs[j].name.Space
+			}
+			return attrs[i].name.Local < attrs[j].name.Local
+		})
+	}
+	for _, attr := range attrs {
+		out.WriteString(" " + attr.qualifiedName(canonical) + `="`)
+		_ = xml.EscapeText(out, []byte(attr.value))
+		out.WriteString(`"`)
+	}
+
+	switch {
+	case len(n.children) == 0:
+		out.WriteString("/>\n")
+	case len(n.children) == 1 && n.children[0].kind == xmlTextNode:
+		out.WriteString(">")
+		_ = xml.EscapeText(out, []byte(strings.TrimSpace(n.children[0].value)))
+		out.WriteString("</" + name + ">\n")
+	default:
+		out.WriteString(">\n")
+		for _, child := range n.children {
+			child.write(out, depth+1, canonical)
+		}
+		out.WriteString(strings.Repeat("    ", depth) + "</" + name + ">\n")
+	}
+}
+
+// qualifiedName returns the name as written in the document, e.g. ns:user. In the canonical form the namespace URI of
+// attributes is written in braces, e.g. {http://example.com/ns}id, while the namespace of elements is declared
+func (n *xmlNode) qualifiedName(canonical bool) string {
+	switch {
+	case n.name.Space == "":
+		return n.name.Local
+	case !canonical:
+		return n.name.Space + ":" + n.name.Local
+	case n.kind == xmlAttrNode:
+		return "{" + n.name.Space + "}" + n.name.Local
+	default:
+		return n.name.Local
+	}
+}
+
+// marshalXML returns the XML body of v, which is either a string, a byte slice or a value marshalled by encoding/xml
+func marshalXML(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case []byte:
+		return string(x), nil
+	default:
+		asXML, err := xml.Marshal(x)
+		if err != nil {
+			return "", err
+		}
+		return string(asXML), nil
+	}
+}
@@ -0,0 +1,138 @@
+package apitest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const xmlUsers = `<?xml version="1.0" encoding="UTF-8"?>
+<u:users xmlns:u="http://example.com/users">
+	<u:user id="1" role="admin">
+		<u:name>jan</u:name>
+		<u:age>32</u:age>
+	</u:user>
+	<u:user id="2">
+		<u:name>kim</u:name>
+		<u:age>28.0</u:age>
+	</u:user>
+	<group><u:user id="3"><u:name>lee</u:name></u:user></group>
+</u:users>`
+
+func TestXML_Canonical(t *testing.T) {
+	tests := map[string]struct {
+		expected string
+		actual   string
+		equal    bool
+	}{
+		"whitespace": {
+			expected: "<user><name>jan</name></user>",
+			actual:   "<user>\n  <name> jan </name>\n</user>\n",
+			equal:    true,
+		},
+		"attribute order": {
+			expected: `<user id="1" role="admin"/>`,
+			actual:   `<user role="admin" id="1"></user>`,
+			equal:    true,
+		},
+		"namespace prefix": {
+			expected: `<user xmlns="http://example.com/ns"><name>jan</name></user>`,
+			actual:   `<ns:user xmlns:ns="http://example.com/ns"><ns:name>jan</ns:name></ns:user>`,
+			equal:    true,
+		},
+		"different namespace": {
+			expected: `<user xmlns="http://example.com/ns"/>`,
+			actual:   `<user xmlns="http://example.com/other"/>`,
+		},
+		"different attribute value": {
+			expected: `<user id="1"/>`,
+			actual:   `<user id="2"/>`,
+		},
+		"element order": {
+			expected: "<user><name>jan</name><age>32</age></user>",
+			actual:   "<user><age>32</age><name>jan</name></user>",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expected, err := canonicalXML([]byte(test.expected))
+			assert.NoError(t, err)
+			actual, err := canonicalXML([]byte(test.actual))
+			assert.NoError(t, err)
+			assert.Equal(t, test.equal, expected == actual, "%s\n%s", expected, actual)
+		})
+	}
+}
+
+func TestXML_CanonicalInvalid(t *testing.T) {
+	for _, body := range []string{"", "hello", "<user>", "<a/><b/>", "<a></b>", "<a/>text"} {
+		_, err := canonicalXML([]byte(body))
+		assert.Error(t, err, body)
+	}
+}
+
+func TestXML_Indent(t *testing.T) {
+	indented, err := indentXML([]byte(`<?xml version="1.0"?><ns:user xmlns:ns="http://example.com/ns" id="1"><!-- comment --><ns:name>jan &amp; kim</ns:name><ns:tags/></ns:user>`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0"?>
+<ns:user xmlns:ns="http://example.com/ns" id="1">
+    <!-- comment -->
+    <ns:name>jan &amp; kim</ns:name>
+    <ns:tags/>
+</ns:user>`, indented)
+}
+
+func TestXML_IndentNotXML(t *testing.T) {
+	_, err := indentXML([]byte("hello <b>world</b>"))
+
+	assert.Error(t, err)
+}
+
+func TestXPath_Evaluate(t *testing.T) {
+	doc, err := parseXML([]byte(xmlUsers), false)
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		expr     string
+		expected []string
+	}{
+		"absolute path":        {expr: "/users/user/name", expected: []string{"jan", "kim"}},
+		"relative path":        {expr: "users/user/name", expected: []string{"jan", "kim"}},
+		"prefixed names":       {expr: "/u:users/u:user/u:name", expected: []string{"jan", "kim"}},
+		"descendants":          {expr: "//user/name", expected: []string{"jan", "kim", "lee"}},
+		"wildcard":             {expr: "/users/*/user/name", expected: []string{"lee"}},
+		"attribute":            {expr: "/users/user/@id", expected: []string{"1", "2"}},
+		"all attributes":       {expr: "//user[1]/@*", expected: []string{"1", "admin", "3"}},
+		"text":                 {expr: "//user[2]/name/text()", expected: []string{"kim"}},
+		"position":             {expr: "/users/user[2]/name", expected: []string{"kim"}},
+		"last":                 {expr: "/users/user[last()]/name", expected: []string{"kim"}},
+		"attribute exists":     {expr: "//user[@role]/name", expected: []string{"jan"}},
+		"attribute equals":     {expr: `//user[@id="3"]/name`, expected: []string{"lee"}},
+		"attribute not equals": {expr: "/users/user[@id != '1']/name", expected: []string{"kim"}},
+		"child equals":         {expr: "//user[name='kim']/@id", expected: []string{"2"}},
+		"number equals":        {expr: "//user[age=28]/name", expected: []string{"kim"}},
+		"text equals":          {expr: "//name[text()='lee']/../@id", expected: []string{"3"}},
+		"self":                 {expr: "//name[.='jan']/.", expected: []string{"jan"}},
+		"missing":              {expr: "/users/customer", expected: nil},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path, err := compileXPath(test.expr)
+			assert.NoError(t, err)
+
+			var values []string
+			for _, node := range path.evaluate(doc) {
+				values = append(values, node.text())
+			}
+			assert.Equal(t, test.expected, values)
+		})
+	}
+}
+
+func TestXPath_CompileErrors(t *testing.T) {
+	for _, expr := range []string{"", "/users/", "/users[1", "//user[@id='1]", "/count(user)", "/users]"} {
+		_, err := compileXPath(expr)
+		assert.Error(t, err, expr)
+	}
+}
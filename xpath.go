@@ -0,0 +1,431 @@
+package apitest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// xPath is a compiled XPath expression, e.g. /users/user[@id='1']/name
+type xPath struct {
+	steps []xPathStep
+}
+
+type xPathAxis int
+
+const (
+	xPathChild xPathAxis = iota
+	xPathAttribute
+	xPathSelf
+	xPathParent
+)
+
+// xPathStep selects the nodes on the axis of the context node that match the name test, which is a local name, * or
+// text(). A recursive step (//) applies to the context node and all its descendants
+type xPathStep struct {
+	axis       xPathAxis
+	recursive  bool
+	test       string
+	predicates []xPathPredicate
+}
+
+type xPathPredicate interface {
+	filter(nodes []*xmlNode) []*xmlNode
+}
+
+type (
+	// xPathPosition selects the node at the 1-based position, or the last node, e.g. [1] and [last()]
+	xPathPosition struct {
+		position int
+		last     bool
+	}
+
+	// xPathCondition selects the nodes where the operand exists or where the value of the operand is (not) equal to
+	// the literal, e.g. [@id], [@id='1'] and [name!='jan']
+	xPathCondition struct {
+		operand xPathOperand
+		op      string
+		literal string
+	}
+
+	xPathOperand struct {
+		axis xPathAxis
+		test string
+	}
+)
+
+// ResponseXPath asserts on the node selected from the response body by an XPath expression
+type ResponseXPath struct {
+	response *Response
+	expr     string
+	path     *xPath
+}
+
+type xPathAssertion struct {
+	expr     string
+	path     *xPath
+	expected string
+}
+
+// Equal asserts that the text of the first selected node equals the expected value, which is formatted with fmt.Sprint.
+// Leading and trailing whitespace of the text is ignored
+func (x *ResponseXPath) Equal(expected interface{}) *Response {
+	x.response.xPaths = append(x.response.xPaths, xPathAssertion{expr: x.expr, path: x.path, expected: fmt.Sprint(expected)})
+	return x.response
+}
+
+func (a xPathAssertion) assert(doc *xmlNode) error {
+	nodes := a.path.evaluate(doc)
+	if len(nodes) == 0 {
+		return fmt.Errorf("XPath %s: expected %q, got nothing", a.expr, a.expected)
+	}
+	if actual := strings.TrimSpace(nodes[0].text()); actual != a.expected {
+		return fmt.Errorf("XPath %s: expected %q, got %q", a.expr, a.expected, actual)
+	}
+	return nil
+}
+
+// compileXPath parses an XPath expression. Supported are absolute and relative location paths using the child (/),
+// descendant (//), self (.), parent (..) and attribute (@) axes, the name tests *, text() and names, where a prefix
+// is ignored, and predicates selecting a position or comparing an attribute, child element or text with a literal
+func compileXPath(expr string) (*xPath, error) {
+	p := &xPathParser{expr: strings.TrimSpace(expr)}
+	if p.expr == "" {
+		return nil, fmt.Errorf("invalid XPath: empty expression")
+	}
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, fmt.Errorf("invalid XPath '%s': %s", expr, err)
+	}
+	return path, nil
+}
+
+// evaluate returns the nodes selected by the path from the document
+func (x *xPath) evaluate(doc *xmlNode) []*xmlNode {
+	nodes := []*xmlNode{doc}
+	for _, step := range x.steps {
+		var next []*xmlNode
+		seen := map[*xmlNode]bool{}
+		for _, node := range nodes {
+			contexts := []*xmlNode{node}
+			if step.recursive {
+				contexts = node.descendantsOrSelf(contexts[:0])
+			}
+			for _, context := range contexts {
+				for _, selected := range step.apply(context) {
+					if !seen[selected] {
+						seen[selected] = true
+						next = append(next, selected)
+					}
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func (n *xmlNode) descendantsOrSelf(out []*xmlNode) []*xmlNode {
+	out = append(out, n)
+	for _, child := range n.children {
+		if child.kind == xmlElementNode {
+			out = child.descendantsOrSelf(out)
+		}
+	}
+	return out
+}
+
+func (s xPathStep) apply(context *xmlNode) []*xmlNode {
+	nodes := xPathOperand{axis: s.axis, test: s.test}.selectNodes(context)
+	for _, predicate := range s.predicates {
+		nodes = predicate.filter(nodes)
+	}
+	return nodes
+}
+
+func (o xPathOperand) selectNodes(context *xmlNode) []*xmlNode {
+	switch o.axis {
+	case xPathSelf:
+		return []*xmlNode{context}
+	case xPathParent:
+		if context.parent == nil {
+			return nil
+		}
+		return []*xmlNode{context.parent}
+	case xPathAttribute:
+		var nodes []*xmlNode
+		for _, attr := range context.attrs {
+			if o.test == "*" || attr.name.Local == o.test {
+				nodes = append(nodes, attr)
+			}
+		}
+		return nodes
+	default:
+		var nodes []*xmlNode
+		for _, child := range context.children {
+			if o.matches(child) {
+				nodes = append(nodes, child)
+			}
+		}
+		return nodes
+	}
+}
+
+func (o xPathOperand) matches(node *xmlNode) bool {
+	switch o.test {
+	case "text()":
+		return node.kind == xmlTextNode
+	case "*":
+		return node.kind == xmlElementNode
+	default:
+		return node.kind == xmlElementNode && node.name.Local == o.test
+	}
+}
+
+func (p xPathPosition) filter(nodes []*xmlNode) []*xmlNode {
+	position := p.position
+	if p.last {
+		position = len(nodes)
+	}
+	if position < 1 || position > len(nodes) {
+		return nil
+	}
+	return nodes[position-1 : position]
+}
+
+func (c xPathCondition) filter(nodes []*xmlNode) []*xmlNode {
+	var selected []*xmlNode
+	for _, node := range nodes {
+		if c.matches(node) {
+			selected = append(selected, node)
+		}
+	}
+	return selected
+}
+
+// matches follows the comparison of node sets in XPath, i.e. a condition matches if any node of the operand matches
+func (c xPathCondition) matches(node *xmlNode) bool {
+	operands := c.operand.selectNodes(node)
+	if c.op == "" {
+		return len(operands) > 0
+	}
+	for _, operand := range operands {
+		equal := xPathValuesEqual(strings.TrimSpace(operand.text()), c.literal)
+		if equal == (c.op == "=") {
+			return true
+		}
+	}
+	return false
+}
+
+// xPathValuesEqual compares the values as numbers if both are numbers, e.g. 10 equals 10.0, and as strings otherwise
+func xPathValuesEqual(a, b string) bool {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return x == y
+	}
+	return a == b
+}
+
+type xPathParser struct {
+	expr string
+	pos  int
+}
+
+func (p *xPathParser) peek() byte {
+	if p.pos >= len(p.expr) {
+		return 0
+	}
+	return p.expr[p.pos]
+}
+
+func (p *xPathParser) skipSpaces() {
+	for p.pos < len(p.expr) && p.expr[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *xPathParser) consume(s string) bool {
+	if strings.HasPrefix(p.expr[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *xPathParser) parsePath() (*xPath, error) {
+	path := &xPath{}
+	recursive := false
+	switch {
+	case p.consume("//"):
+		recursive = true
+	case p.consume("/"):
+		if p.pos == len(p.expr) {
+			// the root path selects the document
+			return path, nil
+		}
+	}
+	for {
+		step, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		step.recursive = recursive
+		path.steps = append(path.steps, step)
+
+		switch {
+		case p.pos == len(p.expr):
+			return path, nil
+		case p.consume("//"):
+			recursive = true
+		case p.consume("/"):
+			recursive = false
+		default:
+			return nil, fmt.Errorf("unexpected '%s' at position %d", p.expr[p.pos:], p.pos)
+		}
+	}
+}
+
+func (p *xPathParser) parseStep() (xPathStep, error) {
+	switch {
+	case p.consume(".."):
+		return xPathStep{axis: xPathParent}, nil
+	case p.consume("."):
+		return xPathStep{axis: xPathSelf}, nil
+	}
+
+	operand, err := p.parseOperand()
+	if err != nil {
+		return xPathStep{}, err
+	}
+	step := xPathStep{axis: operand.axis, test: operand.test}
+	for p.consume("[") {
+		predicate, err := p.parsePredicate()
+		if err != nil {
+			return xPathStep{}, err
+		}
+		step.predicates = append(step.predicates, predicate)
+	}
+	return step, nil
+}
+
+// parseOperand parses an attribute (@id), a child element (name) or text (text()) of the context node
+func (p *xPathParser) parseOperand() (xPathOperand, error) {
+	if p.consume("@") {
+		name, err := p.parseName()
+		return xPathOperand{axis: xPathAttribute, test: name}, err
+	}
+	name, err := p.parseName()
+	if err != nil {
+		return xPathOperand{}, err
+	}
+	if p.consume("(") {
+		if name != "text" || !p.consume(")") {
+			return xPathOperand{}, fmt.Errorf("unsupported function %s at position %d", name, p.pos)
+		}
+		name = "text()"
+	}
+	return xPathOperand{axis: xPathChild, test: name}, nil
+}
+
+// parseName parses a name test and drops its prefix, e.g. ns:user selects the elements named user in any namespace
+func (p *xPathParser) parseName() (string, error) {
+	if p.consume("*") {
+		return "*", nil
+	}
+	start := p.pos
+	for p.pos < len(p.expr) && isXPathNameChar(rune(p.expr[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", fmt.Errorf("expected a name at position %d", p.pos)
+	}
+	name := p.expr[start:p.pos]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name, nil
+}
+
+func isXPathNameChar(r rune) bool {
+	return r >= 0x80 || unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.:", r)
+}
+
+func (p *xPathParser) parsePredicate() (xPathPredicate, error) {
+	p.skipSpaces()
+	var predicate xPathPredicate
+	switch c := p.peek(); {
+	case c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.expr) && p.expr[p.pos] >= '0' && p.expr[p.pos] <= '9' {
+			p.pos++
+		}
+		position, _ := strconv.Atoi(p.expr[start:p.pos])
+		predicate = xPathPosition{position: position}
+	case p.consume("last()"):
+		predicate = xPathPosition{last: true}
+	default:
+		condition, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		predicate = condition
+	}
+	p.skipSpaces()
+	if !p.consume("]") {
+		return nil, fmt.Errorf("expected ']' at position %d", p.pos)
+	}
+	return predicate, nil
+}
+
+func (p *xPathParser) parseCondition() (xPathCondition, error) {
+	var condition xPathCondition
+	if p.consume(".") {
+		condition.operand = xPathOperand{axis: xPathSelf}
+	} else {
+		operand, err := p.parseOperand()
+		if err != nil {
+			return condition, err
+		}
+		condition.operand = operand
+	}
+
+	p.skipSpaces()
+	switch {
+	case p.consume("!="):
+		condition.op = "!="
+	case p.consume("="):
+		condition.op = "="
+	default:
+		return condition, nil
+	}
+	p.skipSpaces()
+	literal, err := p.parseLiteral()
+	if err != nil {
+		return condition, err
+	}
+	condition.literal = literal
+	return condition, nil
+}
+
+// parseLiteral parses a string in single or double quotes or a number
+func (p *xPathParser) parseLiteral() (string, error) {
+	start := p.pos
+	if quote := p.peek(); quote == '\'' || quote == '"' {
+		end := strings.IndexByte(p.expr[start+1:], quote)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string at position %d", start)
+		}
+		p.pos = start + 1 + end + 1
+		return p.expr[start+1 : start+1+end], nil
+	}
+	for p.pos < len(p.expr) && strings.IndexByte("0123456789.-", p.expr[p.pos]) >= 0 {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", fmt.Errorf("expected a literal at position %d", p.pos)
+	}
+	return p.expr[start:p.pos], nil
+}